@@ -2,6 +2,7 @@ package at
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"io"
 	"strings"
@@ -9,18 +10,84 @@ import (
 )
 
 // Client is an AT command client.
+//
+// A background goroutine reads from the modem, delivering response
+// lines to the command in flight and unsolicited result codes (URCs)
+// to their handlers.
 type Client struct {
-	rw io.ReadWriter
-	s  *bufio.Scanner
+	w  io.Writer
+	r  *bufio.Reader
 	mx sync.Mutex
+
+	// hMx protects the fields below, shared with the reader.
+	hMx     sync.Mutex
+	urc     map[string]URCHandler
+	cur     *pending
+	data    *DataConn
+	readErr error
+	done    chan struct{}
+
+	// stale counts abandoned commands whose final result has not
+	// been read yet.
+	stale int
 }
 
-// NewClient creates a new AT command client.
-func NewClient(rw io.ReadWriter) *Client {
-	s := bufio.NewScanner(rw)
-	s.Split(bufio.ScanLines)
+// URCHandler is called with the value of an unsolicited result code,
+// which is the text after the colon, if any.
+//
+// Handlers are called from the reader goroutine, so they must not block
+// or issue commands on the Client directly.
+type URCHandler func(value string)
+
+// Command is a single command sent to the modem.
+type Command struct {
+	// Line is the full command line, such as `AT+CMGS="+15551234"`.
+	Line string
+
+	// Name is the prefix of information responses, without the "+".
+	// It defaults to the name of the command in Line.
+	Name string
+
+	// Payload, if non-nil, is sent after the modem sends a "> " prompt.
+	Payload []byte
+
+	// Terminator is written after Payload. If nil, Ctrl-Z (0x1a)
+	// is used, as for SMS. Use an empty slice to send nothing, as for
+	// socket sends with an explicit length.
+	Terminator []byte
+
+	// connect enters data mode on a CONNECT result.
+	connect bool
+}
+
+// pending is the command in flight.
+type pending struct {
+	name       string
+	line       string
+	wantPrompt bool
+	echoed     bool
+	connect    bool
 
-	return &Client{rw: rw, s: s}
+	// sent is set once the command line is written, and final once
+	// the reader has delivered its final result.
+	sent  bool
+	final bool
+
+	lines   chan string
+	prompt  chan struct{}
+	abandon chan struct{}
+}
+
+// NewClient creates a new AT command client and starts reading from rw.
+func NewClient(rw io.ReadWriter) *Client {
+	c := &Client{
+		w:    rw,
+		r:    bufio.NewReader(rw),
+		urc:  make(map[string]URCHandler),
+		done: make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
 }
 
 var builders = sync.Pool{
@@ -29,6 +96,152 @@ var builders = sync.Pool{
 	},
 }
 
+// HandleURC registers a handler for an unsolicited result code such as
+// "+CREG" or "RING". A nil handler removes it.
+//
+// Lines starting with the name of the command in flight are treated as
+// its response instead, so that querying "AT+CREG?" works while a
+// "+CREG" handler is registered.
+func (c *Client) HandleURC(name string, h URCHandler) {
+	name = strings.ToUpper(name)
+
+	c.hMx.Lock()
+	defer c.hMx.Unlock()
+	if h == nil {
+		delete(c.urc, name)
+		return
+	}
+	c.urc[name] = h
+}
+
+func (c *Client) readLoop() {
+	var line []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := c.r.Read(buf)
+		p := buf[:n]
+		for len(p) > 0 {
+			c.hMx.Lock()
+			data := c.data
+			if data != nil {
+				n, end := data.deliver(p)
+				p = p[n:]
+				if end {
+					// the modem hung up and is in command mode
+					c.data = nil
+				}
+			}
+			c.hMx.Unlock()
+			if data != nil {
+				continue
+			}
+
+			b := p[0]
+			p = p[1:]
+			switch {
+			case b == '\n':
+				c.dispatch(string(line))
+				line = line[:0]
+			case b == '>' && len(strings.TrimSpace(string(line))) == 0 && c.promptReady():
+				line = line[:0]
+			default:
+				line = append(line, b)
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		if len(line) > 0 {
+			c.dispatch(string(line))
+		}
+		c.hMx.Lock()
+		c.readErr = err
+		if c.data != nil {
+			c.data.close(err)
+		}
+		c.hMx.Unlock()
+		close(c.done)
+		return
+	}
+}
+
+// promptReady signals the command in flight if it is waiting for a prompt.
+func (c *Client) promptReady() bool {
+	c.hMx.Lock()
+	defer c.hMx.Unlock()
+	if c.cur == nil || !c.cur.wantPrompt {
+		return false
+	}
+
+	c.cur.wantPrompt = false
+	c.cur.prompt <- struct{}{}
+	return true
+}
+
+func (c *Client) dispatch(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	c.hMx.Lock()
+	cur := c.cur
+	var h URCHandler
+	var value string
+	for name, fn := range c.urc {
+		if cur != nil && name == "+"+cur.name {
+			continue
+		}
+		if line == name {
+			h = fn
+			break
+		}
+		if v, ok := strings.CutPrefix(line, name+":"); ok {
+			h, value = fn, strings.TrimSpace(v)
+			break
+		}
+	}
+	if h == nil && c.stale > 0 {
+		switch {
+		case cur != nil && line == cur.line:
+			// the modem echoed the current command, so it won't
+			// answer the abandoned ones
+			c.stale = 0
+		case isFinal(line):
+			// the late result of an abandoned command
+			c.stale--
+			cur = nil
+		default:
+			cur = nil
+		}
+	}
+	if h == nil && cur != nil && isFinal(line) {
+		cur.final = true
+	}
+	c.hMx.Unlock()
+
+	if h != nil {
+		h(value)
+		return
+	}
+	if cur == nil {
+		// unsolicited and unhandled
+		return
+	}
+	if cur.connect && isConnect(line) {
+		// data following the result is read in data mode
+		c.hMx.Lock()
+		c.data = newDataConn(c)
+		c.hMx.Unlock()
+	}
+
+	select {
+	case cur.lines <- line:
+	case <-cur.abandon:
+	}
+}
+
 // Set sets the value of a parameter.
 //
 // The following example will result in sending "AT+FOO=bar\r\n"
@@ -36,6 +249,12 @@ var builders = sync.Pool{
 //
 //	data, err := c.Set("foo", "bar")
 func (c *Client) Set(name string, params ...string) (*Response, error) {
+	return c.SetContext(context.Background(), name, params...)
+}
+
+// SetContext is like Set, but gives up waiting for the response when
+// ctx is done.
+func (c *Client) SetContext(ctx context.Context, name string, params ...string) (*Response, error) {
 	name = strings.ToUpper(name)
 	name = EscapeString(name, '=', '?')
 
@@ -52,7 +271,7 @@ func (c *Client) Set(name string, params ...string) (*Response, error) {
 	}
 	defer builders.Put(b)
 
-	return c._Command(name, b.String())
+	return c.Do(ctx, Command{Name: name, Line: b.String()})
 }
 
 // Query queries the value of a parameter.
@@ -62,10 +281,16 @@ func (c *Client) Set(name string, params ...string) (*Response, error) {
 //
 //	data, err := c.Query("foo")
 func (c *Client) Query(name string) (*Response, error) {
+	return c.QueryContext(context.Background(), name)
+}
+
+// QueryContext is like Query, but gives up waiting for the response when
+// ctx is done.
+func (c *Client) QueryContext(ctx context.Context, name string) (*Response, error) {
 	name = strings.ToUpper(name)
 	name = EscapeString(name, '=', '?')
 
-	return c._Command(name, "AT+"+name+"?")
+	return c.Do(ctx, Command{Name: name, Line: "AT+" + name + "?"})
 }
 
 // Execute executes a command.
@@ -75,48 +300,204 @@ func (c *Client) Query(name string) (*Response, error) {
 //
 //	data, err := c.Execute("foo")
 func (c *Client) Execute(name string) (*Response, error) {
+	return c.ExecuteContext(context.Background(), name)
+}
+
+// ExecuteContext is like Execute, but gives up waiting for the response
+// when ctx is done.
+func (c *Client) ExecuteContext(ctx context.Context, name string) (*Response, error) {
 	name = strings.ToUpper(name)
 	name = EscapeString(name, '=', '?')
 
-	return c._Command(name, "AT+"+name)
+	return c.Do(ctx, Command{Name: name, Line: "AT+" + name})
 }
 
-func (c *Client) _Command(name, line string) (*Response, error) {
-	if strings.ContainsRune(line, '\n') {
-		return nil, errors.New("at: invalid command: " + line)
+// Do sends a command and waits for its final result.
+//
+// An ERROR result returns a Response with OK set to false and a nil error.
+// "+CME ERROR" and "+CMS ERROR" results are returned as a *CMEError or
+// *CMSError.
+//
+// If ctx is done first, ctx.Err() is returned. The modem may still send
+// a result for the abandoned command, which is discarded when it arrives.
+func (c *Client) Do(ctx context.Context, cmd Command) (*Response, error) {
+	if strings.ContainsAny(cmd.Line, "\r\n") {
+		return nil, errors.New("at: invalid command: " + cmd.Line)
+	}
+	if cmd.Name == "" {
+		cmd.Name = Cmd{FullName: strings.ToUpper(cmd.Line)}.Name()
 	}
 
 	c.mx.Lock()
 	defer c.mx.Unlock()
 
-	_, err := io.WriteString(c.rw, line+"\r\n")
+	p := newPending(cmd)
+	c.hMx.Lock()
+	if c.readErr != nil {
+		err := c.readErr
+		c.hMx.Unlock()
+		return nil, err
+	}
+	if c.data != nil {
+		c.hMx.Unlock()
+		return nil, errors.New("at: in data mode")
+	}
+	c.cur = p
+	c.hMx.Unlock()
+	defer c.finish(p)
+
+	_, err := io.WriteString(c.w, cmd.Line+"\r\n")
 	if err != nil {
 		return nil, err
 	}
+	p.sent = true
 
 	resp := new(Response)
-	for c.s.Scan() {
-		line := c.s.Text()
+	if cmd.Payload != nil {
+		done, err := c.waitPrompt(ctx, p, resp)
+		if done || err != nil {
+			return resp, err
+		}
 
-		switch {
-		case line == "":
-			return nil, io.ErrUnexpectedEOF
-		case line == "OK":
-			resp.OK = true
-			return resp, nil
-		case line == "ERROR":
-			return resp, nil
-		case strings.HasPrefix(line, "+"+name+": "):
-			line = strings.TrimPrefix(line, "+"+name+": ")
-			resp.Data = append(resp.Data, line)
+		term := cmd.Terminator
+		if term == nil {
+			term = []byte{0x1a}
+		}
+		_, err = c.w.Write(append(append([]byte{}, cmd.Payload...), term...))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		line, err := c.next(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if done, err := p.handleLine(resp, line); done {
+			return resp, err
+		}
+	}
+}
+
+func newPending(cmd Command) *pending {
+	return &pending{
+		name:       cmd.Name,
+		line:       cmd.Line,
+		wantPrompt: cmd.Payload != nil,
+		connect:    cmd.connect,
+		lines:      make(chan string, 16),
+		prompt:     make(chan struct{}, 1),
+		abandon:    make(chan struct{}),
+	}
+}
+
+// finish clears the command in flight.
+func (c *Client) finish(p *pending) {
+	c.hMx.Lock()
+	if p.sent && !p.final {
+		c.stale++
+	}
+	c.cur = nil
+	c.hMx.Unlock()
+	close(p.abandon)
+}
+
+// waitPrompt waits for the payload prompt. It returns true if a final
+// result was received instead.
+func (c *Client) waitPrompt(ctx context.Context, p *pending, resp *Response) (bool, error) {
+	for {
+		select {
+		case <-p.prompt:
+			return false, nil
+		case line := <-p.lines:
+			if done, err := p.handleLine(resp, line); done {
+				if err == nil && resp.OK {
+					err = errors.New("at: expected prompt")
+				}
+				return true, err
+			}
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-c.done:
+			return true, c.err()
+		}
+	}
+}
+
+// next returns the next line for the command in flight.
+func (c *Client) next(ctx context.Context, p *pending) (string, error) {
+	select {
+	case line := <-p.lines:
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-c.done:
+		// deliver anything read before the error
+		select {
+		case line := <-p.lines:
+			return line, nil
 		default:
-			return nil, errors.New("at: invalid response: " + line)
+			return "", c.err()
 		}
 	}
+}
+
+func (c *Client) err() error {
+	c.hMx.Lock()
+	defer c.hMx.Unlock()
+	if c.readErr == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return c.readErr
+}
 
-	if c.s.Err() != nil {
-		return nil, c.s.Err()
+// handleLine adds a response line to resp, returning true for a final result.
+func (p *pending) handleLine(resp *Response, line string) (bool, error) {
+	switch {
+	case line == "OK":
+		resp.OK = true
+		return true, nil
+	case line == "ERROR":
+		return true, nil
+	case line == "NO CARRIER":
+		return true, ErrNoCarrier
+	case line == "BUSY":
+		return true, ErrBusy
+	case line == "NO ANSWER":
+		return true, ErrNoAnswer
+	case line == "NO DIALTONE":
+		return true, ErrNoDialtone
+	case isConnect(line):
+		if !p.connect {
+			return true, errors.New("at: unexpected CONNECT, use Client.Connect")
+		}
+		resp.OK = true
+		return true, nil
+	case strings.HasPrefix(line, "+CME ERROR:"):
+		return true, parseCMEError(line)
+	case strings.HasPrefix(line, "+CMS ERROR:"):
+		return true, parseCMSError(line)
+	case line == p.line && !p.echoed && len(resp.Data) == 0:
+		// echo, only skipped once so that AT+CLAC can list itself
+		p.echoed = true
+	case strings.HasPrefix(line, "+"+p.name+":"):
+		resp.Data = append(resp.Data, strings.TrimSpace(strings.TrimPrefix(line, "+"+p.name+":")))
+	default:
+		// information text without a prefix, like a serial number
+		resp.Data = append(resp.Data, line)
 	}
+	return false, nil
+}
 
-	return nil, io.ErrUnexpectedEOF
+// isFinal returns true if line is a final result code, which ends a
+// command.
+func isFinal(line string) bool {
+	switch line {
+	case "OK", "ERROR", "NO CARRIER", "BUSY", "NO ANSWER", "NO DIALTONE":
+		return true
+	}
+	return isConnect(line) ||
+		strings.HasPrefix(line, "+CME ERROR:") ||
+		strings.HasPrefix(line, "+CMS ERROR:")
 }
@@ -0,0 +1,193 @@
+package at_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/at"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeModem answers each command line using replies.
+func fakeModem(t *testing.T, replies map[string]string) (*at.Client, net.Conn) {
+	t.Helper()
+	host, dev := net.Pipe()
+	t.Cleanup(func() {
+		host.Close()
+		dev.Close()
+	})
+
+	go func() {
+		r := bufio.NewReader(dev)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			reply, ok := replies[line[:len(line)-2]]
+			if !ok {
+				reply = "\r\nERROR\r\n"
+			}
+			if _, err := io.WriteString(dev, reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	return at.NewClient(host), dev
+}
+
+func TestClient_URC(t *testing.T) {
+	c, dev := fakeModem(t, map[string]string{
+		"AT+CREG?": "\r\n+CREG: 0,1\r\nRING\r\n\r\nOK\r\n",
+	})
+
+	creg := make(chan string, 2)
+	ring := make(chan string, 2)
+	c.HandleURC("+CREG", func(v string) { creg <- v })
+	c.HandleURC("RING", func(v string) { ring <- v })
+
+	// idle URCs
+	_, err := io.WriteString(dev, "\r\n+CREG: 5\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "5", <-creg)
+
+	// the query response goes to the command, RING to its handler
+	resp, err := c.Query("CREG")
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+	assert.Equal(t, []string{"0,1"}, resp.Data)
+	assert.Equal(t, "", <-ring)
+	assert.Empty(t, creg)
+}
+
+func TestClient_Errors(t *testing.T) {
+	c, _ := fakeModem(t, map[string]string{
+		"AT+CPIN?":  "\r\n+CME ERROR: 10\r\n",
+		"AT+CMGR=1": "\r\n+CMS ERROR: invalid memory index\r\n",
+		"AT+CGSN":   "\r\n123456789012345\r\n\r\nOK\r\n",
+	})
+
+	_, err := c.Query("CPIN")
+	var cme *at.CMEError
+	require.True(t, errors.As(err, &cme), "got %v", err)
+	assert.Equal(t, 10, cme.Code)
+
+	_, err = c.Set("CMGR", "1")
+	var cms *at.CMSError
+	require.True(t, errors.As(err, &cms), "got %v", err)
+	assert.Equal(t, -1, cms.Code)
+	assert.Equal(t, "invalid memory index", cms.Text)
+
+	resp, err := c.Execute("CGSN")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"123456789012345"}, resp.Data)
+
+	resp, err = c.Execute("BOGUS")
+	require.NoError(t, err)
+	assert.False(t, resp.OK)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	// SLOW is never answered, but the echo shows FAST is being run
+	c, _ := fakeModem(t, map[string]string{
+		"AT+SLOW": "",
+		"AT+FAST": "AT+FAST\r\r\n\r\nOK\r\n",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.ExecuteContext(ctx, "SLOW")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	resp, err := c.Execute("FAST")
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+}
+
+func TestClient_LateResult(t *testing.T) {
+	host, dev := net.Pipe()
+	t.Cleanup(func() {
+		host.Close()
+		dev.Close()
+	})
+	c := at.NewClient(host)
+
+	go func() {
+		r := bufio.NewReader(dev)
+		r.ReadString('\n')
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(dev, "\r\n+CME ERROR: 3\r\n")
+		r.ReadString('\n')
+		io.WriteString(dev, "\r\nOK\r\n")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.ExecuteContext(ctx, "SLOW")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the late result of SLOW is discarded
+	resp, err := c.Execute("FAST")
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+}
+
+func TestClient_DialResults(t *testing.T) {
+	c, _ := fakeModem(t, map[string]string{
+		"ATD1": "\r\nBUSY\r\n",
+		"ATD2": "\r\nNO ANSWER\r\n",
+		"ATD3": "\r\nNO DIALTONE\r\n",
+		"ATD4": "\r\nNO CARRIER\r\n",
+	})
+
+	for line, want := range map[string]error{
+		"ATD1": at.ErrBusy,
+		"ATD2": at.ErrNoAnswer,
+		"ATD3": at.ErrNoDialtone,
+		"ATD4": at.ErrNoCarrier,
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := c.Do(ctx, at.Command{Line: line})
+		cancel()
+		assert.ErrorIs(t, err, want, line)
+	}
+}
+
+func TestClient_Payload(t *testing.T) {
+	host, dev := net.Pipe()
+	t.Cleanup(func() {
+		host.Close()
+		dev.Close()
+	})
+	c := at.NewClient(host)
+
+	payload := make(chan string, 1)
+	go func() {
+		r := bufio.NewReader(dev)
+		line, _ := r.ReadString('\n')
+		if line != "AT+CMGS=\"+15551234\"\r\n" {
+			payload <- "unexpected command: " + line
+			return
+		}
+		io.WriteString(dev, "\r\n> ")
+		msg, _ := r.ReadString(0x1a)
+		payload <- msg
+		io.WriteString(dev, "\r\n+CMGS: 42\r\n\r\nOK\r\n")
+	}()
+
+	resp, err := c.Do(context.Background(), at.Command{
+		Line:    `AT+CMGS="+15551234"`,
+		Payload: []byte("hello"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello\x1a", <-payload)
+	assert.True(t, resp.OK)
+	assert.Equal(t, []string{"42"}, resp.Data)
+}
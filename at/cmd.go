@@ -14,6 +14,9 @@ type Cmd struct {
 
 	// Command parameter(s).
 	Params []string
+
+	// raw is the unparsed parameter text of a set command.
+	raw string
 }
 
 // IsSet returns true if the command is a set command.
@@ -0,0 +1,374 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// result codes, as numbered by V.250
+const (
+	resultOK        = 0
+	resultConnect   = 1
+	resultNoCarrier = 3
+	resultError     = 4
+)
+
+var resultText = [...]string{
+	resultOK:        "OK",
+	resultConnect:   "CONNECT",
+	resultNoCarrier: "NO CARRIER",
+	resultError:     "ERROR",
+}
+
+// result returns the final result line for code. wMx must be held.
+func (s *Server) result(code int) string {
+	if s.verbose {
+		return resultText[code] + s.eol()
+	}
+	return string(rune('0'+code)) + string(s.sreg[3])
+}
+
+// guard returns the escape guard time from S12, in fiftieths of a second.
+// wMx must be held.
+func (s *Server) guard() time.Duration {
+	return time.Duration(s.sreg[12]) * time.Second / 50
+}
+
+// Connect switches to data mode on conn once the current handler returns
+// an OK response, which is sent as CONNECT instead. It is meant to be
+// called from a handler, such as one for "ATD".
+//
+// In data mode, bytes from the host are written to conn and bytes read
+// from conn are sent to the host, until the host sends the escape sequence:
+// three S2 characters ("+++") with at least the S12 guard time of silence
+// before and after. The server then answers OK and returns to command mode,
+// where "ATO" resumes data mode and "ATH" drops conn, closing it if it is an
+// io.Closer. If reading conn fails, the server returns to command mode and
+// sends NO CARRIER.
+func (s *Server) Connect(conn io.ReadWriter) {
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	if s.conn != conn {
+		s.drop()
+		s.conn = conn
+		go s.pump(conn)
+	}
+	s.connect = true
+}
+
+// drop forgets the current connection. wMx must be held.
+func (s *Server) drop() {
+	if s.conn == nil {
+		return
+	}
+	if c, ok := s.conn.(io.Closer); ok {
+		c.Close()
+	}
+	s.conn = nil
+	s.online = false
+	s.cond.Broadcast()
+}
+
+// hangup handles a connection that failed to read.
+func (s *Server) hangup(conn io.ReadWriter) error {
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	if s.conn != conn {
+		// already dropped
+		return nil
+	}
+	s.drop()
+
+	if _, err := io.WriteString(s.w, s.eol()+s.result(resultNoCarrier)); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// pump sends data from conn to the host while in data mode.
+func (s *Server) pump(conn io.ReadWriter) {
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+
+		s.wMx.Lock()
+		for s.conn == conn && !s.online {
+			s.cond.Wait()
+		}
+		if s.conn != conn {
+			s.wMx.Unlock()
+			return
+		}
+		if n > 0 {
+			s.w.Write(buf[:n])
+			s.w.Flush()
+		}
+		s.wMx.Unlock()
+
+		if err != nil {
+			select {
+			case s.lost <- conn:
+			case <-s.done:
+			}
+			return
+		}
+	}
+}
+
+// dataState tracks the escape sequence in data mode.
+type dataState struct {
+	// last is when data was last received
+	last time.Time
+
+	// esc is the number of escape characters held back
+	esc  int
+	escC <-chan time.Time
+
+	// skipLF drops the LF of the command line that entered data mode
+	skipLF bool
+}
+
+// write passes data from the host to the connection, holding back a
+// possible escape sequence.
+func (d *dataState) write(s *Server, p []byte, t time.Time) error {
+	s.wMx.Lock()
+	escChar := s.sreg[2]
+	guard := s.guard()
+	conn := s.conn
+	s.wMx.Unlock()
+
+	if d.skipLF && p[0] == '\n' {
+		p = p[1:]
+	}
+	d.skipLF = false
+
+	out := make([]byte, 0, len(p)+3)
+	for i, b := range p {
+		if d.esc < 3 && b == escChar && (d.esc > 0 || (i == 0 && t.Sub(d.last) >= guard)) {
+			d.esc++
+			if d.esc == 3 {
+				d.escC = time.After(guard)
+			}
+			continue
+		}
+
+		// not an escape sequence after all
+		for ; d.esc > 0; d.esc-- {
+			out = append(out, escChar)
+		}
+		d.escC = nil
+		out = append(out, b)
+	}
+	d.last = t
+
+	if len(out) == 0 {
+		return nil
+	}
+	_, err := conn.Write(out)
+	return err
+}
+
+var (
+	// ErrNoCarrier is returned when the modem reports NO CARRIER, such as
+	// when a data connection ends or fails to connect.
+	ErrNoCarrier = errors.New("at: no carrier")
+
+	// ErrBusy, ErrNoAnswer and ErrNoDialtone are returned for the other
+	// final results of a failed dial.
+	ErrBusy       = errors.New("at: busy")
+	ErrNoAnswer   = errors.New("at: no answer")
+	ErrNoDialtone = errors.New("at: no dialtone")
+)
+
+// DataConn is a connection in data mode, returned by Client.Connect.
+type DataConn struct {
+	c *Client
+
+	// Guard is the escape guard time, which must be at least the modem's
+	// S12 setting. Defaults to one second.
+	Guard time.Duration
+
+	mx     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	held   []byte
+	closed bool
+	err    error
+}
+
+// noCarrier is sent by the modem when the connection ends and it
+// returns to command mode.
+const noCarrier = "\r\nNO CARRIER\r\n"
+
+// Connect sends cmd, such as "ATD*99#" or "ATO", and waits for a CONNECT
+// result. The DataConn then reads and writes raw bytes until Escape is
+// called or the modem ends the connection. Other commands fail until then.
+//
+// The end of the connection is detected by a NO CARRIER result in the data
+// read from the modem, which is not passed on as data. Anything after it
+// is handled in command mode. Data that could be the start of the result
+// is held back until more is read.
+func (c *Client) Connect(ctx context.Context, cmd Command) (*DataConn, error) {
+	cmd.connect = true
+	resp, err := c.Do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, ErrCommand
+	}
+
+	c.hMx.Lock()
+	defer c.hMx.Unlock()
+	if c.data == nil {
+		// ended already
+		return nil, ErrNoCarrier
+	}
+	return c.data, nil
+}
+
+func newDataConn(c *Client) *DataConn {
+	d := &DataConn{c: c, Guard: time.Second}
+	d.cond = sync.NewCond(&d.mx)
+	return d
+}
+
+// deliver buffers data read from the modem and returns the number of
+// bytes of p used. It returns true, closing d, if NO CARRIER was read;
+// the rest of p is then for command mode.
+func (d *DataConn) deliver(p []byte) (int, bool) {
+	d.mx.Lock()
+	defer d.cond.Broadcast()
+	defer d.mx.Unlock()
+
+	// the result may be split across reads
+	held := len(d.held)
+	data := append(d.held, p...)
+	if i := bytes.Index(data, []byte(noCarrier)); i >= 0 {
+		d.buf.Write(data[:i])
+		d.held = nil
+		if !d.closed {
+			d.closed = true
+			d.err = io.EOF
+		}
+		return i + len(noCarrier) - held, true
+	}
+
+	n := heldLen(data)
+	d.buf.Write(data[:len(data)-n])
+	d.held = append(d.held[:0:0], data[len(data)-n:]...)
+	return len(p), false
+}
+
+// heldLen returns the length of the longest suffix of data that could
+// be the start of NO CARRIER.
+func heldLen(data []byte) int {
+	n := len(noCarrier) - 1
+	if n > len(data) {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if string(data[len(data)-n:]) == noCarrier[:n] {
+			break
+		}
+	}
+	return n
+}
+
+// close ends reads once buffered data is consumed.
+func (d *DataConn) close(err error) {
+	d.mx.Lock()
+	d.buf.Write(d.held)
+	d.held = nil
+	if !d.closed {
+		d.closed = true
+		d.err = err
+	}
+	d.mx.Unlock()
+	d.cond.Broadcast()
+}
+
+// Read reads data from the modem. It returns io.EOF after Escape or
+// NO CARRIER, or the read error of the Client, once buffered data has
+// been read.
+func (d *DataConn) Read(p []byte) (int, error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for d.buf.Len() == 0 && !d.closed {
+		d.cond.Wait()
+	}
+	if d.buf.Len() > 0 {
+		return d.buf.Read(p)
+	}
+	return 0, d.err
+}
+
+// Write writes data to the modem.
+func (d *DataConn) Write(p []byte) (int, error) {
+	d.mx.Lock()
+	closed := d.closed
+	d.mx.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	return d.c.w.Write(p)
+}
+
+// Escape sends the escape sequence to return the modem to command mode,
+// waiting the guard time before and after.
+func (d *DataConn) Escape(ctx context.Context) error {
+	c := d.c
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	t := time.NewTimer(d.Guard)
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+
+	p := newPending(Command{Line: "+++"})
+	c.hMx.Lock()
+	if c.data != d {
+		c.hMx.Unlock()
+		return ErrNoCarrier
+	}
+	c.data = nil
+	c.cur = p
+	c.hMx.Unlock()
+	defer c.finish(p)
+	d.close(io.EOF)
+
+	if _, err := io.WriteString(c.w, "+++"); err != nil {
+		return err
+	}
+	p.sent = true
+
+	resp := new(Response)
+	for {
+		line, err := c.next(ctx, p)
+		if err != nil {
+			return err
+		}
+		if done, err := p.handleLine(resp, line); done {
+			if err == nil && !resp.OK {
+				err = ErrCommand
+			}
+			return err
+		}
+	}
+}
+
+// isConnect returns true if line is a CONNECT result, which may include
+// the connection speed.
+func isConnect(line string) bool {
+	return line == "CONNECT" || strings.HasPrefix(line, "CONNECT ")
+}
@@ -0,0 +1,148 @@
+package at_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/at"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataMode(t *testing.T) {
+	host, dev := net.Pipe()
+	remote, local := net.Pipe()
+	t.Cleanup(func() {
+		host.Close()
+		dev.Close()
+		remote.Close()
+	})
+
+	srv := at.NewServer(dev, dev)
+	srv.SetSReg(12, 5) // 100ms guard time
+	srv.HandleFunc("AT+TUNNEL", func(at.Cmd) at.Response {
+		srv.Connect(local)
+		return at.Response{OK: true}
+	})
+	srv.HandleFunc("AT+PING", func(at.Cmd) at.Response { return at.Response{OK: true} })
+	go srv.Serve()
+
+	c := at.NewClient(host)
+	ctx := context.Background()
+	conn, err := c.Connect(ctx, at.Command{Line: "AT+TUNNEL"})
+	require.NoError(t, err)
+	conn.Guard = 150 * time.Millisecond
+
+	_, err = c.Execute("PING")
+	assert.Error(t, err, "commands fail in data mode")
+
+	// escape characters without the guard time are data
+	buf := make([]byte, 64)
+	_, err = conn.Write([]byte("a+++\r\nAT+PING\r\n"))
+	require.NoError(t, err)
+	n, err := io.ReadFull(remote, buf[:15])
+	require.NoError(t, err)
+	assert.Equal(t, "a+++\r\nAT+PING\r\n", string(buf[:n]))
+
+	_, err = remote.Write([]byte("\x00\xffbinary"))
+	require.NoError(t, err)
+	n, err = io.ReadFull(conn, buf[:8])
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\xffbinary", string(buf[:n]))
+
+	require.NoError(t, conn.Escape(ctx))
+	_, err = conn.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
+
+	resp, err := c.Execute("PING")
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+
+	// remote data is held until data mode resumes
+	go remote.Write([]byte("later"))
+	conn, err = c.Connect(ctx, at.Command{Line: "ATO"})
+	require.NoError(t, err)
+	conn.Guard = 150 * time.Millisecond
+	n, err = io.ReadFull(conn, buf[:5])
+	require.NoError(t, err)
+	assert.Equal(t, "later", string(buf[:n]))
+
+	require.NoError(t, conn.Escape(ctx))
+	resp, err = c.Do(ctx, at.Command{Line: "ATH"})
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+	_, err = remote.Read(buf)
+	assert.ErrorIs(t, err, io.EOF, "connection closed on hang up")
+
+	_, err = c.Connect(ctx, at.Command{Line: "ATO"})
+	assert.ErrorIs(t, err, at.ErrCommand)
+}
+
+func TestDataMode_NoCarrier(t *testing.T) {
+	host, dev := net.Pipe()
+	remote, local := net.Pipe()
+	t.Cleanup(func() {
+		host.Close()
+		dev.Close()
+	})
+
+	srv := at.NewServer(dev, dev)
+	srv.HandleFunc("AT+TUNNEL", func(at.Cmd) at.Response {
+		srv.Connect(local)
+		return at.Response{OK: true}
+	})
+	srv.HandleFunc("AT+PING", func(at.Cmd) at.Response { return at.Response{OK: true} })
+	go srv.Serve()
+
+	c := at.NewClient(host)
+	conn, err := c.Connect(context.Background(), at.Command{Line: "AT+TUNNEL"})
+	require.NoError(t, err)
+
+	_, err = remote.Write([]byte("bye\r\n"))
+	require.NoError(t, err)
+	remote.Close()
+
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "bye\r\n", string(data))
+
+	// back in command mode
+	resp, err := c.Execute("PING")
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+}
+
+func TestDataMode_NoCarrierSplit(t *testing.T) {
+	const noCarrier = "\r\nNO CARRIER\r\n"
+	for _, extra := range []string{"", "\r\nRING\r\n"} {
+		msg := "data\r\n" + noCarrier + extra
+		for i := 1; i < len(msg); i++ {
+			c, dev := fakeModem(t, map[string]string{"ATD1": "\r\nCONNECT\r\n"})
+			ring := make(chan string, 1)
+			c.HandleURC("RING", func(v string) { ring <- v })
+
+			conn, err := c.Connect(context.Background(), at.Command{Line: "ATD1"})
+			require.NoError(t, err)
+
+			for _, part := range []string{msg[:i], msg[i:]} {
+				_, err = io.WriteString(dev, part)
+				require.NoError(t, err)
+			}
+
+			data, err := io.ReadAll(conn)
+			require.NoError(t, err)
+			assert.Equal(t, "data\r\n", string(data), "split at %d", i)
+
+			if extra != "" {
+				select {
+				case <-ring:
+				case <-time.After(time.Second):
+					t.Fatalf("split at %d: RING not handled after NO CARRIER", i)
+				}
+			}
+		}
+	}
+}
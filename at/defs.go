@@ -0,0 +1,38 @@
+package at
+
+// Common 3GPP TS 27.007 commands, for use with Client.Call.
+var (
+	DefCGMI = Def{Name: "CGMI", Desc: "Request manufacturer identification.", Result: []Param{{Name: "manufacturer", Type: TypeString}}}
+	DefCGMM = Def{Name: "CGMM", Desc: "Request model identification.", Result: []Param{{Name: "model", Type: TypeString}}}
+	DefCGMR = Def{Name: "CGMR", Desc: "Request revision identification.", Result: []Param{{Name: "revision", Type: TypeString}}}
+	DefCGSN = Def{Name: "CGSN", Desc: "Request product serial number (IMEI).", Result: []Param{{Name: "sn", Type: TypeString}}}
+
+	DefCFUN = Def{
+		Name: "CFUN",
+		Desc: "Set phone functionality.",
+		Params: []Param{
+			{Name: "fun", Type: TypeInt, Min: 0, Max: 127},
+			{Name: "rst", Type: TypeInt, Min: 0, Max: 1, Optional: true},
+		},
+		Result: []Param{{Name: "fun", Type: TypeInt}},
+	}
+	DefCSQ = Def{
+		Name:   "CSQ",
+		Desc:   "Signal quality.",
+		Result: []Param{{Name: "rssi", Type: TypeInt}, {Name: "ber", Type: TypeInt}},
+	}
+	DefCREG = Def{
+		Name:   "CREG",
+		Desc:   "Network registration.",
+		Params: []Param{{Name: "n", Type: TypeInt, Min: 0, Max: 2}},
+		Result: []Param{
+			{Name: "n", Type: TypeInt},
+			{Name: "stat", Type: TypeInt},
+			{Name: "lac", Type: TypeString, Optional: true},
+			{Name: "ci", Type: TypeString, Optional: true},
+		},
+	}
+)
+
+// StandardDefs lists the predefined commands.
+var StandardDefs = []Def{DefCGMI, DefCGMM, DefCGMR, DefCGSN, DefCFUN, DefCSQ, DefCREG}
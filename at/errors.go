@@ -2,6 +2,58 @@ package at
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 )
 
 var ErrInvalidCommand = errors.New("invalid command")
+
+// CMEError is a mobile equipment error, reported as "+CME ERROR: <err>".
+//
+// Modems report either a numeric code or, in verbose mode, text. Code is
+// -1 when only text was given.
+type CMEError struct {
+	Code int
+	Text string
+}
+
+func (e *CMEError) Error() string {
+	if e.Code < 0 {
+		return "at: +CME ERROR: " + e.Text
+	}
+	return "at: +CME ERROR: " + strconv.Itoa(e.Code)
+}
+
+// CMSError is a message service (SMS) error, reported as "+CMS ERROR: <err>".
+//
+// Code is -1 when only text was given.
+type CMSError struct {
+	Code int
+	Text string
+}
+
+func (e *CMSError) Error() string {
+	if e.Code < 0 {
+		return "at: +CMS ERROR: " + e.Text
+	}
+	return "at: +CMS ERROR: " + strconv.Itoa(e.Code)
+}
+
+func parseErrValue(line, prefix string) (int, string) {
+	v := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	code, err := strconv.Atoi(v)
+	if err != nil {
+		return -1, v
+	}
+	return code, ""
+}
+
+func parseCMEError(line string) error {
+	code, text := parseErrValue(line, "+CME ERROR:")
+	return &CMEError{Code: code, Text: text}
+}
+
+func parseCMSError(line string) error {
+	code, text := parseErrValue(line, "+CMS ERROR:")
+	return &CMSError{Code: code, Text: text}
+}
@@ -6,6 +6,9 @@ type Response struct {
 	Data []string
 
 	OK bool
+
+	// err, if a *CMEError or *CMSError, is sent in place of ERROR.
+	err error
 }
 
 // SetValue sets the value of a response parameter.
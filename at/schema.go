@@ -0,0 +1,379 @@
+package at
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ParamType is the type of a command parameter.
+type ParamType uint8
+
+const (
+	// TypeInt is a decimal integer, stored in Values as an int.
+	TypeInt ParamType = iota
+
+	// TypeString is a string, quoted on the wire, stored in Values
+	// as a string.
+	TypeString
+)
+
+// Param describes a parameter of a command, or a field of its
+// information response.
+type Param struct {
+	Name string
+	Type ParamType
+
+	// Min and Max are the inclusive range of a TypeInt parameter. The
+	// range isn't checked if both are zero.
+	Min, Max int
+
+	// Optional parameters may be left empty, and are nil in Values.
+	Optional bool
+}
+
+// Def is a declarative command definition, shared by the server, to
+// validate and decode parameters, and the client, to encode them and
+// decode responses.
+type Def struct {
+	// Name is the command name without the "AT+", such as "CFUN".
+	Name string
+	Desc string
+
+	// Params are the parameters of the set command ("AT+NAME=...").
+	Params []Param
+
+	// Result are the fields of each information response line
+	// ("+NAME: ...") to a query or execute command.
+	Result []Param
+}
+
+// Values are decoded parameter values; an int for TypeInt, a string for
+// TypeString, or nil for an omitted optional parameter.
+type Values []interface{}
+
+// Int returns the value at i as an int, or 0 if it isn't one.
+func (v Values) Int(i int) int {
+	if i >= len(v) {
+		return 0
+	}
+	n, _ := v[i].(int)
+	return n
+}
+
+// Str returns the value at i as a string, or "" if it isn't one.
+func (v Values) Str(i int) string {
+	if i >= len(v) {
+		return ""
+	}
+	s, _ := v[i].(string)
+	return s
+}
+
+// ErrCommand is returned by Client.Call when the modem responds
+// with ERROR.
+var ErrCommand = errors.New("at: command failed")
+
+// DefFunc handles a defined command. The parameters of a set command
+// are decoded into args; args is nil for query and execute commands,
+// which c distinguishes.
+//
+// Returned rows are encoded as information response lines. A *CMEError or
+// *CMSError is sent as such, any other error as ERROR.
+type DefFunc func(c Cmd, args Values) ([]Values, error)
+
+// Help returns a usage line for the command, such as
+// "AT+CFUN=<fun>[,<rst>]".
+func (d Def) Help() string {
+	var b strings.Builder
+	b.WriteString("AT+" + strings.ToUpper(d.Name))
+	for i, p := range d.Params {
+		if i == 0 {
+			b.WriteString("=")
+		}
+		sep := ""
+		if i > 0 {
+			sep = ","
+		}
+		if p.Optional {
+			b.WriteString("[" + sep + "<" + p.Name + ">]")
+		} else {
+			b.WriteString(sep + "<" + p.Name + ">")
+		}
+	}
+	return b.String()
+}
+
+// testParams returns the response to a test command, listing the ranges
+// of the parameters.
+func (d Def) testParams() string {
+	parts := make([]string, len(d.Params))
+	for i, p := range d.Params {
+		switch {
+		case p.Type == TypeInt && (p.Min != 0 || p.Max != 0):
+			parts[i] = "(" + strconv.Itoa(p.Min) + "-" + strconv.Itoa(p.Max) + ")"
+		case p.Type == TypeString:
+			parts[i] = quote(p.Name)
+		default:
+			parts[i] = p.Name
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Define registers a handler for the set, query and execute forms of the
+// command described by d. Test commands are answered with the parameter
+// ranges.
+//
+// Set commands with invalid parameters get ERROR without calling h.
+func (s *Server) Define(d Def, h DefFunc) {
+	name := "AT+" + strings.ToUpper(d.Name)
+	fn := func(c Cmd) Response {
+		var args Values
+		if c.IsSet() {
+			var err error
+			args, err = decodeValues(d.Params, c.raw)
+			if err != nil {
+				return Response{}
+			}
+		}
+
+		rows, err := h(c, args)
+		if err != nil {
+			return Response{err: err}
+		}
+
+		resp := Response{OK: true}
+		for _, row := range rows {
+			line, err := encodeValues(d.Result, row)
+			if err != nil {
+				return Response{}
+			}
+			resp.Data = append(resp.Data, line)
+		}
+		return resp
+	}
+
+	meta := Handler{Func: fn, Params: d.testParams()}
+	s.Handle(name, meta)
+	s.Handle(name+"?", meta)
+	if len(d.Params) > 0 {
+		s.Handle(name+"=", meta)
+	}
+}
+
+// Call sends a command described by d, as a set command with args if d
+// has parameters or an execute command otherwise, and decodes the
+// information response lines.
+//
+// An ERROR result is returned as ErrCommand.
+func (c *Client) Call(ctx context.Context, d Def, args ...interface{}) ([]Values, error) {
+	name := strings.ToUpper(d.Name)
+	line := "AT+" + name
+	if len(d.Params) > 0 {
+		params, err := encodeValues(d.Params, args)
+		if err != nil {
+			return nil, err
+		}
+		line += "=" + params
+	} else if len(args) > 0 {
+		return nil, errors.New("at: " + name + ": too many parameters")
+	}
+
+	return c.call(ctx, d, line)
+}
+
+// CallQuery sends the query form of a command described by d, and decodes
+// the information response lines.
+func (c *Client) CallQuery(ctx context.Context, d Def) ([]Values, error) {
+	return c.call(ctx, d, "AT+"+strings.ToUpper(d.Name)+"?")
+}
+
+func (c *Client) call(ctx context.Context, d Def, line string) ([]Values, error) {
+	resp, err := c.Do(ctx, Command{Name: strings.ToUpper(d.Name), Line: line})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, ErrCommand
+	}
+
+	rows := make([]Values, 0, len(resp.Data))
+	for _, data := range resp.Data {
+		row, err := decodeValues(d.Result, data)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// encodeValues encodes vals as a comma-separated list of parameters.
+func encodeValues(params []Param, vals []interface{}) (string, error) {
+	if len(vals) > len(params) {
+		return "", errors.New("at: too many parameters")
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		var v interface{}
+		if i < len(vals) {
+			v = vals[i]
+		}
+		if v == nil {
+			if !p.Optional {
+				return "", errors.New("at: " + p.Name + ": missing parameter")
+			}
+			continue
+		}
+		if err := p.check(v); err != nil {
+			return "", err
+		}
+
+		switch v := v.(type) {
+		case int:
+			parts[i] = strconv.Itoa(v)
+		case string:
+			parts[i] = quote(v)
+		}
+	}
+
+	// omit trailing optional parameters
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// decodeValues decodes a comma-separated list of parameters.
+func decodeValues(params []Param, s string) (Values, error) {
+	fields, err := splitParams(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) > len(params) {
+		return nil, errors.New("at: too many parameters")
+	}
+
+	vals := make(Values, len(params))
+	for i, p := range params {
+		if i >= len(fields) || fields[i] == "" {
+			if !p.Optional {
+				return nil, errors.New("at: " + p.Name + ": missing parameter")
+			}
+			continue
+		}
+
+		switch p.Type {
+		case TypeInt:
+			n, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, errors.New("at: " + p.Name + ": invalid integer")
+			}
+			vals[i] = n
+		case TypeString:
+			str, err := unquote(fields[i])
+			if err != nil {
+				return nil, errors.New("at: " + p.Name + ": " + err.Error())
+			}
+			vals[i] = str
+		}
+		if err := p.check(vals[i]); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// check validates the type and range of v.
+func (p Param) check(v interface{}) error {
+	switch p.Type {
+	case TypeInt:
+		n, ok := v.(int)
+		if !ok {
+			return errors.New("at: " + p.Name + ": expected integer")
+		}
+		if (p.Min != 0 || p.Max != 0) && (n < p.Min || n > p.Max) {
+			return errors.New("at: " + p.Name + ": " + strconv.Itoa(n) + " out of range")
+		}
+	case TypeString:
+		if _, ok := v.(string); !ok {
+			return errors.New("at: " + p.Name + ": expected string")
+		}
+	}
+	return nil
+}
+
+// splitParams splits s on commas outside of quotes, trimming spaces.
+func splitParams(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	var inQuote bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == ',' && !inQuote:
+			fields = append(fields, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if inQuote {
+		return nil, errors.New("at: unterminated string")
+	}
+	return append(fields, strings.TrimSpace(s[start:])), nil
+}
+
+// quote returns s as a V.250 string constant, with quotes, backslashes and
+// control characters written as "\" and two hex digits.
+func quote(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			b.WriteByte('\\')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// unquote is the inverse of quote. Unquoted strings, as sent by many
+// modems for information text, are returned as-is.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s, nil
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", errors.New("invalid escape")
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", errors.New("invalid escape")
+		}
+		b.WriteByte(byte(v))
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,109 @@
+package at_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/mastercactapus/embedded/at"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defGreet = at.Def{
+	Name: "GREET",
+	Desc: "Greet someone.",
+	Params: []at.Param{
+		{Name: "name", Type: at.TypeString},
+		{Name: "times", Type: at.TypeInt, Min: 1, Max: 3, Optional: true},
+	},
+	Result: []at.Param{
+		{Name: "n", Type: at.TypeInt},
+		{Name: "msg", Type: at.TypeString},
+	},
+}
+
+func TestSchema(t *testing.T) {
+	host, dev := net.Pipe()
+	t.Cleanup(func() {
+		host.Close()
+		dev.Close()
+	})
+
+	srv := at.NewServer(dev, dev)
+	var last string
+	srv.Define(defGreet, func(c at.Cmd, args at.Values) ([]at.Values, error) {
+		if c.IsQuery() {
+			return []at.Values{{0, last}}, nil
+		}
+		if args.Str(0) == "nobody" {
+			return nil, &at.CMEError{Code: 50}
+		}
+
+		last = args.Str(0)
+		n := args.Int(1)
+		if args[1] == nil {
+			n = 1
+		}
+		var rows []at.Values
+		for i := 0; i < n; i++ {
+			rows = append(rows, at.Values{i, "hello, " + last})
+		}
+		return rows, nil
+	})
+	srv.Define(at.DefCSQ, func(c at.Cmd, args at.Values) ([]at.Values, error) {
+		return []at.Values{{20, 99}}, nil
+	})
+	go srv.Serve()
+
+	c := at.NewClient(host)
+	ctx := context.Background()
+
+	rows, err := c.Call(ctx, defGreet, `a "quoted", name`, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []at.Values{
+		{0, `hello, a "quoted", name`},
+		{1, `hello, a "quoted", name`},
+	}, rows)
+
+	rows, err = c.CallQuery(ctx, defGreet)
+	require.NoError(t, err)
+	assert.Equal(t, []at.Values{{0, `a "quoted", name`}}, rows)
+
+	rows, err = c.Call(ctx, at.DefCSQ)
+	require.NoError(t, err)
+	assert.Equal(t, []at.Values{{20, 99}}, rows)
+
+	// validated by the client
+	_, err = c.Call(ctx, defGreet, "bob", 4)
+	assert.Error(t, err)
+	_, err = c.Call(ctx, defGreet)
+	assert.Error(t, err)
+
+	// validated by the server
+	resp, err := c.Set("GREET", "bob", "4")
+	require.NoError(t, err)
+	assert.False(t, resp.OK)
+
+	_, err = c.Call(ctx, defGreet, "nobody")
+	var cme *at.CMEError
+	require.True(t, errors.As(err, &cme), "got %v", err)
+	assert.Equal(t, 50, cme.Code)
+
+	resp, err = c.Do(ctx, at.Command{Line: "AT+GREET=?"})
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+	assert.Equal(t, []string{`"name",(1-3)`}, resp.Data)
+
+	// with echo on, so the listing of AT+CLAC isn't mistaken for the echo
+	resp, err = c.Do(ctx, at.Command{Line: "ATE1"})
+	require.NoError(t, err)
+	require.True(t, resp.OK)
+	resp, err = c.Do(ctx, at.Command{Line: "AT+CLAC"})
+	require.NoError(t, err)
+	assert.True(t, resp.OK)
+	assert.Equal(t, []string{"AT+CLAC", "AT+CSQ", "AT+GREET"}, resp.Data)
+
+	assert.Equal(t, "AT+GREET=<name>[,<times>]", defGreet.Help())
+}
@@ -2,7 +2,11 @@ package at
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,41 +18,125 @@ import (
 //
 // Data sent will be attributed to the last command
 // received.
+//
+// Besides registered handlers, the server answers the V.250 basic
+// commands E (echo), V (verbose results), S-register reads and writes,
+// O (return to data mode) and H (hang up), as well as "AT+NAME=?" test
+// commands. See Connect for data mode.
 type Server struct {
-	s *bufio.Scanner
+	r io.Reader
 	w *bufio.Writer
 
 	idleDur time.Duration
 	t       *time.Timer
 	mx      sync.Mutex
 
-	h map[string]HandlerFunc
+	// wMx protects w and the settings below, so that URCs can be
+	// sent from any goroutine.
+	wMx     sync.Mutex
+	echo    bool
+	verbose bool
+	sreg    [256]byte
+
+	// data mode state, also protected by wMx
+	conn    io.ReadWriter
+	online  bool
+	connect bool
+	cond    *sync.Cond
+	lost    chan io.ReadWriter
+	done    chan struct{}
+
+	h map[string]Handler
 }
 
 type HandlerFunc func(Cmd) Response
 
+// Handler is a command handler along with its metadata.
+type Handler struct {
+	Func HandlerFunc
+
+	// Params lists the accepted parameter values, reported in
+	// response to a test command ("AT+NAME=?"), such as "(0-2),(0,1)".
+	Params string
+}
+
 // NewServer creates a new AT command server.
+//
+// Echo is off and results are verbose by default.
 func NewServer(r io.Reader, w io.Writer) *Server {
-	s := bufio.NewScanner(r)
-
-	s.Split(bufio.ScanLines)
-	return &Server{w: bufio.NewWriter(w), s: s}
+	srv := &Server{
+		w:       bufio.NewWriter(w),
+		r:       r,
+		verbose: true,
+		lost:    make(chan io.ReadWriter),
+		done:    make(chan struct{}),
+	}
+	srv.cond = sync.NewCond(&srv.wMx)
+	srv.sreg[2] = '+'
+	srv.sreg[3] = '\r'
+	srv.sreg[4] = '\n'
+	srv.sreg[5] = '\b'
+	srv.sreg[12] = 50
+	return srv
 }
 
 // HandleFunc registers a handler for a command.
 //
 // The command name is case insensitive, including the "AT+"
 func (s *Server) HandleFunc(name string, h HandlerFunc) {
+	s.Handle(name, Handler{Func: h})
+}
+
+// Handle registers a handler, with metadata, for a command.
+//
+// The command name is case insensitive, including the "AT+"
+func (s *Server) Handle(name string, h Handler) {
 	if s.h == nil {
-		s.h = make(map[string]HandlerFunc)
+		s.h = make(map[string]Handler)
 	}
 	name = strings.ToUpper(name)
-	if _, ok := s.h[strings.ToUpper(name)]; ok {
+	if _, ok := s.h[name]; ok {
 		panic("at: duplicate handler for " + name)
 	}
 	s.h[name] = h
 }
 
+// SReg returns the value of an S-register.
+func (s *Server) SReg(n uint8) byte {
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	return s.sreg[n]
+}
+
+// SetSReg sets the value of an S-register. S3 and S4 are used
+// to terminate responses.
+func (s *Server) SetSReg(n uint8, v byte) {
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	s.sreg[n] = v
+}
+
+// URC sends an unsolicited result code, such as "RING" or "+CREG: 1".
+//
+// It is safe to call from any goroutine, including handlers.
+func (s *Server) URC(line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return errors.New("at: URC cannot contain newlines")
+	}
+
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	if _, err := io.WriteString(s.w, line+s.eol()); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// eol returns the response line terminator. wMx must be held.
+func (s *Server) eol() string {
+	return string([]byte{s.sreg[3], s.sreg[4]})
+}
+
 // OnIdle sets a handler to be called when the server
 // has been idle for the specified duration.
 //
@@ -70,66 +158,337 @@ func (s *Server) OnIdle(dur time.Duration, h func()) {
 
 // Serve serves AT commands.
 func (s *Server) Serve() error {
-	var c Cmd
-	for s.s.Scan() {
-		var isSet bool
-		var params string
-		c.FullName, params, isSet = strings.Cut(s.s.Text(), "=")
-		c.FullName = strings.ToUpper(c.FullName)
-		c.FullName = strings.TrimSpace(c.FullName)
-		if isSet {
-			c.FullName += "="
-			c.Params = strings.Split(params, ",")
-			for i := range c.Params {
-				c.Params[i] = UnescapeString(c.Params[i], ',')
+	defer close(s.done)
+
+	rx := make(chan rxChunk)
+	go s.readLoop(rx)
+
+	var line []byte
+	var cr bool // the last byte was S3
+	var d dataState
+	for {
+		var ch rxChunk
+		select {
+		case ch = <-rx:
+		case <-d.escC:
+			// guard time passed after the escape sequence
+			d = dataState{}
+			s.wMx.Lock()
+			s.online = false
+			s.wMx.Unlock()
+			if err := s.respond("", Response{OK: true}); err != nil {
+				return err
+			}
+			continue
+		case conn := <-s.lost:
+			if err := s.hangup(conn); err != nil {
+				return err
 			}
+			continue
 		}
 
-		if s.t != nil {
-			s.t.Reset(s.idleDur)
+		p := ch.data
+		for len(p) > 0 {
+			if s.online {
+				if err := d.write(s, p, ch.t); err != nil {
+					return err
+				}
+				break
+			}
+
+			b := p[0]
+			p = p[1:]
+			switch {
+			case b == s.SReg(3):
+				cr = true
+			case b == '\n' && cr:
+				cr = false
+				continue
+			case b == '\n':
+				// a lone LF also ends the line
+			default:
+				cr = false
+				line = append(line, b)
+				continue
+			}
+
+			err := s.handleLine(string(line))
+			line = line[:0]
+			if err != nil {
+				return err
+			}
+			if s.online {
+				d = dataState{last: time.Now(), skipLF: true}
+			}
+		}
+
+		if ch.err == nil {
+			continue
+		}
+		if len(line) > 0 && !s.online {
+			if err := s.handleLine(string(line)); err != nil {
+				return err
+			}
+		}
+		if ch.err == io.EOF {
+			return io.ErrUnexpectedEOF
 		}
+		return ch.err
+	}
+}
 
-		var resp Response
-		if c.FullName == "" || c.Name() == "" {
-			resp.OK = true
-		} else if h, ok := s.h[c.FullName]; ok {
-			s.mx.Lock()
-			resp = h(c)
-			s.mx.Unlock()
-		} else {
-			resp.SetValue("ERROR", "unknown command")
+type rxChunk struct {
+	data []byte
+	t    time.Time
+	err  error
+}
+
+// readLoop reads from r, timestamping each read for guard time detection.
+func (s *Server) readLoop(rx chan<- rxChunk) {
+	for {
+		buf := make([]byte, 256)
+		n, err := s.r.Read(buf)
+		select {
+		case rx <- rxChunk{data: buf[:n], t: time.Now(), err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			return
 		}
+	}
+}
 
-		if err := s.respond(c, resp); err != nil {
-			return err
+func (s *Server) handleLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	if s.t != nil {
+		s.t.Reset(s.idleDur)
+	}
+
+	s.wMx.Lock()
+	if s.echo {
+		io.WriteString(s.w, line+s.eol())
+	}
+	s.wMx.Unlock()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.exec(line)
+}
+
+func parseCmd(line string) Cmd {
+	var c Cmd
+	var isSet bool
+	var params string
+	c.FullName, params, isSet = strings.Cut(line, "=")
+	c.FullName = strings.ToUpper(c.FullName)
+	c.FullName = strings.TrimSpace(c.FullName)
+	if isSet {
+		c.FullName += "="
+		c.raw = params
+		c.Params = strings.Split(params, ",")
+		for i := range c.Params {
+			c.Params[i] = UnescapeString(c.Params[i], ',')
+		}
+	}
+	return c
+}
+
+func (s *Server) exec(line string) error {
+	c := parseCmd(line)
+	prefix := "+" + c.Name() + ": "
+
+	var resp Response
+	if c.FullName == "" || c.Name() == "" {
+		resp.OK = true
+	} else if c.IsSet() && len(c.Params) == 1 && c.Params[0] == "?" {
+		resp = s.test(c)
+	} else if h, ok := s.h[c.FullName]; ok {
+		resp = h.Func(c)
+	} else if c.FullName == "AT+CLAC" {
+		resp = s.list()
+		prefix = ""
+	} else if isBasic(c.FullName) {
+		resp = s.basic(strings.ToUpper(line[2:]))
+		prefix = ""
+	} else {
+		resp.SetValue("ERROR", "unknown command")
+	}
+
+	return s.respond(prefix, resp)
+}
+
+// test answers a test command using the metadata of any handler for the command.
+func (s *Server) test(c Cmd) Response {
+	base := strings.TrimSuffix(c.FullName, "=")
+
+	var resp Response
+	for _, name := range []string{base + "=", base, base + "?"} {
+		h, ok := s.h[name]
+		if !ok {
+			continue
+		}
+		if h.Params != "" {
+			resp.Data = append(resp.Data, h.Params)
+		}
+		resp.OK = true
+		return resp
+	}
+
+	resp.SetValue("ERROR", "unknown command")
+	return resp
+}
+
+// list answers AT+CLAC with the supported extended commands.
+func (s *Server) list() Response {
+	seen := map[string]bool{"AT+CLAC": true}
+	for name := range s.h {
+		name = strings.TrimRight(name, "=?")
+		if strings.HasPrefix(name, "AT+") {
+			seen[name] = true
+		}
+	}
+
+	resp := Response{OK: true}
+	for name := range seen {
+		resp.Data = append(resp.Data, name)
+	}
+	sort.Strings(resp.Data)
+	return resp
+}
+
+// isBasic returns true if name is a line of V.250 basic commands, such
+// as "ATE0V1".
+func isBasic(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "AT") && name[2] != '+'
+}
+
+// basic executes a sequence of basic commands.
+func (s *Server) basic(cmds string) Response {
+	var resp Response
+
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	for len(cmds) > 0 {
+		c := cmds[0]
+		cmds = cmds[1:]
+
+		var n int
+		var ok bool
+		switch c {
+		case ' ':
+			continue
+		case 'E', 'V':
+			n, cmds, _ = parseNum(cmds)
+			if n > 1 {
+				return resp
+			}
+			if c == 'E' {
+				s.echo = n == 1
+			} else {
+				s.verbose = n == 1
+			}
+		case 'S':
+			n, cmds, ok = parseNum(cmds)
+			if !ok || n > 255 {
+				return resp
+			}
+			switch {
+			case strings.HasPrefix(cmds, "?"):
+				cmds = cmds[1:]
+				resp.Data = append(resp.Data, fmt.Sprintf("%03d", s.sreg[n]))
+			case strings.HasPrefix(cmds, "="):
+				var v int
+				v, cmds, ok = parseNum(cmds[1:])
+				if !ok || v > 255 {
+					return resp
+				}
+				s.sreg[n] = byte(v)
+			default:
+				return resp
+			}
+		case 'O':
+			n, cmds, _ = parseNum(cmds)
+			if n != 0 || s.conn == nil {
+				return resp
+			}
+			s.connect = true
+		case 'H':
+			n, cmds, _ = parseNum(cmds)
+			if n != 0 {
+				return resp
+			}
+			s.drop()
+		default:
+			return resp
 		}
 	}
-	if s.s.Err() != nil {
-		return s.s.Err()
+
+	resp.OK = true
+	return resp
+}
+
+// parseNum parses leading decimal digits from s, returning 0 if there are none.
+func parseNum(s string) (int, string, bool) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, s, false
 	}
 
-	return io.ErrUnexpectedEOF
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return 0, s, false
+	}
+	return n, s[i:], true
 }
 
-func (s *Server) respond(c Cmd, resp Response) error {
+// respond writes the response. Data lines are prefixed with prefix.
+func (s *Server) respond(prefix string, resp Response) error {
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+
+	eol := s.eol()
 	for _, data := range resp.Data {
 		if strings.ContainsRune(data, '\n') {
 			panic("at: data cannot contain newlines")
 		}
 
-		if _, err := io.WriteString(s.w, "+"+c.Name()+": "+data+"\r\n"); err != nil {
+		if _, err := io.WriteString(s.w, prefix+data+eol); err != nil {
 			return err
 		}
 	}
 
-	if resp.OK {
-		if _, err := io.WriteString(s.w, "OK\r\n"); err != nil {
-			return err
-		}
-	} else {
-		if _, err := io.WriteString(s.w, "ERROR\r\n"); err != nil {
-			return err
-		}
+	var result string
+	var cme *CMEError
+	var cms *CMSError
+	switch {
+	case errors.As(resp.err, &cme) && cme.Code < 0:
+		result = "+CME ERROR: " + cme.Text + eol
+	case errors.As(resp.err, &cme):
+		result = "+CME ERROR: " + strconv.Itoa(cme.Code) + eol
+	case errors.As(resp.err, &cms) && cms.Code < 0:
+		result = "+CMS ERROR: " + cms.Text + eol
+	case errors.As(resp.err, &cms):
+		result = "+CMS ERROR: " + strconv.Itoa(cms.Code) + eol
+	case resp.OK && s.connect:
+		result = s.result(resultConnect)
+		s.online = true
+		s.cond.Broadcast()
+	case resp.OK:
+		result = s.result(resultOK)
+	default:
+		result = s.result(resultError)
+	}
+	s.connect = false
+	if _, err := io.WriteString(s.w, result); err != nil {
+		return err
 	}
 
 	return s.w.Flush()
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"io"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mastercactapus/embedded/at"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServ(t *testing.T) {
@@ -28,3 +31,80 @@ func TestServ(t *testing.T) {
 
 	assert.Equal(t, "+TEST: =BAR\r\nOK\r\n", buf.String())
 }
+
+func TestServer_Basic(t *testing.T) {
+	var buf bytes.Buffer
+	s := at.NewServer(strings.NewReader("ATE1\r\nATS0=5\r\nats0?\r\nATV0\r\nATX\r\nATS3=10V1\r\n"), &buf)
+
+	err := s.Serve()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, byte(10), s.SReg(3))
+	assert.Equal(t, byte(5), s.SReg(0))
+
+	assert.Equal(t, ""+
+		"OK\r\n"+
+		"ATS0=5\r\nOK\r\n"+
+		"ats0?\r\n005\r\nOK\r\n"+
+		"ATV0\r\n0\r"+
+		"ATX\r\n4\r"+
+		"ATS3=10V1\r\nOK\n\n",
+		buf.String())
+}
+
+func TestServer_LF(t *testing.T) {
+	var buf bytes.Buffer
+	s := at.NewServer(strings.NewReader("AT+PING\nAT+PING\r\nAT+PING\r"), &buf)
+	s.HandleFunc("AT+PING", func(at.Cmd) at.Response { return at.Response{OK: true} })
+
+	err := s.Serve()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "OK\r\nOK\r\nOK\r\n", buf.String())
+}
+
+func TestServer_Test(t *testing.T) {
+	var buf bytes.Buffer
+	s := at.NewServer(strings.NewReader("AT+FOO=?\r\nAT+BAR=?\r\nAT+BAZ=?\r\n"), &buf)
+	s.Handle("AT+FOO=", at.Handler{
+		Params: "(0-2)",
+		Func:   func(at.Cmd) at.Response { return at.Response{OK: true} },
+	})
+	s.HandleFunc("AT+BAR", func(at.Cmd) at.Response { return at.Response{OK: true} })
+
+	err := s.Serve()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "+FOO: (0-2)\r\nOK\r\nOK\r\n+BAZ: ERROR=unknown command\r\nERROR\r\n", buf.String())
+}
+
+func TestServer_URC(t *testing.T) {
+	pr, pw := io.Pipe()
+	var buf bytes.Buffer
+	var mx sync.Mutex
+	s := at.NewServer(pr, writerFunc(func(p []byte) (int, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		return buf.Write(p)
+	}))
+	s.HandleFunc("AT+DIAL", func(at.Cmd) at.Response {
+		go s.URC("NO CARRIER")
+		return at.Response{OK: true}
+	})
+	done := make(chan error)
+	go func() { done <- s.Serve() }()
+
+	require.NoError(t, s.URC("RING"))
+	assert.Error(t, s.URC("RING\r\nRING"))
+	_, err := io.WriteString(pw, "AT+DIAL\r\n")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return buf.String() == "RING\r\nOK\r\nNO CARRIER\r\n"
+	}, time.Second, time.Millisecond)
+
+	pw.Close()
+	assert.ErrorIs(t, <-done, io.ErrUnexpectedEOF)
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) { return fn(p) }
@@ -0,0 +1,187 @@
+package bustool
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mastercactapus/embedded/at"
+	"github.com/mastercactapus/embedded/term"
+)
+
+// AddAT adds a subshell to talk to an AT modem. Commands in defs, along
+// with at.StandardDefs, can be called with typed parameters.
+func AddAT(sh *term.Shell, c *at.Client, defs ...at.Def) *term.Shell {
+	defs = append(append([]at.Def{}, at.StandardDefs...), defs...)
+	find := func(name string) (at.Def, bool) {
+		name = strings.TrimPrefix(strings.ToUpper(name), "AT+")
+		// later definitions override the standard ones
+		for i := len(defs) - 1; i >= 0; i-- {
+			if strings.EqualFold(defs[i].Name, name) {
+				return defs[i], true
+			}
+		}
+		return at.Def{}, false
+	}
+
+	atSh := sh.NewSubShell("at", "Interact with an AT command modem.", func(r term.RunArgs) error {
+		timeout := r.Int(term.Flag{Name: "timeout", Short: 't', Def: "1000", Desc: "Command timeout in milliseconds."})
+		if err := r.Parse(); err != nil {
+			return err
+		}
+		r.Set("at.timeout", time.Duration(*timeout)*time.Millisecond)
+		return nil
+	})
+	ctx := func(r term.RunArgs) (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), r.Get("at.timeout").(time.Duration))
+	}
+
+	atSh.AddCommand("send", "Send a raw command line.", func(r term.RunArgs) error {
+		r.SetHelpParameters("<line>")
+		if err := r.Parse(); err != nil {
+			return err
+		}
+		line := strings.Join(r.Args(), " ")
+		if line == "" {
+			return r.UsageError("command line is required")
+		}
+
+		ctx, cancel := ctx(r)
+		defer cancel()
+		resp, err := c.Do(ctx, at.Command{Line: line})
+		if err != nil {
+			return err
+		}
+		for _, data := range resp.Data {
+			r.Println(data)
+		}
+		if !resp.OK {
+			return at.ErrCommand
+		}
+		r.Println("OK")
+		return nil
+	})
+
+	atSh.AddCommand("list", "List commands supported by the modem.", func(r term.RunArgs) error {
+		if err := r.Parse(); err != nil {
+			return err
+		}
+
+		ctx, cancel := ctx(r)
+		defer cancel()
+		resp, err := c.ExecuteContext(ctx, "CLAC")
+		if err != nil {
+			return err
+		}
+		if !resp.OK {
+			return at.ErrCommand
+		}
+		for _, name := range resp.Data {
+			if d, ok := find(name); ok {
+				r.Printf("%-24s %s\n", d.Help(), d.Desc)
+				continue
+			}
+			r.Println(name)
+		}
+		return nil
+	})
+
+	atSh.AddCommand("call", "Call a known command with typed parameters.", func(r term.RunArgs) error {
+		query := r.Bool(term.Flag{Name: "query", Short: 'q', Desc: "Send the query form of the command."})
+		r.SetHelpParameters("<name> [params...]")
+		if err := r.Parse(); err != nil {
+			return err
+		}
+		if len(r.Args()) == 0 {
+			return r.UsageError("command name is required")
+		}
+		d, ok := find(r.Arg(0))
+		if !ok {
+			return r.UsageError("unknown command '%s'", r.Arg(0))
+		}
+
+		args := make([]interface{}, len(r.Args())-1)
+		for i, arg := range r.Args()[1:] {
+			if i >= len(d.Params) {
+				return r.UsageError("usage: %s", d.Help())
+			}
+			switch {
+			case arg == "":
+				// omitted
+			case d.Params[i].Type == at.TypeInt:
+				n, err := term.ParseInt(arg)
+				if err != nil {
+					return err
+				}
+				args[i] = n
+			default:
+				args[i] = arg
+			}
+		}
+
+		ctx, cancel := ctx(r)
+		defer cancel()
+		var rows []at.Values
+		var err error
+		if *query {
+			rows, err = c.CallQuery(ctx, d)
+		} else {
+			rows, err = c.Call(ctx, d, args...)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			for i, v := range row {
+				name := strconv.Itoa(i)
+				if i < len(d.Result) {
+					name = d.Result[i].Name
+				}
+				switch v := v.(type) {
+				case int:
+					r.Printf("%s=%d ", name, v)
+				case string:
+					r.Printf("%s=\"%s\" ", name, v)
+				}
+			}
+			r.Println()
+		}
+		return nil
+	})
+
+	atSh.AddCommand("describe", "Describe a known command.", func(r term.RunArgs) error {
+		r.SetHelpParameters("<name>")
+		if err := r.Parse(); err != nil {
+			return err
+		}
+		d, ok := find(r.Arg(0))
+		if !ok {
+			return r.UsageError("unknown command '%s'", r.Arg(0))
+		}
+
+		r.Println(d.Help())
+		r.Println("  " + d.Desc)
+		for _, p := range d.Params {
+			r.Printf("  <%s> %s", p.Name, typeName(p))
+			if p.Optional {
+				r.Print(" (optional)")
+			}
+			r.Println()
+		}
+		return nil
+	})
+
+	return atSh
+}
+
+func typeName(p at.Param) string {
+	if p.Type == at.TypeString {
+		return "string"
+	}
+	if p.Min != 0 || p.Max != 0 {
+		return "integer " + strconv.Itoa(p.Min) + "-" + strconv.Itoa(p.Max)
+	}
+	return "integer"
+}
@@ -0,0 +1,69 @@
+package bustool
+
+import (
+	"os"
+
+	"github.com/mastercactapus/embedded/term"
+	"github.com/mastercactapus/embedded/xb"
+)
+
+var triggers = map[string]xb.Trigger{
+	"none":    xb.TriggerNone,
+	"high":    xb.TriggerHigh,
+	"low":     xb.TriggerLow,
+	"rising":  xb.TriggerRising,
+	"falling": xb.TriggerFalling,
+}
+
+// AddCapture adds a command to run a logic-analyzer capture on an xb device.
+func AddCapture(sh *term.Shell, c *xb.Client) {
+	sh.AddCommand("capture", "Capture pin states to a VCD or sigrok file.", func(r term.RunArgs) error {
+		pins := r.Bytes(term.Flag{Name: "pins", Short: 'p', Desc: "Pins to sample (comma separated).", Req: true})
+		rate := r.Int(term.Flag{Name: "rate", Short: 'r', Def: "10000", Desc: "Sample rate in Hz."})
+		samples := r.Int(term.Flag{Name: "samples", Short: 'n', Def: "1000", Desc: "Number of samples."})
+		pre := r.Int(term.Flag{Name: "pre", Def: "0", Desc: "Number of samples to keep from before the trigger."})
+		trig := r.Enum(term.Flag{Name: "trigger", Short: 't', Def: "none", Desc: "Trigger condition."}, "none", "high", "low", "rising", "falling")
+		trigPin := r.Int(term.Flag{Name: "trigger-pin", Short: 'T', Def: "0", Desc: "Trigger pin."})
+		timeout := r.Int(term.Flag{Name: "timeout", Def: "1000", Desc: "Trigger timeout in milliseconds."})
+		format := r.Enum(term.Flag{Name: "format", Short: 'f', Def: "vcd", Desc: "Output format."}, "vcd", "sr")
+		out := r.String(term.Flag{Name: "out", Short: 'o', Desc: "Output file.", Req: true})
+		if err := r.Parse(); err != nil {
+			return err
+		}
+
+		cfg := xb.CaptureConfig{
+			Rate:       uint32(*rate),
+			Samples:    uint16(*samples),
+			PreTrigger: uint16(*pre),
+			Trigger:    triggers[*trig],
+			TriggerPin: uint8(*trigPin),
+			Timeout:    uint32(*timeout),
+		}
+		for _, p := range *pins {
+			cfg.Mask |= 1 << p
+		}
+
+		res, err := c.Capture(cfg)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if *format == "sr" {
+			err = res.WriteSigrok(f)
+		} else {
+			err = res.WriteVCD(f)
+		}
+		if err != nil {
+			return err
+		}
+
+		r.Printf("Wrote %d samples at %d Hz to %s\n", res.Len(), res.Rate, *out)
+		return f.Close()
+	})
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/mastercactapus/embedded/term"
 )
 
-func AddI2C(sh *term.Shell, bus *i2c.I2C) *term.Shell {
+func AddI2C(sh *term.Shell, bus i2c.Bus) *term.Shell {
 	i2cSh := sh.NewSubShell("i2c", "Interact with I2C devices.", func(r term.RunArgs) error {
 		if err := r.Parse(); err != nil {
 			return err
@@ -29,7 +29,10 @@ var i2cCommands = []term.Command{
 			return err
 		}
 
-		bus := r.Get("i2c").(*i2c.I2C)
+		bus, ok := r.Get("i2c").(*i2c.I2C)
+		if !ok {
+			return i2c.ErrUnsupported
+		}
 
 		if *write {
 			return bus.PingW(*addr)
@@ -43,11 +46,15 @@ var i2cCommands = []term.Command{
 			return err
 		}
 
-		bus := r.Get("i2c").(*i2c.I2C)
+		bus := r.Get("i2c").(i2c.Bus)
+		res, err := i2c.Scan(bus)
+		if err != nil {
+			return err
+		}
 
-		for i := 0; i < 127; i++ {
-			canRead := bus.Ping(byte(i)) == nil
-			canWrite := bus.PingW(byte(i)) == nil
+		for i := byte(0); i < 127; i++ {
+			canRead := res.CanRead(i)
+			canWrite := res.CanWrite(i)
 			if !canRead && !canWrite {
 				continue
 			}
@@ -62,9 +69,11 @@ var i2cCommands = []term.Command{
 				r.Printf("WO")
 			}
 
-			id, err := bus.DeviceID(byte(i))
-			if err == nil {
-				r.Printf(" ID=%x", id)
+			if b, ok := bus.(*i2c.I2C); ok {
+				id, err := b.DeviceID(i)
+				if err == nil {
+					r.Printf(" ID=%x", id)
+				}
 			}
 			r.Println()
 		}
@@ -72,6 +81,19 @@ var i2cCommands = []term.Command{
 		return nil
 	}},
 
+	{Name: "recover", Desc: "Attempt to free a stuck bus.", Exec: func(r term.RunArgs) error {
+		if err := r.Parse(); err != nil {
+			return err
+		}
+
+		rec, ok := r.Get("i2c").(i2c.Recoverer)
+		if !ok {
+			return i2c.ErrUnsupported
+		}
+
+		return rec.Recover()
+	}},
+
 	{Name: "w", Desc: "Write to an I2C device register.", Exec: func(r term.RunArgs) error {
 		addr := r.Byte(term.Flag{Name: "dev", Short: 'd', Env: "DEV", Desc: "Device addresss.", Req: true})
 		reg := r.Byte(term.Flag{Name: "reg", Short: 'r', Def: "0", Env: "REG", Desc: "Register address."})
@@ -80,8 +102,12 @@ var i2cCommands = []term.Command{
 			return err
 		}
 
-		bus := r.Get("i2c").(*i2c.I2C)
-		return bus.WriteRegister(*addr, *reg, *data)
+		if bus, ok := r.Get("i2c").(*i2c.I2C); ok {
+			return bus.WriteRegister(*addr, *reg, *data)
+		}
+
+		bus := r.Get("i2c").(i2c.Bus)
+		return bus.Tx(uint16(*addr), append([]byte{*reg}, *data...), nil)
 	}},
 
 	{Name: "r", Desc: "Read from an I2C device register.", Exec: func(r term.RunArgs) error {
@@ -92,10 +118,13 @@ var i2cCommands = []term.Command{
 			return err
 		}
 
-		bus := r.Get("i2c").(*i2c.I2C)
-
 		data := make([]byte, *count)
-		err := bus.ReadRegister(*addr, *reg, data)
+		var err error
+		if bus, ok := r.Get("i2c").(*i2c.I2C); ok {
+			err = bus.ReadRegister(*addr, *reg, data)
+		} else {
+			err = r.Get("i2c").(i2c.Bus).Tx(uint16(*addr), []byte{*reg}, data)
+		}
 		if err != nil {
 			return err
 		}
@@ -112,7 +141,7 @@ var i2cCommands = []term.Command{
 			return err
 		}
 
-		bus := r.Get("i2c").(*i2c.I2C)
+		bus := r.Get("i2c").(i2c.Bus)
 
 		var rData []byte
 		if *count > 0 {
@@ -1,16 +1,21 @@
 package bustool
 
 import (
+	"os"
+
 	"github.com/mastercactapus/embedded/driver"
 	"github.com/mastercactapus/embedded/driver/ioexp"
 	"github.com/mastercactapus/embedded/serial/i2c"
 	"github.com/mastercactapus/embedded/term"
+	"github.com/mastercactapus/embedded/term/ascii"
 )
 
 func AddIO(sh *term.Shell) *term.Shell {
 	ioSh := sh.NewSubShell("io", "Interact with IO expansion chips over I2C.", func(r term.RunArgs) error {
-		addr := r.Uint16(term.Flag{Name: "addr", Short: 'd', Def: "0x20", Env: "DEV", Desc: "Device addresss.", Req: true})
-		devType := r.Enum(term.Flag{Name: "type", Short: 't', Def: "mcp16", Desc: "IO device type.", Req: true}, "pcf", "mcp8", "mcp16")
+		addr := r.Uint16(term.Flag{Name: "addr", Short: 'd', Def: "0", Env: "DEV", Desc: "Device addresss, or 0 for the device type default.", Req: true})
+		devType := r.Enum(term.Flag{Name: "type", Short: 't', Def: "mcp16", Desc: "IO device type.", Req: true}, "pcf", "pcf16", "mcp8", "mcp16", "pca16", "pwm")
+		freq := r.Int(term.Flag{Name: "freq", Short: 'f', Def: "1000", Desc: "PWM frequency in Hz, for pwm devices."})
+		board := r.String(term.Flag{Name: "board", Short: 'b', Env: "BOARD", Desc: "Board definition file, naming pins."})
 		if err := r.Parse(); err != nil {
 			return err
 		}
@@ -25,9 +30,34 @@ func AddIO(sh *term.Shell) *term.Shell {
 			dev = ioexp.NewMCP23008(bus, *addr)
 		case "pcf":
 			dev = ioexp.NewPCF8574(bus, *addr)
+		case "pcf16":
+			dev = ioexp.NewPCF8575(bus, *addr)
+		case "pca16":
+			dev = ioexp.NewPCA9555(bus, *addr)
+		case "pwm":
+			if *freq <= 0 {
+				return r.UsageError("frequency must be positive")
+			}
+			pwm := ioexp.NewPCA9685(bus, *addr)
+			if err := pwm.Configure(uint32(*freq)); err != nil {
+				return err
+			}
+			dev = pwm
 		default:
 			return r.UsageError("unsupported device type '%s'", *devType)
 		}
+
+		if *board != "" {
+			f, err := os.Open(*board)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			dev, err = driver.LoadBoard(dev, f)
+			if err != nil {
+				return err
+			}
+		}
 		r.Set("io", dev)
 
 		return nil
@@ -37,6 +67,40 @@ func AddIO(sh *term.Shell) *term.Shell {
 	return ioSh
 }
 
+// ioPin parses a pin number, or a pin name if a board definition is loaded.
+func ioPin(dev driver.Pinner, arg string) (int, error) {
+	if m, ok := dev.(*driver.PinMap); ok {
+		if n, ok := m.Lookup(arg); ok {
+			return n, nil
+		}
+	}
+	n, err := term.ParseInt(arg)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n >= dev.PinCount() {
+		return 0, ascii.Errorf("pin %d out of range", n)
+	}
+	return n, nil
+}
+
+// readNamed prints the state of each pin, with its name.
+func readNamed(r term.RunArgs, m *driver.PinMap) error {
+	for i := 0; i < m.PinCount(); i++ {
+		v, err := m.Pin(i).Get()
+		if err != nil {
+			return err
+		}
+		val := "0"
+		if v {
+			val = "1"
+		}
+		r.Printf("% 3d ", i)
+		r.Println(val + " " + m.Name(i))
+	}
+	return nil
+}
+
 var ioCommands = []term.Command{
 	{Name: "r", Desc: "Read pin state.", Exec: func(r term.RunArgs) error {
 		if err := r.Parse(); err != nil {
@@ -44,6 +108,9 @@ var ioCommands = []term.Command{
 		}
 
 		dev := r.Get("io").(driver.Pinner)
+		if m, ok := dev.(*driver.PinMap); ok {
+			return readNamed(r, m)
+		}
 
 		for i := 0; i < dev.PinCount(); i++ {
 			r.Printf("% 3d ", i)
@@ -72,7 +139,7 @@ var ioCommands = []term.Command{
 
 		dev := r.Get("io").(driver.Pinner)
 		for _, arg := range r.Args() {
-			i, err := term.ParseInt(arg)
+			i, err := ioPin(dev, arg)
 			if err != nil {
 				return err
 			}
@@ -91,7 +158,7 @@ var ioCommands = []term.Command{
 
 		dev := r.Get("io").(driver.Pinner)
 		for _, arg := range r.Args() {
-			i, err := term.ParseInt(arg)
+			i, err := ioPin(dev, arg)
 			if err != nil {
 				return err
 			}
@@ -110,7 +177,7 @@ var ioCommands = []term.Command{
 
 		dev := r.Get("io").(driver.Pinner)
 		for _, arg := range r.Args() {
-			i, err := term.ParseInt(arg)
+			i, err := ioPin(dev, arg)
 			if err != nil {
 				return err
 			}
@@ -129,7 +196,7 @@ var ioCommands = []term.Command{
 
 		dev := r.Get("io").(driver.Pinner)
 		for _, arg := range r.Args() {
-			i, err := term.ParseInt(arg)
+			i, err := ioPin(dev, arg)
 			if err != nil {
 				return err
 			}
@@ -140,4 +207,80 @@ var ioCommands = []term.Command{
 
 		return nil
 	}},
+
+	{Name: "pwm", Desc: "Set PWM duty cycle (0-100%) of a pin.", Exec: func(r term.RunArgs) error {
+		freq := r.Int(term.Flag{Name: "freq", Short: 'f', Desc: "PWM frequency in Hz, or 0 to keep the current frequency."})
+		r.SetHelpParameters("<pin> <duty>")
+		if err := r.Parse(); err != nil {
+			return err
+		}
+		if len(r.Args()) != 2 {
+			return r.UsageError("expected pin and duty cycle")
+		}
+		dev := r.Get("io").(driver.Pinner)
+		n, err := ioPin(dev, r.Arg(0))
+		if err != nil {
+			return err
+		}
+		pct, err := term.ParseInt(r.Arg(1))
+		if err != nil {
+			return err
+		}
+		if pct < 0 || pct > 100 {
+			return r.UsageError("duty cycle must be 0-100")
+		}
+		if *freq < 0 {
+			return r.UsageError("frequency must not be negative")
+		}
+
+		pwm, ok := dev.Pin(n).(driver.PWMPin)
+		if !ok {
+			return driver.ErrNotSupported
+		}
+		return pwm.SetPWM(uint32(*freq), uint16(pct*0xffff/100))
+	}},
+
+	{Name: "modes", Desc: "List the modes supported by each pin.", Exec: func(r term.RunArgs) error {
+		if err := r.Parse(); err != nil {
+			return err
+		}
+
+		dev := r.Get("io").(driver.Pinner)
+		for i := 0; i < dev.PinCount(); i++ {
+			r.Printf("% 3d ", i)
+			r.Println(driver.PinModes(dev, i).String())
+		}
+		return nil
+	}},
+
+	{Name: "mode", Desc: "Set the mode of selected pin(s).", Exec: func(r term.RunArgs) error {
+		r.SetHelpParameters("<mode> <pin>...")
+		if err := r.Parse(); err != nil {
+			return err
+		}
+		if len(r.Args()) < 2 {
+			return r.UsageError("expected mode and pins")
+		}
+		mode, err := driver.ParseMode(r.Arg(0))
+		if err != nil {
+			return r.UsageError("%s", err.Error())
+		}
+
+		dev := r.Get("io").(driver.Pinner)
+		for _, arg := range r.Args()[1:] {
+			i, err := ioPin(dev, arg)
+			if err != nil {
+				return err
+			}
+			mp, ok := dev.Pin(i).(driver.ModePin)
+			if !ok {
+				return driver.ErrNotSupported
+			}
+			if err := mp.SetMode(mode); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}},
 }
@@ -11,7 +11,8 @@ import (
 
 func main() {
 	sh := bustool.NewShell(os.Stdin, os.Stdout)
-	bus := i2c.New(i2c.NewSoftController(nilPin(false), nilPin(false)))
+	// no devices, so every address NACKs
+	bus := i2c.New(i2c.NewSoftController(nilPin{}, nilPin{}))
 	i2cSh := bustool.AddI2C(sh, bus)
 	bustool.AddMem(i2cSh)
 	bustool.AddIO(i2cSh)
@@ -1,7 +1,13 @@
 package main
 
-type nilPin bool
+// nilPin is a bus line with nothing attached but a pull-up, so it always
+// reads high and ignores everything written.
+type nilPin struct{}
 
-func (p nilPin) Get() bool { return bool(p) }
-func (nilPin) OutputLow()  {}
-func (nilPin) PullupHigh() {}
+func (nilPin) Get() (bool, error)  { return true, nil }
+func (nilPin) Set(bool) error      { return nil }
+func (nilPin) High() error         { return nil }
+func (nilPin) Low() error          { return nil }
+func (nilPin) SetInput(bool) error { return nil }
+func (nilPin) Input() error        { return nil }
+func (nilPin) Output() error       { return nil }
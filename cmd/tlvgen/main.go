@@ -0,0 +1,37 @@
+// Command tlvgen generates TLV encoding methods for xb message structs.
+//
+// It is intended to be run with go generate:
+//
+//	//go:generate go run ../cmd/tlvgen -type Request,Response -o messages_tlv.go
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mastercactapus/embedded/xb/tlv/tlvgen"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct types")
+	out := flag.String("o", "tlv_gen.go", "output file")
+	dir := flag.String("dir", ".", "package directory")
+	log.SetFlags(0)
+	flag.Parse()
+
+	if *typeNames == "" {
+		log.Fatal("tlvgen: -type is required")
+	}
+
+	src, err := tlvgen.Generate(*dir, *out, strings.Split(*typeNames, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
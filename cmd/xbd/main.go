@@ -0,0 +1,81 @@
+// Command xbd shares an xb device on a serial port with multiple clients
+// over TCP and/or unix sockets.
+//
+// Clients connect with xb.NewClient using the net.Conn for both the
+// reader and writer.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+
+	"github.com/mastercactapus/embedded/xb"
+	"github.com/tarm/serial"
+)
+
+func main() {
+	baud := flag.Int("b", 115200, "baud rate")
+	port := flag.String("p", "/dev/ttyACM0", "port")
+	tcpAddr := flag.String("tcp", "", "TCP address to listen on (e.g. :7070)")
+	unixPath := flag.String("unix", "", "Unix socket path to listen on")
+	cobs := flag.Bool("cobs", false, "use COBS framing with the device")
+	connCOBS := flag.Bool("conn-cobs", false, "use COBS framing with clients")
+	log.SetFlags(log.Lshortfile)
+	flag.Parse()
+
+	if *tcpAddr == "" && *unixPath == "" {
+		log.Fatal("at least one of -tcp or -unix is required")
+	}
+
+	p, err := serial.OpenPort(&serial.Config{Name: *port, Baud: *baud})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.Close()
+
+	proxy := xb.NewProxy(p, p)
+	if *cobs {
+		proxy.Framing = xb.FramingCOBS
+	}
+	if *connCOBS {
+		proxy.ConnFraming = xb.FramingCOBS
+	}
+
+	if *tcpAddr != "" {
+		l, err := net.Listen("tcp", *tcpAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go serve(proxy, l)
+	}
+	if *unixPath != "" {
+		os.Remove(*unixPath)
+		l, err := net.Listen("unix", *unixPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer l.Close()
+		go serve(proxy, l)
+	}
+
+	log.Fatal(proxy.Run())
+}
+
+func serve(proxy *xb.Proxy, l net.Listener) {
+	log.Println("listening on", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go func() {
+			defer conn.Close()
+			log.Println("client connected:", conn.RemoteAddr())
+			err := proxy.ServeConn(conn)
+			log.Println("client disconnected:", conn.RemoteAddr(), err)
+		}()
+	}
+}
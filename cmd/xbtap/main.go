@@ -0,0 +1,91 @@
+// Command xbtap records a single xb session between a client and a
+// device on a serial port, or replays a recorded session without the device.
+//
+// Clients connect with xb.NewClient using the net.Conn for both the
+// reader and writer. Each entry in the trace is a JSON object holding
+// a request and its response, or another chunk sent by the device.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+
+	"github.com/mastercactapus/embedded/xb"
+	"github.com/tarm/serial"
+)
+
+func main() {
+	baud := flag.Int("b", 115200, "baud rate")
+	port := flag.String("p", "/dev/ttyACM0", "port")
+	addr := flag.String("tcp", ":7070", "TCP address to listen on")
+	out := flag.String("o", "trace.jsonl", "trace file to write")
+	replay := flag.String("replay", "", "trace file to replay instead of connecting to a device")
+	log.SetFlags(log.Lshortfile)
+	flag.Parse()
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("listening on", l.Addr())
+
+	if *replay != "" {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Fatal(err)
+			}
+			go serveReplay(conn, *replay)
+		}
+	}
+
+	p, err := serial.OpenPort(&serial.Config{Name: *port, Baud: *baud})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		log.Fatal(err)
+	}
+	l.Close()
+	log.Println("recording:", conn.RemoteAddr())
+
+	tap := xb.NewTap(p, p, f)
+	go io.Copy(conn, tap)
+	_, err = io.Copy(tap, conn)
+	conn.Close()
+	tap.Close()
+	log.Println("client disconnected:", conn.RemoteAddr(), err)
+}
+
+func serveReplay(conn net.Conn, name string) {
+	defer conn.Close()
+
+	f, err := os.Open(name)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+
+	srv, err := xb.NewReplayServer(conn, conn, f)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Println("replaying:", conn.RemoteAddr())
+	err = srv.Serve()
+	log.Println("client disconnected:", conn.RemoteAddr(), err)
+}
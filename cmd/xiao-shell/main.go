@@ -6,17 +6,18 @@ import (
 	"os"
 	"time"
 
+	"github.com/mastercactapus/embedded/at"
 	"github.com/mastercactapus/embedded/bustool"
-	"github.com/mastercactapus/embedded/driver/ioexp"
 	"github.com/mastercactapus/embedded/driver/stepper"
-	"github.com/mastercactapus/embedded/serial/i2c"
 	"github.com/mastercactapus/embedded/term"
+	"github.com/mastercactapus/embedded/xb"
 	"github.com/tarm/serial"
 )
 
 func main() {
 	baud := flag.Int("b", 460800, "baud rate")
 	port := flag.String("p", "/dev/ttyACM0", "port")
+	atBaud := flag.Int("at-baud", 0, "baud rate of an AT modem on the UART pins (D6/D7), 0 to disable")
 	log.SetFlags(log.Lshortfile)
 	flag.Parse()
 	p, err := serial.OpenPort(&serial.Config{Name: *port, Baud: *baud})
@@ -25,19 +26,26 @@ func main() {
 	}
 	defer p.Close()
 
-	x := ioexp.NewXIAO(p)
-	x.InputPins.State = 0xff
-	x.OutputState.State = 0
-	if err = x.Ping(); err != nil {
-		log.Fatal(err)
-	}
-	if err = x.Flush(); err != nil {
+	x, err := xb.NewClient(p, p)
+	if err != nil {
 		log.Fatal(err)
 	}
 
 	sh := bustool.NewShell(os.Stdin, os.Stdout)
+	bustool.AddCapture(sh, x)
+	if *atBaud > 0 {
+		u, err := x.UART(xb.UARTConfig{TX: 6, RX: 7, Baud: uint32(*atBaud)})
+		if err != nil {
+			log.Fatal(err)
+		}
+		bustool.AddAT(sh, at.NewClient(u))
+	}
 
-	i2cSh := bustool.AddI2C(sh, i2c.New(i2c.NewSoftController(x.Pin(1), x.Pin(0))))
+	bus, err := x.I2C(xb.I2CConfig{SDA: 1, SCL: 0})
+	if err != nil {
+		log.Fatal(err)
+	}
+	i2cSh := bustool.AddI2C(sh, bus)
 	bustool.AddIO(i2cSh)
 	bustool.AddMem(i2cSh)
 	bustool.AddRTC(i2cSh)
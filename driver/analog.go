@@ -0,0 +1,40 @@
+package driver
+
+// Pull selects the internal resistor used by an input pin.
+type Pull uint8
+
+const (
+	PullNone Pull = iota
+	PullUp
+	PullDown
+)
+
+// PullPin is implemented by pins with configurable internal pull resistors.
+type PullPin interface {
+	SetPull(Pull) error
+}
+
+// AnalogInput is implemented by pins that can be read by an ADC.
+type AnalogInput interface {
+	// ReadAnalog returns the reading scaled to the full 16-bit range,
+	// and the reference voltage, in millivolts, that 0xffff represents.
+	ReadAnalog() (value, refMV uint16, err error)
+}
+
+// PWMPin is implemented by pins that can output a PWM signal.
+type PWMPin interface {
+	// SetPWM drives the pin at freq Hz, high for duty/0xffff of each period.
+	//
+	// Pins sharing a timer may also share a frequency.
+	SetPWM(freq uint32, duty uint16) error
+}
+
+// Millivolts is a convenience method that returns the voltage on an
+// AnalogInput in millivolts.
+func Millivolts(a AnalogInput) (int, error) {
+	v, ref, err := a.ReadAnalog()
+	if err != nil {
+		return 0, err
+	}
+	return int(uint32(v) * uint32(ref) / 0xffff), nil
+}
@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/mastercactapus/embedded/term/ascii"
+)
+
+// LoadBoard reads a board definition, naming pins of p, and returns a
+// PinMap with the same numbering as p.
+//
+// Each line has a name and pin number, optionally followed by "invert"
+// for active-low signals. Blank lines and lines starting with '#' are
+// ignored:
+//
+//	# name  pin  [invert]
+//	LED1    3
+//	RESET   7    invert
+func LoadBoard(p Pinner, r io.Reader) (*PinMap, error) {
+	m := NewPinMap()
+	for i := 0; i < p.PinCount(); i++ {
+		m.Add(p, i)
+	}
+
+	s := bufio.NewScanner(r)
+	var line int
+	for s.Scan() {
+		line++
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := m.define(fields); err != nil {
+			return nil, ascii.Errorf("board: line %d: %w", line, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *PinMap) define(fields []string) error {
+	if len(fields) < 2 || len(fields) > 3 {
+		return errors.New("expected name, pin and optional 'invert'")
+	}
+	name := fields[0]
+	if _, ok := m.Lookup(name); ok {
+		return ascii.Errorf("duplicate name '%s'", name)
+	}
+	if _, err := ascii.ParseInt(name); err == nil {
+		return ascii.Errorf("name '%s' must not be a number", name)
+	}
+
+	n, err := ascii.ParseInt(fields[1])
+	if err != nil {
+		return ascii.Errorf("invalid pin '%s'", fields[1])
+	}
+	if n < 0 || n >= m.PinCount() {
+		return ascii.Errorf("pin %d out of range", n)
+	}
+
+	if len(fields) == 3 {
+		if fields[2] != "invert" {
+			return ascii.Errorf("unknown option '%s'", fields[2])
+		}
+		m.SetInvert(n, true)
+	}
+	m.SetName(n, name)
+	return nil
+}
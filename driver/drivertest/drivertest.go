@@ -0,0 +1,195 @@
+// Package drivertest provides fake pins for testing drivers.
+//
+// A Recorder hands out pins that log every change of level or direction,
+// so that the signals produced by a driver can be compared against a
+// golden waveform. Input pins can be scripted to return a sequence of
+// values.
+package drivertest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// Event is a change to a recorded pin.
+type Event struct {
+	// T is the time of the change, since the Recorder was created.
+	T time.Duration
+
+	Pin string
+
+	// Input and Level are the state of the pin after the change.
+	Input bool
+	Level bool
+}
+
+// Recorder records the changes of a set of pins.
+type Recorder struct {
+	mx     sync.Mutex
+	start  time.Time
+	pins   map[string]*Pin
+	names  []string
+	events []Event
+
+	// base is the state of each pin when events were last reset.
+	base map[string]Event
+}
+
+// NewRecorder returns a Recorder with no pins.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		start: time.Now(),
+		pins:  make(map[string]*Pin),
+		base:  make(map[string]Event),
+	}
+}
+
+// Pin returns the pin with the given name, creating it as a low output
+// if it does not exist.
+func (r *Recorder) Pin(name string) *Pin {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if p, ok := r.pins[name]; ok {
+		return p
+	}
+	p := &Pin{r: r, name: name}
+	r.pins[name] = p
+	r.names = append(r.names, name)
+	return p
+}
+
+// Pins returns a new pin for each name, in order.
+func (r *Recorder) Pins(names ...string) []driver.Pin {
+	pins := make([]driver.Pin, len(names))
+	for i, name := range names {
+		pins[i] = r.Pin(name)
+	}
+	return pins
+}
+
+// Events returns all recorded events, in order.
+func (r *Recorder) Events() []Event {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Reset clears all recorded events, keeping the current pin state.
+func (r *Recorder) Reset() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = nil
+	for name, p := range r.pins {
+		r.base[name] = Event{Pin: name, Input: p.input, Level: p.level}
+	}
+}
+
+// record logs the state of p, if it changed. r.mx must be held.
+func (r *Recorder) record(p *Pin, input, level bool) {
+	if p.input == input && p.level == level {
+		return
+	}
+	p.input, p.level = input, level
+	r.events = append(r.events, Event{
+		T:     time.Since(r.start),
+		Pin:   p.name,
+		Input: input,
+		Level: level,
+	})
+}
+
+// Pin is a recorded pin. It starts as a low output.
+//
+// Get returns the level last set, unless the pin is fed a script.
+type Pin struct {
+	r    *Recorder
+	name string
+
+	input bool
+	level bool
+
+	feed func() bool
+}
+
+var _ driver.Pin = (*Pin)(nil)
+
+// Name returns the name of the pin.
+func (p *Pin) Name() string { return p.name }
+
+// Feed sets the values returned by Get, one per call, from a waveform
+// of '0' or '_' for low and '1' or '-' for high. Spaces are ignored.
+// The last value is repeated once the waveform is used up.
+func (p *Pin) Feed(wave string) {
+	levels := parseWave(wave)
+	var i int
+	p.FeedFunc(func() bool {
+		if len(levels) == 0 {
+			return false
+		}
+		v := levels[i]
+		if i < len(levels)-1 {
+			i++
+		}
+		return v
+	})
+}
+
+// FeedFunc sets a function to provide the values returned by Get, or
+// clears it if fn is nil.
+func (p *Pin) FeedFunc(fn func() bool) {
+	p.r.mx.Lock()
+	defer p.r.mx.Unlock()
+	p.feed = fn
+}
+
+// State returns the current direction and level of the pin.
+func (p *Pin) State() (input, level bool) {
+	p.r.mx.Lock()
+	defer p.r.mx.Unlock()
+	return p.input, p.level
+}
+
+func (p *Pin) Set(v bool) error {
+	p.r.mx.Lock()
+	defer p.r.mx.Unlock()
+	p.r.record(p, p.input, v)
+	return nil
+}
+func (p *Pin) High() error { return p.Set(true) }
+func (p *Pin) Low() error  { return p.Set(false) }
+
+func (p *Pin) SetInput(v bool) error {
+	p.r.mx.Lock()
+	defer p.r.mx.Unlock()
+	p.r.record(p, v, p.level)
+	return nil
+}
+func (p *Pin) Input() error  { return p.SetInput(true) }
+func (p *Pin) Output() error { return p.SetInput(false) }
+
+func (p *Pin) Get() (bool, error) {
+	p.r.mx.Lock()
+	feed, level := p.feed, p.level
+	p.r.mx.Unlock()
+
+	if feed != nil {
+		return feed(), nil
+	}
+	return level, nil
+}
+
+func parseWave(wave string) []bool {
+	var levels []bool
+	for _, c := range wave {
+		switch c {
+		case '0', '_':
+			levels = append(levels, false)
+		case '1', '-':
+			levels = append(levels, true)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,58 @@
+package drivertest
+
+import (
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	clk, data := r.Pin("CLK"), r.Pin("DATA")
+
+	data.High()
+	clk.High()
+	clk.Low()
+	clk.Low() // no change
+	data.Input()
+	data.Low() // not shown while input
+	clk.High()
+
+	AssertWaveform(t, r, `
+		CLK  __-__-
+		DATA _---zz
+	`)
+	if n := len(r.Events()); n != 6 {
+		t.Errorf("got %d events; want 6", n)
+	}
+
+	want := "CLK  __-__-\nDATA _---zz\n"
+	if got := r.Waveform("CLK", "DATA"); got != want {
+		t.Errorf("Waveform() =\n%s\nwant:\n%s", got, want)
+	}
+
+	r.Reset()
+	data.Output()
+	AssertWaveform(t, r, `
+		CLK  --
+		DATA z_
+	`)
+}
+
+func TestPin_Feed(t *testing.T) {
+	p := NewRecorder().Pin("IN")
+	p.Feed("01 1_")
+
+	var got []bool
+	for i := 0; i < 6; i++ {
+		v, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	want := []bool{false, true, true, false, false, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
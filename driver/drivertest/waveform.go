@@ -0,0 +1,115 @@
+package drivertest
+
+import (
+	"strings"
+	"testing"
+)
+
+// Waveform renders the recorded changes of the named pins, one line per
+// pin, with '_' for low, '-' for high and 'z' for input. Each column is
+// the state after a change to any of the pins, starting from the state
+// when the Recorder was created or last reset.
+//
+// For example, a clock pulse while data is high:
+//
+//	CLK  _-_
+//	DATA ---
+func (r *Recorder) Waveform(names ...string) string {
+	return formatWaves(names, r.waves(names))
+}
+
+func (r *Recorder) waves(names []string) []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	state := make([]Event, len(names))
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		state[i] = r.base[name]
+		index[name] = i
+	}
+
+	cols := []string{render(state)}
+	for _, ev := range r.events {
+		i, ok := index[ev.Pin]
+		if !ok {
+			continue
+		}
+		state[i] = ev
+		// changes that don't show, like the level of an input, are
+		// skipped
+		if col := render(state); col != cols[len(cols)-1] {
+			cols = append(cols, col)
+		}
+	}
+
+	waves := make([]string, len(names))
+	for i := range names {
+		b := make([]byte, len(cols))
+		for j, col := range cols {
+			b[j] = col[i]
+		}
+		waves[i] = string(b)
+	}
+	return waves
+}
+
+func render(state []Event) string {
+	b := make([]byte, len(state))
+	for i, s := range state {
+		switch {
+		case s.Input:
+			b[i] = 'z'
+		case s.Level:
+			b[i] = '-'
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// AssertWaveform compares the recorded changes against a golden waveform,
+// in the format returned by Waveform, reporting an error if they differ.
+// Only pins named in golden are compared. Blank lines and spaces within
+// a waveform are ignored.
+func AssertWaveform(t testing.TB, r *Recorder, golden string) bool {
+	t.Helper()
+
+	var names, want []string
+	for _, line := range strings.Split(golden, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		names = append(names, fields[0])
+		want = append(want, strings.Join(fields[1:], ""))
+	}
+
+	got := r.waves(names)
+	for i := range names {
+		if got[i] != want[i] {
+			t.Errorf("waveform mismatch\ngot:\n%s\nwant:\n%s", r.Waveform(names...), formatWaves(names, want))
+			return false
+		}
+	}
+	return true
+}
+
+func formatWaves(names, waves []string) string {
+	var width int
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	var sb strings.Builder
+	for i, name := range names {
+		sb.WriteString(name)
+		sb.WriteString(strings.Repeat(" ", width-len(name)+1))
+		sb.WriteString(waves[i])
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Edge selects which pin transitions trigger an interrupt.
+type Edge uint8
+
+const (
+	EdgeNone    Edge = 0
+	EdgeRising  Edge = 1 << 0
+	EdgeFalling Edge = 1 << 1
+	EdgeBoth         = EdgeRising | EdgeFalling
+)
+
+// InterruptPin is implemented by pins that can notify of changes.
+type InterruptPin interface {
+	// SetInterrupt calls fn when the pin changes as selected by edge,
+	// replacing any previous handler. EdgeNone or a nil fn disables it.
+	//
+	// fn may be called from an interrupt handler or another goroutine,
+	// so it must return quickly and not block.
+	SetInterrupt(edge Edge, fn func()) error
+}
+
+// Interrupt returns pin as an InterruptPin, falling back to polling it
+// every interval if it has no native support, including when its
+// SetInterrupt returns ErrNotSupported.
+func Interrupt(pin InputPin, interval time.Duration) InterruptPin {
+	if ip, ok := pin.(InterruptPin); ok {
+		return &fallbackInterrupt{native: ip, poll: NewPollInterrupt(pin, interval)}
+	}
+	return NewPollInterrupt(pin, interval)
+}
+
+// fallbackInterrupt tries native interrupts first, polling if they
+// are not supported.
+type fallbackInterrupt struct {
+	native InterruptPin
+	poll   *PollInterrupt
+}
+
+func (p *fallbackInterrupt) SetInterrupt(edge Edge, fn func()) error {
+	err := p.native.SetInterrupt(edge, fn)
+	if errors.Is(err, ErrNotSupported) {
+		return p.poll.SetInterrupt(edge, fn)
+	}
+
+	// stop polling from a previous fallback
+	p.poll.SetInterrupt(EdgeNone, nil)
+	return err
+}
+
+// PollInterrupt emulates an InterruptPin by polling an input pin from a
+// goroutine. Read errors are ignored.
+type PollInterrupt struct {
+	pin      InputPin
+	interval time.Duration
+
+	mx   sync.Mutex
+	edge Edge
+	fn   func()
+	stop chan struct{}
+}
+
+var _ InterruptPin = (*PollInterrupt)(nil)
+
+// defaultPollInterval is used for intervals of zero or less.
+const defaultPollInterval = 10 * time.Millisecond
+
+// NewPollInterrupt returns a PollInterrupt that reads pin every interval
+// while a handler is set. An interval of zero or less polls every 10ms.
+func NewPollInterrupt(pin InputPin, interval time.Duration) *PollInterrupt {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	return &PollInterrupt{pin: pin, interval: interval}
+}
+
+func (p *PollInterrupt) SetInterrupt(edge Edge, fn func()) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if fn == nil {
+		edge = EdgeNone
+	}
+	p.edge, p.fn = edge, fn
+
+	if edge == EdgeNone {
+		if p.stop != nil {
+			close(p.stop)
+			p.stop = nil
+		}
+		return nil
+	}
+	if p.stop != nil {
+		return nil
+	}
+
+	last, err := p.pin.Get()
+	if err != nil {
+		return err
+	}
+	p.stop = make(chan struct{})
+	go p.poll(p.stop, last)
+	return nil
+}
+
+func (p *PollInterrupt) poll(stop chan struct{}, last bool) {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		v, err := p.pin.Get()
+		if err != nil || v == last {
+			continue
+		}
+		last = v
+
+		p.mx.Lock()
+		if p.stop != stop {
+			// disabled or replaced while reading
+			p.mx.Unlock()
+			return
+		}
+		fn := p.fn
+		match := (v && p.edge&EdgeRising != 0) || (!v && p.edge&EdgeFalling != 0)
+		p.mx.Unlock()
+		if match && fn != nil {
+			fn()
+		}
+	}
+}
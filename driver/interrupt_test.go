@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollInterrupt(t *testing.T) {
+	var state atomic.Bool
+	pin := PinF{GetFunc: func() (bool, error) { return state.Load(), nil }}
+
+	ip := Interrupt(pin, time.Millisecond)
+	if _, ok := ip.(*PollInterrupt); !ok {
+		t.Fatalf("got %T; want *PollInterrupt", ip)
+	}
+
+	ch := make(chan struct{}, 10)
+	if err := ip.SetInterrupt(EdgeRising, func() { ch <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	set := func(v bool) {
+		state.Store(v)
+		time.Sleep(20 * time.Millisecond)
+	}
+	set(true)
+	set(false)
+	set(true)
+	if len(ch) != 2 {
+		t.Errorf("got %d interrupts; want 2", len(ch))
+	}
+
+	if err := ip.SetInterrupt(EdgeNone, nil); err != nil {
+		t.Fatal(err)
+	}
+	set(false)
+	set(true)
+	if len(ch) != 2 {
+		t.Errorf("got %d interrupts after disabling; want 2", len(ch))
+	}
+}
+
+func TestInterrupt_Fallback(t *testing.T) {
+	var state atomic.Bool
+	var native atomic.Bool
+	get := func(int) (bool, error) { return state.Load(), nil }
+
+	ch := make(chan struct{}, 10)
+	fn := func() { ch <- struct{}{} }
+	set := func(v bool) {
+		state.Store(v)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// PinFN always implements InterruptPin
+	ip := Interrupt(PinFN{GetFunc: get}, time.Millisecond)
+	if err := ip.SetInterrupt(EdgeBoth, fn); err != nil {
+		t.Fatal(err)
+	}
+	set(true)
+	set(false)
+	if len(ch) != 2 {
+		t.Errorf("got %d polled interrupts; want 2", len(ch))
+	}
+	if err := ip.SetInterrupt(EdgeNone, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ip = Interrupt(PinFN{GetFunc: get, SetInterruptFunc: func(_ int, edge Edge, fn func()) error {
+		native.Store(edge != EdgeNone)
+		return nil
+	}}, time.Millisecond)
+	if err := ip.SetInterrupt(EdgeBoth, fn); err != nil {
+		t.Fatal(err)
+	}
+	if !native.Load() {
+		t.Error("native interrupt not used")
+	}
+	set(true)
+	if len(ch) != 2 {
+		t.Errorf("polled with native support: got %d interrupts; want 2", len(ch))
+	}
+}
+
+func TestPollInterrupt_Interval(t *testing.T) {
+	var state atomic.Bool
+	pin := PinF{GetFunc: func() (bool, error) { return state.Load(), nil }}
+
+	ch := make(chan struct{}, 10)
+	ip := NewPollInterrupt(pin, 0)
+	if err := ip.SetInterrupt(EdgeRising, func() { ch <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer ip.SetInterrupt(EdgeNone, nil)
+
+	state.Store(true)
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("no interrupt with the default interval")
+	}
+}
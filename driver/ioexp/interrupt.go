@@ -0,0 +1,121 @@
+package ioexp
+
+import (
+	"sync"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// irq dispatches changes signaled on an expander's INT line to per-pin
+// handlers.
+//
+// The INT handler only wakes a goroutine, which reads the device to find
+// the changed pins, since bus transfers can't be done from an interrupt.
+// That goroutine uses the device, so it must not be used concurrently
+// from elsewhere while handlers are set. It runs only while a handler is
+// set.
+type irq struct {
+	mx   sync.Mutex
+	edge [16]driver.Edge
+	fn   [16]func()
+	sig  chan struct{}
+	stop chan struct{}
+
+	// read returns the pins that changed and their new state, clearing
+	// the interrupt.
+	read func() (changed, state uint16, err error)
+}
+
+func newIRQ(read func() (uint16, uint16, error)) *irq {
+	return &irq{sig: make(chan struct{}, 1), read: read}
+}
+
+// attach handles interrupts from pin, the INT line.
+func (q *irq) attach(pin driver.InterruptPin, activeHigh bool) error {
+	edge := driver.EdgeFalling
+	if activeHigh {
+		edge = driver.EdgeRising
+	}
+	if err := pin.SetInterrupt(edge, q.signal); err != nil {
+		return err
+	}
+
+	// clear anything pending, since INT may already be low
+	q.signal()
+	return nil
+}
+
+func (q *irq) signal() {
+	select {
+	case q.sig <- struct{}{}:
+	default:
+	}
+}
+
+func (q *irq) loop(stop chan struct{}) {
+	var fns []func()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-q.sig:
+		}
+
+		changed, state, err := q.read()
+		if err != nil {
+			continue
+		}
+
+		fns = fns[:0]
+		q.mx.Lock()
+		if q.stop != stop {
+			// the last handler was removed while reading
+			q.mx.Unlock()
+			return
+		}
+		for n := range q.fn {
+			if changed&(1<<uint(n)) == 0 || q.fn[n] == nil {
+				continue
+			}
+			high := state&(1<<uint(n)) != 0
+			if (high && q.edge[n]&driver.EdgeRising != 0) || (!high && q.edge[n]&driver.EdgeFalling != 0) {
+				fns = append(fns, q.fn[n])
+			}
+		}
+		q.mx.Unlock()
+
+		for _, fn := range fns {
+			fn()
+		}
+	}
+}
+
+// set sets the handler for pin n, returning the mask of pins with a handler.
+func (q *irq) set(n int, edge driver.Edge, fn func()) uint16 {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if fn == nil {
+		edge = driver.EdgeNone
+	}
+	if edge == driver.EdgeNone {
+		fn = nil
+	}
+	q.edge[n], q.fn[n] = edge, fn
+
+	var mask uint16
+	for i, f := range q.fn {
+		if f != nil {
+			mask |= 1 << uint(i)
+		}
+	}
+
+	switch {
+	case mask != 0 && q.stop == nil:
+		q.stop = make(chan struct{})
+		go q.loop(q.stop)
+	case mask == 0 && q.stop != nil:
+		close(q.stop)
+		q.stop = nil
+	}
+	return mask
+}
@@ -0,0 +1,39 @@
+package ioexp
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+func TestIRQ(t *testing.T) {
+	var reads atomic.Int32
+	q := newIRQ(func() (uint16, uint16, error) {
+		reads.Add(1)
+		return 0b10, 0b10, nil
+	})
+
+	ch := make(chan struct{}, 1)
+	if mask := q.set(1, driver.EdgeRising, func() { ch <- struct{}{} }); mask != 0b10 {
+		t.Errorf("mask = %b; want 10", mask)
+	}
+	q.signal()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+
+	// removing the last handler stops the dispatch goroutine
+	if mask := q.set(1, driver.EdgeNone, nil); mask != 0 {
+		t.Errorf("mask = %b; want 0", mask)
+	}
+	n := reads.Load()
+	q.signal()
+	time.Sleep(20 * time.Millisecond)
+	if reads.Load() != n {
+		t.Error("device read with no handlers set")
+	}
+}
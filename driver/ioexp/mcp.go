@@ -0,0 +1,83 @@
+package ioexp
+
+import (
+	"errors"
+
+	"github.com/mastercactapus/embedded/serial"
+	"github.com/mastercactapus/embedded/serial/spi"
+)
+
+// IOCON is the configuration register of MCP23x08 and MCP23x17 devices.
+type IOCON uint8
+
+const (
+	// IOCONBank groups the registers of each port, instead of
+	// interleaving them. MCP23x17 only.
+	IOCONBank IOCON = 1 << 7
+
+	// IOCONMirror connects the INTA and INTB outputs, so that either
+	// reports changes on both ports. MCP23x17 only.
+	IOCONMirror IOCON = 1 << 6
+
+	// IOCONSeqOp disables the automatic address increment of
+	// sequential operations.
+	IOCONSeqOp IOCON = 1 << 5
+
+	// IOCONDisSlw disables slew rate control of SDA. I2C only.
+	IOCONDisSlw IOCON = 1 << 4
+
+	// IOCONHAEN enables the hardware address pins. SPI only.
+	IOCONHAEN IOCON = 1 << 3
+
+	// IOCONODR makes the INT outputs open-drain, overriding IOCONIntPol.
+	IOCONODR IOCON = 1 << 2
+
+	// IOCONIntPol makes the INT outputs active-high.
+	IOCONIntPol IOCON = 1 << 1
+)
+
+// Register indexes, which are the MCP23x08 addresses and the MCP23x17
+// port A addresses with IOCON.BANK set.
+const (
+	mcpRegIODIR = iota
+	mcpRegIPOL
+	mcpRegGPINTEN
+	mcpRegDEFVAL
+	mcpRegINTCON
+	mcpRegIOCON
+	mcpRegGPPU
+	mcpRegINTF
+	mcpRegINTCAP
+	mcpRegGPIO
+	mcpRegOLAT
+)
+
+// mcpSPI adds the MCP23Sxx opcode, holding the hardware address, to
+// register reads and writes.
+type mcpSPI struct {
+	dev    *spi.Device
+	opcode byte
+}
+
+var _ serial.Transmitter = (*mcpSPI)(nil)
+
+func newMCPSPI(dev *spi.Device, addr uint8) *mcpSPI {
+	return &mcpSPI{dev: dev, opcode: 0x40 | (addr&7)<<1}
+}
+
+func (m *mcpSPI) Write(p []byte) (int, error) {
+	if err := m.dev.Tx(append([]byte{m.opcode}, p...), nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read is not supported, since SPI reads must start with a register
+// address; use Tx instead.
+func (m *mcpSPI) Read(p []byte) (int, error) {
+	return 0, errors.New("ioexp: MCP23Sxx reads require a register address")
+}
+
+func (m *mcpSPI) Tx(w, r []byte) error {
+	return m.dev.Tx(append([]byte{m.opcode | 1}, w...), r)
+}
@@ -4,7 +4,9 @@ import (
 	"io"
 
 	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial"
 	"github.com/mastercactapus/embedded/serial/i2c"
+	"github.com/mastercactapus/embedded/serial/spi"
 )
 
 type MCP23X08 struct {
@@ -16,16 +18,21 @@ type MCP23X08 struct {
 	InputPins   uint8
 	OutputState uint8
 
+	// IntEnable enables interrupt-on-change for each pin (GPINTEN).
+	IntEnable uint8
+
+	// IntCompare selects pins that interrupt when they differ from
+	// DefaultValue, rather than when they change (INTCON).
+	IntCompare   uint8
+	DefaultValue uint8
+
 	lastRead uint8
+
+	iocon IOCON
+	irq   *irq
 }
 
-const (
-	mcp8RegOLAT  = 0x0a
-	mcp8RegIPOL  = 0x01
-	mcp8RegGPPU  = 0x06
-	mcp8RegIODIR = 0x00
-	mcp8RegGPIO  = 0x09
-)
+var _ driver.Port = (*MCP23X08)(nil)
 
 // NewMCP23008 is a convenience method that returns a PinReadWriter for a MCP23008-compatible I2C device.
 func NewMCP23008(bus i2c.Bus, addr uint16) *MCP23X08 {
@@ -35,24 +42,57 @@ func NewMCP23008(bus i2c.Bus, addr uint16) *MCP23X08 {
 	return NewMCP23X08(i2c.NewDevice(bus, addr))
 }
 
+// NewMCP23S08 returns a PinReadWriter for a MCP23S08-compatible SPI device
+// with the hardware address addr (0-3).
+//
+// Devices ignore the address pins, responding as address 0, until
+// IOCONHAEN is set. All devices sharing a chip select can be configured
+// at once with:
+//
+//	ioexp.NewMCP23S08(dev, 0).SetIOCON(ioexp.IOCONHAEN)
+func NewMCP23S08(dev *spi.Device, addr uint8) *MCP23X08 {
+	return NewMCP23X08(newMCPSPI(dev, addr&3))
+}
+
 // NewMCP23X08 is a convenience method that returns a PinReadWriter for a MCP23x08-compatible serial device.
 func NewMCP23X08(rw io.ReadWriter) *MCP23X08 {
 	return &MCP23X08{rw: rw}
 }
 
+// IOCON returns the last configuration set with SetIOCON.
+func (m *MCP23X08) IOCON() IOCON { return m.iocon }
+
+// SetIOCON writes the configuration register.
+func (m *MCP23X08) SetIOCON(v IOCON) error {
+	if err := m.write(mcpRegIOCON, uint8(v)); err != nil {
+		return err
+	}
+	m.iocon = v
+	return nil
+}
+
 func (MCP23X08) PinCount() int { return 8 }
 
 func (m *MCP23X08) Flush() error {
-	if err := m.write(mcp8RegGPPU, m.PullupPins); err != nil {
+	if err := m.write(mcpRegGPPU, m.PullupPins); err != nil {
+		return err
+	}
+	if err := m.write(mcpRegIODIR, m.InputPins); err != nil {
+		return err
+	}
+	if err := m.write(mcpRegIPOL, m.InvertPins); err != nil {
 		return err
 	}
-	if err := m.write(mcp8RegIODIR, m.InputPins); err != nil {
+	if err := m.write(mcpRegOLAT, m.OutputState); err != nil {
 		return err
 	}
-	if err := m.write(mcp8RegIPOL, m.InvertPins); err != nil {
+	if err := m.write(mcpRegDEFVAL, m.DefaultValue); err != nil {
 		return err
 	}
-	if err := m.write(mcp8RegOLAT, m.OutputState); err != nil {
+	if err := m.write(mcpRegINTCON, m.IntCompare); err != nil {
+		return err
+	}
+	if err := m.write(mcpRegGPINTEN, m.IntEnable); err != nil {
 		return err
 	}
 	return nil
@@ -69,6 +109,10 @@ func (m *MCP23X08) Pin(n int) driver.Pin {
 		GetFunc:      m.getPin,
 		SetInputFunc: m.setIODIR,
 		SetFunc:      m.setOLAT,
+
+		SetInterruptFunc: m.setInterrupt,
+		ModesFunc:        m.PinModes,
+		SetModeFunc:      m.setMode,
 	}
 }
 
@@ -78,7 +122,40 @@ func (m *MCP23X08) BufferedPin(n int) driver.Pin {
 		GetFunc:      m.getPinBuf,
 		SetInputFunc: m.setIODIRBuf,
 		SetFunc:      m.setOLATBuf,
+
+		SetInterruptFunc: m.setInterrupt,
+		ModesFunc:        m.PinModes,
+		SetModeFunc:      m.setModeBuf,
+	}
+}
+
+// PinModes returns the modes supported by every pin.
+func (MCP23X08) PinModes(int) driver.Mode {
+	return driver.ModePushPull | driver.ModeHighZ | driver.ModeInputPullup
+}
+
+func (m *MCP23X08) setModeBuf(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModePushPull:
+		return m.setIODIRBuf(n, false)
+	case driver.ModeHighZ:
+		m.PullupPins &^= 1 << uint8(n)
+		return m.setIODIRBuf(n, true)
+	case driver.ModeInputPullup:
+		m.PullupPins |= 1 << uint8(n)
+		return m.setIODIRBuf(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
+func (m *MCP23X08) setMode(n int, mode driver.Mode) error {
+	if err := m.setModeBuf(n, mode); err != nil {
+		return err
+	}
+	if err := m.write(mcpRegGPPU, m.PullupPins); err != nil {
+		return err
 	}
+	return m.write(mcpRegIODIR, m.InputPins)
 }
 
 func (m *MCP23X08) setIODIRBuf(n int, v bool) error {
@@ -92,7 +169,7 @@ func (m *MCP23X08) setIODIRBuf(n int, v bool) error {
 
 func (m *MCP23X08) setIODIR(n int, v bool) error {
 	m.setIODIRBuf(n, v)
-	return m.write(mcp8RegIODIR, m.InputPins)
+	return m.write(mcpRegIODIR, m.InputPins)
 }
 
 func (m *MCP23X08) setOLATBuf(n int, v bool) error {
@@ -106,7 +183,7 @@ func (m *MCP23X08) setOLATBuf(n int, v bool) error {
 
 func (m *MCP23X08) setOLAT(n int, v bool) error {
 	m.setOLATBuf(n, v)
-	return m.write(mcp8RegOLAT, m.OutputState)
+	return m.write(mcpRegOLAT, m.OutputState)
 }
 
 func (m *MCP23X08) getPinBuf(n int) (bool, error) {
@@ -121,20 +198,90 @@ func (m *MCP23X08) getPin(n int) (bool, error) {
 }
 
 func (m *MCP23X08) Refresh() error {
-	if br, ok := m.rw.(io.ByteReader); ok {
-		b, err := br.ReadByte()
-		if err != nil {
-			return err
-		}
-		m.lastRead = b
-		return nil
+	var buf [1]byte
+	if err := serial.Tx(m.rw, []byte{mcpRegGPIO}, buf[:]); err != nil {
+		return err
 	}
+	m.lastRead = buf[0]
+	return nil
+}
 
+// ReadIntFlags returns the pins that caused the last interrupt (INTF).
+func (m *MCP23X08) ReadIntFlags() (uint8, error) { return m.read(mcpRegINTF) }
+
+// ReadIntCapture returns the pin state at the time of the last interrupt
+// (INTCAP), clearing it.
+func (m *MCP23X08) ReadIntCapture() (uint8, error) { return m.read(mcpRegINTCAP) }
+
+func (m *MCP23X08) read(reg uint8) (uint8, error) {
 	var buf [1]byte
-	_, err := m.rw.Read(buf[:])
+	if err := serial.Tx(m.rw, []byte{reg}, buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// SetInterruptPin enables pin interrupts, using the INT output of the chip
+// connected to pin.
+//
+// Interrupts are handled by a goroutine that reads the device, so it must
+// not be used concurrently while pin interrupts are set.
+func (m *MCP23X08) SetInterruptPin(pin driver.InterruptPin) error {
+	if m.irq == nil {
+		m.irq = newIRQ(m.readInterrupt)
+	}
+	return m.irq.attach(pin, m.iocon&(IOCONIntPol|IOCONODR) == IOCONIntPol)
+}
+
+// readInterrupt reads the interrupt flags and captured state, which
+// clears the interrupt.
+func (m *MCP23X08) readInterrupt() (uint16, uint16, error) {
+	flags, err := m.ReadIntFlags()
 	if err != nil {
+		return 0, 0, err
+	}
+	capture, err := m.ReadIntCapture()
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint16(flags), uint16(capture), nil
+}
+
+func (m *MCP23X08) setInterrupt(n int, edge driver.Edge, fn func()) error {
+	if m.irq == nil {
+		return driver.ErrNotSupported
+	}
+	m.irq.set(n, edge, fn)
+
+	// compare against the previous value, to interrupt on any change
+	m.IntCompare &^= 1 << uint(n)
+	if err := m.write(mcpRegINTCON, m.IntCompare); err != nil {
 		return err
 	}
-	m.lastRead = buf[0]
-	return nil
+	if edge != driver.EdgeNone && fn != nil {
+		m.IntEnable |= 1 << uint(n)
+	} else {
+		m.IntEnable &^= 1 << uint(n)
+	}
+	return m.write(mcpRegGPINTEN, m.IntEnable)
+}
+
+// SetMask sets the output state of the pins in mask.
+func (m *MCP23X08) SetMask(mask, value uint64) error {
+	m.OutputState = m.OutputState&^uint8(mask) | uint8(value&mask)
+	return m.write(mcpRegOLAT, m.OutputState)
+}
+
+// Get reads the state of all pins.
+func (m *MCP23X08) Get() (uint64, error) {
+	if err := m.Refresh(); err != nil {
+		return 0, err
+	}
+	return uint64(m.lastRead), nil
+}
+
+// SetDirection makes the pins in mask inputs, and the rest outputs.
+func (m *MCP23X08) SetDirection(mask uint64) error {
+	m.InputPins = uint8(mask)
+	return m.write(mcpRegIODIR, m.InputPins)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/mastercactapus/embedded/driver"
 	"github.com/mastercactapus/embedded/serial/i2c"
+	"github.com/mastercactapus/embedded/serial/spi"
 )
 
 type MCP23X17 struct {
@@ -15,18 +16,26 @@ type MCP23X17 struct {
 	InputPins   *Register16
 	OutputState *Register16
 	InputState  *Register16
+
+	// IntEnable enables interrupt-on-change for each pin (GPINTEN).
+	IntEnable *Register16
+
+	// IntCompare selects pins that interrupt when they differ from
+	// DefaultValue, rather than when they change (INTCON).
+	IntCompare   *Register16
+	DefaultValue *Register16
+
+	// IntFlags and IntCapture are read-only, holding the pins that caused
+	// an interrupt and their state at the time (INTF and INTCAP). Refreshing
+	// IntCapture clears the interrupt.
+	IntFlags   *Register16
+	IntCapture *Register16
+
+	iocon IOCON
+	irq   *irq
 }
 
-const (
-	// Registers A and B are one after another
-	// so we can just send two bytes to the
-	// first for all these calls.
-	mcp16RegOLATA  = 0x14
-	mcp16RegIPOLA  = 0x02
-	mcp16RegGPPUA  = 0x0C
-	mcp16RegIODIRA = 0x00
-	mcp16RegGPIOA  = 0x12
-)
+var _ driver.Port = (*MCP23X17)(nil)
 
 // NewMCP23017 is a convenience method that returns a PinReadWriter for a MCP23017-compatible I2C device.
 func NewMCP23017(bus i2c.Bus, addr uint16) *MCP23X17 {
@@ -36,16 +45,87 @@ func NewMCP23017(bus i2c.Bus, addr uint16) *MCP23X17 {
 	return NewMCP23X17(i2c.NewDevice(bus, addr))
 }
 
+// NewMCP23S17 returns a PinReadWriter for a MCP23S17-compatible SPI device
+// with the hardware address addr (0-7).
+//
+// Devices ignore the address pins, responding as address 0, until
+// IOCONHAEN is set. All devices sharing a chip select can be configured
+// at once with:
+//
+//	ioexp.NewMCP23S17(dev, 0).SetIOCON(ioexp.IOCONHAEN)
+func NewMCP23S17(dev *spi.Device, addr uint8) *MCP23X17 {
+	return NewMCP23X17(newMCPSPI(dev, addr))
+}
+
 // NewMCP23X17 is a convenience method that returns a PinReadWriter for a MCP23x17-compatible serial device.
+//
+// The device must be using the default IOCON.BANK setting, which is set
+// again with SetIOCON if changed.
 func NewMCP23X17(rw io.ReadWriter) *MCP23X17 {
-	return &MCP23X17{
-		rw:          rw,
-		InvertPins:  NewRegister16(rw, mcp16RegIPOLA),
-		PullupPins:  NewRegister16(rw, mcp16RegGPPUA),
-		InputPins:   NewRegister16(rw, mcp16RegIODIRA),
-		OutputState: NewRegister16(rw, mcp16RegOLATA),
-		InputState:  NewRegister16(rw, mcp16RegGPIOA),
+	m := &MCP23X17{rw: rw}
+	m.InvertPins = m.reg(mcpRegIPOL)
+	m.PullupPins = m.reg(mcpRegGPPU)
+	m.InputPins = m.reg(mcpRegIODIR)
+	m.OutputState = m.reg(mcpRegOLAT)
+	m.InputState = m.reg(mcpRegGPIO)
+	m.IntEnable = m.reg(mcpRegGPINTEN)
+	m.IntCompare = m.reg(mcpRegINTCON)
+	m.DefaultValue = m.reg(mcpRegDEFVAL)
+	m.IntFlags = m.reg(mcpRegINTF)
+	m.IntCapture = m.reg(mcpRegINTCAP)
+	return m
+}
+
+// reg returns a register for the current IOCON.BANK setting.
+func (m *MCP23X17) reg(idx uint8) *Register16 {
+	r := NewRegister16(m.rw, 0)
+	m.setAddr(r, idx)
+	return r
+}
+
+func (m *MCP23X17) setAddr(r *Register16, idx uint8) {
+	if m.iocon&IOCONBank != 0 {
+		// ports are in separate banks, accessed separately
+		r.setAddr(idx, idx+0x10, true)
+		return
+	}
+
+	// Registers A and B are one after another, so both are
+	// accessed with two bytes to the first. With IOCON.SEQOP set, the
+	// address toggles between the pair, which works the same.
+	r.setAddr(idx*2, idx*2+1, false)
+}
+
+// IOCON returns the last configuration set with SetIOCON.
+func (m *MCP23X17) IOCON() IOCON { return m.iocon }
+
+// SetIOCON writes the configuration register, updating register addresses
+// if IOCONBank changes.
+func (m *MCP23X17) SetIOCON(v IOCON) error {
+	addr := uint8(mcpRegIOCON * 2)
+	if m.iocon&IOCONBank != 0 {
+		addr = mcpRegIOCON
 	}
+	if _, err := m.rw.Write([]byte{addr, byte(v)}); err != nil {
+		return err
+	}
+
+	m.iocon = v
+	for idx, r := range map[uint8]*Register16{
+		mcpRegIPOL:    m.InvertPins,
+		mcpRegGPPU:    m.PullupPins,
+		mcpRegIODIR:   m.InputPins,
+		mcpRegOLAT:    m.OutputState,
+		mcpRegGPIO:    m.InputState,
+		mcpRegGPINTEN: m.IntEnable,
+		mcpRegINTCON:  m.IntCompare,
+		mcpRegDEFVAL:  m.DefaultValue,
+		mcpRegINTF:    m.IntFlags,
+		mcpRegINTCAP:  m.IntCapture,
+	} {
+		m.setAddr(r, idx)
+	}
+	return nil
 }
 
 func (MCP23X17) PinCount() int { return 16 }
@@ -63,6 +143,15 @@ func (m *MCP23X17) Flush() error {
 	if err := m.OutputState.Flush(); err != nil {
 		return err
 	}
+	if err := m.DefaultValue.Flush(); err != nil {
+		return err
+	}
+	if err := m.IntCompare.Flush(); err != nil {
+		return err
+	}
+	if err := m.IntEnable.Flush(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -72,6 +161,10 @@ func (m *MCP23X17) Pin(n int) driver.Pin {
 		GetFunc:      m.InputState.Get,
 		SetInputFunc: m.InputPins.Set,
 		SetFunc:      m.OutputState.Set,
+
+		SetInterruptFunc: m.setInterrupt,
+		ModesFunc:        m.PinModes,
+		SetModeFunc:      m.setMode,
 	}
 }
 
@@ -81,7 +174,98 @@ func (m *MCP23X17) BufferedPin(n int) driver.Pin {
 		GetFunc:      m.InputState.GetBuf,
 		SetInputFunc: m.InputPins.SetBuf,
 		SetFunc:      m.OutputState.SetBuf,
+
+		SetInterruptFunc: m.setInterrupt,
+		ModesFunc:        m.PinModes,
+		SetModeFunc:      m.setModeBuf,
 	}
 }
 
+// PinModes returns the modes supported by every pin.
+func (MCP23X17) PinModes(int) driver.Mode {
+	return driver.ModePushPull | driver.ModeHighZ | driver.ModeInputPullup
+}
+
+func (m *MCP23X17) setModeBuf(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModePushPull:
+		return m.InputPins.SetBuf(n, false)
+	case driver.ModeHighZ, driver.ModeInputPullup:
+		m.PullupPins.SetBuf(n, mode == driver.ModeInputPullup)
+		return m.InputPins.SetBuf(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
+func (m *MCP23X17) setMode(n int, mode driver.Mode) error {
+	if err := m.setModeBuf(n, mode); err != nil {
+		return err
+	}
+	if err := m.PullupPins.Flush(); err != nil {
+		return err
+	}
+	return m.InputPins.Flush()
+}
+
 func (m *MCP23X17) Refresh() error { return m.InputState.Refresh() }
+
+// SetInterruptPin enables pin interrupts, using the INTA or INTB output of
+// the chip connected to pin. IOCONMirror is set so that either output
+// reports both ports.
+//
+// Interrupts are handled by a goroutine that reads the device, so it must
+// not be used concurrently while pin interrupts are set.
+func (m *MCP23X17) SetInterruptPin(pin driver.InterruptPin) error {
+	if err := m.SetIOCON(m.iocon | IOCONMirror); err != nil {
+		return err
+	}
+	if m.irq == nil {
+		m.irq = newIRQ(m.readInterrupt)
+	}
+	return m.irq.attach(pin, m.iocon&(IOCONIntPol|IOCONODR) == IOCONIntPol)
+}
+
+// readInterrupt reads the interrupt flags and captured state, which
+// clears the interrupt.
+func (m *MCP23X17) readInterrupt() (uint16, uint16, error) {
+	if err := m.IntFlags.Refresh(); err != nil {
+		return 0, 0, err
+	}
+	if err := m.IntCapture.Refresh(); err != nil {
+		return 0, 0, err
+	}
+	return m.IntFlags.State, m.IntCapture.State, nil
+}
+
+func (m *MCP23X17) setInterrupt(n int, edge driver.Edge, fn func()) error {
+	if m.irq == nil {
+		return driver.ErrNotSupported
+	}
+	m.irq.set(n, edge, fn)
+
+	// compare against the previous value, to interrupt on any change
+	if err := m.IntCompare.Set(n, false); err != nil {
+		return err
+	}
+	return m.IntEnable.Set(n, edge != driver.EdgeNone && fn != nil)
+}
+
+// SetMask sets the output state of the pins in mask.
+func (m *MCP23X17) SetMask(mask, value uint64) error {
+	m.OutputState.State = m.OutputState.State&^uint16(mask) | uint16(value&mask)
+	return m.OutputState.Flush()
+}
+
+// Get reads the state of all pins.
+func (m *MCP23X17) Get() (uint64, error) {
+	if err := m.InputState.Refresh(); err != nil {
+		return 0, err
+	}
+	return uint64(m.InputState.State), nil
+}
+
+// SetDirection makes the pins in mask inputs, and the rest outputs.
+func (m *MCP23X17) SetDirection(mask uint64) error {
+	m.InputPins.State = uint16(mask)
+	return m.InputPins.Flush()
+}
@@ -0,0 +1,269 @@
+package ioexp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial/i2c"
+	"github.com/mastercactapus/embedded/serial/spi"
+)
+
+// fakeRegs is a register file with an auto-incrementing address, like
+// the MCP23xxx with IOCON.SEQOP clear. Each transaction is logged as the
+// bytes written, followed by "rN" if N bytes were read.
+type fakeRegs struct {
+	regs [0x20]byte
+	log  []string
+}
+
+func (f *fakeRegs) tx(w, r []byte) {
+	entry := fmt.Sprintf("% x", w)
+	if len(r) > 0 {
+		entry += fmt.Sprintf(" r%d", len(r))
+	}
+	f.log = append(f.log, entry)
+
+	if len(w) == 0 {
+		return
+	}
+	addr := int(w[0])
+	for _, b := range w[1:] {
+		f.regs[addr%len(f.regs)] = b
+		addr++
+	}
+	for i := range r {
+		r[i] = f.regs[addr%len(f.regs)]
+		addr++
+	}
+}
+
+func (f *fakeRegs) assertLog(t *testing.T, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(f.log, want) {
+		t.Errorf("got transactions\n%q\nwant\n%q", f.log, want)
+	}
+	f.log = nil
+}
+
+// fakeI2C is a bus with a single fakeRegs device at 0x20.
+type fakeI2C struct{ fakeRegs }
+
+func (b *fakeI2C) Tx(addr uint16, w, r []byte) error {
+	if addr != 0x20 {
+		return i2c.ErrNack
+	}
+	b.tx(w, r)
+	return nil
+}
+
+// fakeSPI is an MCP23Sxx on a bus, logging the opcode before the
+// register transaction of each chip select frame.
+type fakeSPI struct {
+	fakeRegs
+	frame []byte
+	pos   int
+	addr  int
+}
+
+func (s *fakeSPI) cs() driver.OutputPin {
+	return driver.PinF{SetFunc: func(v bool) error {
+		if !v {
+			s.frame, s.pos = s.frame[:0], 0
+			return nil
+		}
+		if len(s.frame) == 0 {
+			return nil
+		}
+		entry := fmt.Sprintf("% x", s.frame[:2])
+		if s.frame[0]&1 != 0 {
+			entry += fmt.Sprintf(" r%d", len(s.frame)-2)
+		} else if len(s.frame) > 2 {
+			entry += fmt.Sprintf(" % x", s.frame[2:])
+		}
+		s.log = append(s.log, entry)
+		s.frame = s.frame[:0]
+		return nil
+	}}
+}
+
+func (s *fakeSPI) ReadWriteByte(b byte) (byte, error) {
+	s.pos++
+	switch {
+	case s.pos == 1:
+		s.frame = append(s.frame, b)
+		return 0, nil
+	case s.pos == 2:
+		s.frame = append(s.frame, b)
+		s.addr = int(b)
+		return 0, nil
+	case s.frame[0]&1 != 0:
+		// read
+		s.frame = append(s.frame, b)
+		v := s.regs[s.addr%len(s.regs)]
+		s.addr++
+		return v, nil
+	}
+	s.frame = append(s.frame, b)
+	s.regs[s.addr%len(s.regs)] = b
+	s.addr++
+	return 0, nil
+}
+
+func newFakeSPIDevice(t *testing.T) (*fakeSPI, *spi.Device) {
+	t.Helper()
+	s := new(fakeSPI)
+	dev, err := spi.NewDevice(s, s.cs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dev
+}
+
+func TestRegister16(t *testing.T) {
+	var f fakeI2C
+	dev := i2c.NewDevice(&f, 0x20)
+
+	r := NewRegister16(dev, 0x04)
+	r.SetInvert(0, true)
+	r.State = 0x1201
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f.regs[0x04], f.regs[0x05] = 0x35, 0x12
+	if err := r.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "04 00 12", "04 r2")
+	if r.State != 0x1234 {
+		t.Errorf("State = 0x%04x; want 0x1234", r.State)
+	}
+
+	// split registers are accessed one byte at a time
+	r = NewRegister16Split(dev, 0x04, 0x14)
+	r.SetInvert(8, true)
+	r.State = 0x0201
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f.regs[0x04], f.regs[0x14] = 0x56, 0x35
+	if err := r.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "04 01", "14 03", "04 r1", "14 r1")
+	if r.State != 0x3456 {
+		t.Errorf("State = 0x%04x; want 0x3456", r.State)
+	}
+}
+
+func TestMCP23X17_Bank(t *testing.T) {
+	var f fakeI2C
+	m := NewMCP23017(&f, 0)
+
+	// IOCON.BANK=0: A and B registers are interleaved
+	if err := m.Pin(9).High(); err != nil {
+		t.Fatal(err)
+	}
+	f.regs[0x12], f.regs[0x13] = 0x34, 0x12
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "14 00 02", "12 r2")
+	if m.InputState.State != 0x1234 {
+		t.Errorf("InputState = 0x%04x; want 0x1234", m.InputState.State)
+	}
+
+	if err := m.SetIOCON(IOCONBank); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "0a 80")
+
+	// IOCON.BANK=1: port B registers are 0x10 after port A
+	if err := m.Pin(0).High(); err != nil {
+		t.Fatal(err)
+	}
+	f.regs[0x09], f.regs[0x19] = 0xcd, 0xab
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "0a 01", "1a 02", "09 r1", "19 r1")
+	if m.InputState.State != 0xabcd {
+		t.Errorf("InputState = 0x%04x; want 0xabcd", m.InputState.State)
+	}
+
+	// IOCON is at 0x05 in bank mode
+	if err := m.SetIOCON(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.InputPins.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "05 00", "00 00 00")
+}
+
+func TestMCP23S17(t *testing.T) {
+	s, dev := newFakeSPIDevice(t)
+	m := NewMCP23S17(dev, 5)
+
+	// the opcode holds the hardware address, and bit 0 is set for reads
+	if err := m.Pin(9).High(); err != nil {
+		t.Fatal(err)
+	}
+	s.regs[0x12], s.regs[0x13] = 0x34, 0x12
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	s.assertLog(t, "4a 14 00 02", "4b 12 r2")
+	if m.InputState.State != 0x1234 {
+		t.Errorf("InputState = 0x%04x; want 0x1234", m.InputState.State)
+	}
+
+	if err := m.SetIOCON(IOCONBank | IOCONHAEN); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Pin(0).High(); err != nil {
+		t.Fatal(err)
+	}
+	s.regs[0x09], s.regs[0x19] = 0xcd, 0xab
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	s.assertLog(t, "4a 0a 88", "4a 0a 01", "4a 1a 02", "4b 09 r1", "4b 19 r1")
+	if m.InputState.State != 0xabcd {
+		t.Errorf("InputState = 0x%04x; want 0xabcd", m.InputState.State)
+	}
+}
+
+func TestMCP23X08(t *testing.T) {
+	var f fakeI2C
+	m := NewMCP23008(&f, 0)
+
+	if err := m.Pin(1).High(); err != nil {
+		t.Fatal(err)
+	}
+	f.regs[0x09] = 0x5a
+	v, err := m.Pin(3).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "0a 02", "09 r1")
+	if !v {
+		t.Error("pin 3 = false; want true")
+	}
+
+	// the address is limited to 0-3
+	s, dev := newFakeSPIDevice(t)
+	m = NewMCP23S08(dev, 7)
+	if err := m.Pin(1).High(); err != nil {
+		t.Fatal(err)
+	}
+	s.regs[0x09] = 0x5a
+	if err := m.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	s.assertLog(t, "46 0a 02", "47 09 r1")
+	if m.lastRead != 0x5a {
+		t.Errorf("read 0x%02x; want 0x5a", m.lastRead)
+	}
+}
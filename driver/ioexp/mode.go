@@ -0,0 +1,14 @@
+package ioexp
+
+import "github.com/mastercactapus/embedded/driver"
+
+// fixedMode returns a SetModeFunc for pins that each have a single mode,
+// reported by modes, which is accepted without change.
+func fixedMode(modes func(int) driver.Mode) func(int, driver.Mode) error {
+	return func(n int, mode driver.Mode) error {
+		if mode != modes(n) {
+			return driver.ErrNotSupported
+		}
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+package ioexp
+
+import (
+	"io"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+// PCA9555 is a 16-bit I/O expander with input, output, polarity and
+// configuration registers, as used by the PCA9555, TCA9535 and TCA6416.
+type PCA9555 struct {
+	rw io.ReadWriter
+
+	InvertPins  *Register16
+	InputPins   *Register16
+	OutputState *Register16
+	InputState  *Register16
+
+	irq     *irq
+	intLast uint16
+}
+
+const (
+	pcaRegInput    = 0x00
+	pcaRegOutput   = 0x02
+	pcaRegPolarity = 0x04
+	pcaRegConfig   = 0x06
+)
+
+// NewPCA9555 is a convenience method that returns a PinReadWriter for a PCA9555-compatible I2C device.
+//
+// Default address is 0x20.
+func NewPCA9555(bus i2c.Bus, addr uint16) *PCA9555 {
+	if addr == 0 {
+		addr = 0x20
+	}
+	return NewPCA9555X(i2c.NewDevice(bus, addr))
+}
+
+// NewTCA9535 is a convenience method that returns a PinReadWriter for a TCA9535 I2C device.
+//
+// Default address is 0x20.
+func NewTCA9535(bus i2c.Bus, addr uint16) *PCA9555 { return NewPCA9555(bus, addr) }
+
+// NewTCA6416 is a convenience method that returns a PinReadWriter for a TCA6416 I2C device.
+//
+// Default address is 0x20.
+func NewTCA6416(bus i2c.Bus, addr uint16) *PCA9555 { return NewPCA9555(bus, addr) }
+
+// NewPCA9555X is a convenience method that returns a PinReadWriter for a PCA9555-compatible serial device.
+//
+// All pins are inputs at power-on, so InputPins starts with all pins set.
+func NewPCA9555X(rw io.ReadWriter) *PCA9555 {
+	p := &PCA9555{
+		rw:          rw,
+		InvertPins:  NewRegister16(rw, pcaRegPolarity),
+		InputPins:   NewRegister16(rw, pcaRegConfig),
+		OutputState: NewRegister16(rw, pcaRegOutput),
+		InputState:  NewRegister16(rw, pcaRegInput),
+	}
+	p.InputPins.State = 0xffff
+	p.OutputState.State = 0xffff
+	return p
+}
+
+func (PCA9555) PinCount() int { return 16 }
+
+func (p *PCA9555) Flush() error {
+	if err := p.InvertPins.Flush(); err != nil {
+		return err
+	}
+	if err := p.OutputState.Flush(); err != nil {
+		return err
+	}
+	return p.InputPins.Flush()
+}
+
+func (p *PCA9555) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:            n,
+		GetFunc:      p.InputState.Get,
+		SetInputFunc: p.InputPins.Set,
+		SetFunc:      p.OutputState.Set,
+
+		SetInterruptFunc: p.setInterrupt,
+		ModesFunc:        p.PinModes,
+		SetModeFunc:      p.setMode,
+	}
+}
+
+func (p *PCA9555) BufferedPin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:            n,
+		GetFunc:      p.InputState.GetBuf,
+		SetInputFunc: p.InputPins.SetBuf,
+		SetFunc:      p.OutputState.SetBuf,
+
+		SetInterruptFunc: p.setInterrupt,
+		ModesFunc:        p.PinModes,
+		SetModeFunc:      p.setModeBuf,
+	}
+}
+
+// PinModes returns the modes supported by every pin.
+func (PCA9555) PinModes(int) driver.Mode { return driver.ModePushPull | driver.ModeHighZ }
+
+func (p *PCA9555) setModeBuf(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModePushPull:
+		return p.InputPins.SetBuf(n, false)
+	case driver.ModeHighZ:
+		return p.InputPins.SetBuf(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
+func (p *PCA9555) setMode(n int, mode driver.Mode) error {
+	if err := p.setModeBuf(n, mode); err != nil {
+		return err
+	}
+	return p.InputPins.Flush()
+}
+
+func (p *PCA9555) Refresh() error { return p.InputState.Refresh() }
+
+// SetInterruptPin enables pin interrupts, using the INT output of the chip
+// connected to pin, which is asserted when any input changes.
+//
+// Interrupts are handled by a goroutine that reads the device, so it must
+// not be used concurrently while pin interrupts are set.
+func (p *PCA9555) SetInterruptPin(pin driver.InterruptPin) error {
+	if p.irq == nil {
+		if err := p.InputState.Refresh(); err != nil {
+			return err
+		}
+		p.intLast = p.InputState.State
+		p.irq = newIRQ(p.readInterrupt)
+	}
+	return p.irq.attach(pin, false)
+}
+
+// readInterrupt reads the inputs, which clears the interrupt, and
+// compares them with the last read.
+func (p *PCA9555) readInterrupt() (uint16, uint16, error) {
+	if err := p.InputState.Refresh(); err != nil {
+		return 0, 0, err
+	}
+	v := p.InputState.State
+	changed := v ^ p.intLast
+	p.intLast = v
+	return changed, v, nil
+}
+
+func (p *PCA9555) setInterrupt(n int, edge driver.Edge, fn func()) error {
+	if p.irq == nil {
+		return driver.ErrNotSupported
+	}
+	p.irq.set(n, edge, fn)
+	return nil
+}
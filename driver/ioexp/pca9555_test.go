@@ -0,0 +1,40 @@
+package ioexp
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+func TestPCA9555(t *testing.T) {
+	var f fakeI2C
+	p := NewPCA9555(&f, 0)
+
+	if err := p.Pin(9).Output(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(9).Low(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(1).(driver.ModePin).SetMode(driver.ModeHighZ); err != nil {
+		t.Fatal(err)
+	}
+	f.regs[0x00], f.regs[0x01] = 0x34, 0x12
+	v, err := p.Pin(2).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "06 ff fd", "02 ff fd", "06 ff fd", "00 r2")
+	if !v {
+		t.Error("pin 2 = false; want true")
+	}
+	if p.InputState.State != 0x1234 {
+		t.Errorf("InputState = 0x%04x; want 0x1234", p.InputState.State)
+	}
+
+	p.InvertPins.State = 0x0101
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "04 01 01", "02 ff fd", "06 ff fd")
+}
@@ -0,0 +1,197 @@
+package ioexp
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+// PCA9685 is a 16-channel, 12-bit PWM driver. All channels share a single
+// frequency.
+//
+// Configure must be called before use, to enable register auto-increment
+// and set the frequency.
+type PCA9685 struct {
+	rw io.ReadWriter
+
+	// Duty holds the duty cycle of each channel, where 0xffff is fully on.
+	Duty [16]uint16
+
+	// Osc is the oscillator frequency in Hz, if an external clock is used.
+	Osc uint32
+
+	freq uint32
+}
+
+const (
+	pca9685RegMode1    = 0x00
+	pca9685RegLED0     = 0x06
+	pca9685RegPrescale = 0xfe
+
+	pca9685Mode1Restart = 1 << 7
+	pca9685Mode1AI      = 1 << 5
+	pca9685Mode1Sleep   = 1 << 4
+
+	pca9685Osc = 25000000
+)
+
+// NewPCA9685 is a convenience method that returns a PinReadWriter for a PCA9685-compatible I2C device.
+//
+// Default address is 0x40.
+func NewPCA9685(bus i2c.Bus, addr uint16) *PCA9685 {
+	if addr == 0 {
+		addr = 0x40
+	}
+	return NewPCA9685X(i2c.NewDevice(bus, addr))
+}
+
+// NewPCA9685X is a convenience method that returns a PinReadWriter for a PCA9685-compatible serial device.
+func NewPCA9685X(rw io.ReadWriter) *PCA9685 {
+	return &PCA9685{rw: rw, Osc: pca9685Osc}
+}
+
+// Configure sets the PWM frequency, in Hz, and writes the duty cycle of
+// all channels.
+func (p *PCA9685) Configure(freq uint32) error {
+	if err := p.SetFreq(freq); err != nil {
+		return err
+	}
+	return p.Flush()
+}
+
+// Freq returns the frequency last set with SetFreq.
+func (p *PCA9685) Freq() uint32 { return p.freq }
+
+// SetFreq sets the PWM frequency, in Hz, of all channels. The oscillator
+// is stopped while the prescaler is changed.
+func (p *PCA9685) SetFreq(freq uint32) error {
+	if freq == 0 {
+		return errors.New("pca9685: frequency must be non-zero")
+	}
+	// rounded, in 64 bits as 4096*freq overflows a uint32
+	prescale := (uint64(p.Osc)+2048*uint64(freq))/(4096*uint64(freq)) - 1
+	if prescale < 3 || prescale > 0xff {
+		return errors.New("pca9685: frequency out of range")
+	}
+
+	if err := p.write(pca9685RegMode1, pca9685Mode1AI|pca9685Mode1Sleep); err != nil {
+		return err
+	}
+	if err := p.write(pca9685RegPrescale, byte(prescale)); err != nil {
+		return err
+	}
+	if err := p.write(pca9685RegMode1, pca9685Mode1AI); err != nil {
+		return err
+	}
+
+	// oscillator needs 500us to stabilize before restarting
+	time.Sleep(500 * time.Microsecond)
+	if err := p.write(pca9685RegMode1, pca9685Mode1AI|pca9685Mode1Restart); err != nil {
+		return err
+	}
+	p.freq = freq
+	return nil
+}
+
+func (p *PCA9685) write(reg, val uint8) error {
+	_, err := p.rw.Write([]byte{reg, val})
+	return err
+}
+
+func (PCA9685) PinCount() int { return 16 }
+
+func (p *PCA9685) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:       n,
+		SetFunc: p.setPin,
+
+		SetPWMFunc:  p.setPWM,
+		ModesFunc:   p.PinModes,
+		SetModeFunc: fixedMode(p.PinModes),
+	}
+}
+
+func (p *PCA9685) BufferedPin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:       n,
+		SetFunc: p.setPinBuf,
+
+		ModesFunc:   p.PinModes,
+		SetModeFunc: fixedMode(p.PinModes),
+	}
+}
+
+// PinModes returns the modes supported by every pin.
+func (PCA9685) PinModes(int) driver.Mode { return driver.ModePushPull }
+
+func (p *PCA9685) setPinBuf(n int, v bool) error {
+	if v {
+		p.Duty[n] = 0xffff
+	} else {
+		p.Duty[n] = 0
+	}
+	return nil
+}
+
+func (p *PCA9685) setPin(n int, v bool) error {
+	p.setPinBuf(n, v)
+	return p.flushChannel(n)
+}
+
+// SetDuty sets the duty cycle of channel n, where 0xffff is fully on.
+func (p *PCA9685) SetDuty(n int, duty uint16) error {
+	if n < 0 || n >= len(p.Duty) {
+		return errors.New("pca9685: channel out of range")
+	}
+	p.Duty[n] = duty
+	return p.flushChannel(n)
+}
+
+// setPWM sets the duty cycle of channel n, changing the frequency of all
+// channels if freq is different.
+func (p *PCA9685) setPWM(n int, freq uint32, duty uint16) error {
+	if freq != 0 && freq != p.freq {
+		if err := p.SetFreq(freq); err != nil {
+			return err
+		}
+	}
+	return p.SetDuty(n, duty)
+}
+
+func (p *PCA9685) flushChannel(n int) error {
+	buf := make([]byte, 5)
+	buf[0] = pca9685RegLED0 + byte(n)*4
+	p.encode(buf[1:], p.Duty[n])
+	_, err := p.rw.Write(buf)
+	return err
+}
+
+// encode writes the ON and OFF registers of a channel for duty.
+func (p *PCA9685) encode(buf []byte, duty uint16) {
+	buf[0], buf[1], buf[2], buf[3] = 0, 0, 0, 0
+	switch off := uint32(duty) * 4096 / 0xffff; off {
+	case 0:
+		buf[3] = 0x10 // full off
+	case 4096:
+		buf[1] = 0x10 // full on
+	default:
+		buf[2], buf[3] = byte(off), byte(off>>8)
+	}
+}
+
+// Flush writes the duty cycle of all channels.
+func (p *PCA9685) Flush() error {
+	buf := make([]byte, 1+4*len(p.Duty))
+	buf[0] = pca9685RegLED0
+	for i, d := range p.Duty {
+		p.encode(buf[1+i*4:], d)
+	}
+	_, err := p.rw.Write(buf)
+	return err
+}
+
+// Refresh does nothing, as the PCA9685 has no inputs.
+func (p *PCA9685) Refresh() error { return nil }
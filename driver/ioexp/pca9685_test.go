@@ -0,0 +1,40 @@
+package ioexp
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+func TestPCA9685_SetFreq(t *testing.T) {
+	var f fakeI2C
+	p := NewPCA9685X(i2c.NewDevice(&f, 0x20))
+
+	if err := p.SetFreq(50); err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "00 30", "fe 79", "00 20", "00 a0")
+
+	// 4096*freq overflows 32 bits
+	for _, freq := range []uint32{0, 1 << 20, 0xffffffff, 1e6} {
+		if err := p.SetFreq(freq); err == nil {
+			t.Errorf("SetFreq(%d): expected error", freq)
+		}
+	}
+	f.assertLog(t)
+	if p.Freq() != 50 {
+		t.Errorf("Freq() = %d; want 50", p.Freq())
+	}
+}
+
+func TestPCA9685_SetDuty(t *testing.T) {
+	var f fakeI2C
+	p := NewPCA9685X(i2c.NewDevice(&f, 0x20))
+
+	for _, n := range []int{-1, 16, 100} {
+		if err := p.SetDuty(n, 0x8000); err == nil {
+			t.Errorf("SetDuty(%d): expected error", n)
+		}
+	}
+	f.assertLog(t)
+}
@@ -0,0 +1,13 @@
+package ioexp
+
+import "github.com/mastercactapus/embedded/serial/i2c"
+
+// NewPCF8575 is a convenience method that returns a PinReadWriter for a PCF8575-compatible I2C device.
+//
+// Default address is 0x20.
+func NewPCF8575(bus i2c.Bus, addr uint16) *Simple16 {
+	if addr == 0 {
+		addr = 0x20
+	}
+	return NewSimple16(i2c.NewDevice(bus, addr))
+}
@@ -0,0 +1,62 @@
+package ioexp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+// fakePort is a quasi-bidirectional port at 0x20 with no registers:
+// writes set the outputs and reads return in.
+type fakePort struct {
+	fakeRegs
+	in []byte
+}
+
+func (p *fakePort) Tx(addr uint16, w, r []byte) error {
+	if addr != 0x20 {
+		return i2c.ErrNack
+	}
+	if len(w) > 0 {
+		p.log = append(p.log, fmt.Sprintf("% x", w))
+	}
+	if len(r) > 0 {
+		copy(r, p.in)
+		p.log = append(p.log, fmt.Sprintf("r%d", len(r)))
+	}
+	return nil
+}
+
+func TestPCF8575(t *testing.T) {
+	f := &fakePort{in: []byte{0x34, 0x12}}
+	p := NewPCF8575(f, 0)
+
+	// pins are written low byte first
+	if err := p.Pin(9).High(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.Pin(4).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "00 02", "r2")
+	if !v {
+		t.Error("pin 4 = false; want true")
+	}
+
+	if err := p.SetMask(0xff00, 0x1200); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetDirection(0x000f); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.assertLog(t, "00 12", "0f 12", "r2")
+	if got != 0x1234 {
+		t.Errorf("Get() = 0x%04x; want 0x1234", got)
+	}
+}
@@ -10,6 +10,11 @@ type Register16 struct {
 	rw   io.ReadWriter
 	addr uint8
 
+	// addrB, if split is set, is the address of the high byte, which
+	// otherwise follows addr.
+	addrB uint8
+	split bool
+
 	State uint16
 
 	invert uint16
@@ -19,6 +24,17 @@ func NewRegister16(rw io.ReadWriter, addr uint8) *Register16 {
 	return &Register16{addr: addr, rw: rw}
 }
 
+// NewRegister16Split returns a Register16 whose low and high bytes are at
+// separate addresses, accessed one at a time.
+func NewRegister16Split(rw io.ReadWriter, addrA, addrB uint8) *Register16 {
+	return &Register16{addr: addrA, addrB: addrB, split: true, rw: rw}
+}
+
+// setAddr changes the address(es) of the register.
+func (r *Register16) setAddr(addrA, addrB uint8, split bool) {
+	r.addr, r.addrB, r.split = addrA, addrB, split
+}
+
 func (r *Register16) SetInvert(n int, v bool) {
 	if v {
 		r.invert |= 1 << n
@@ -28,7 +44,16 @@ func (r *Register16) SetInvert(n int, v bool) {
 }
 
 func (r *Register16) Flush() error {
-	_, err := r.rw.Write([]byte{r.addr, byte(r.State ^ r.invert), byte((r.State ^ r.invert) >> 8)})
+	v := r.State ^ r.invert
+	if r.split {
+		if _, err := r.rw.Write([]byte{r.addr, byte(v)}); err != nil {
+			return err
+		}
+		_, err := r.rw.Write([]byte{r.addrB, byte(v >> 8)})
+		return err
+	}
+
+	_, err := r.rw.Write([]byte{r.addr, byte(v), byte(v >> 8)})
 	return err
 }
 
@@ -61,7 +86,15 @@ func (r *Register16) Get(n int) (bool, error) {
 
 func (r *Register16) Refresh() error {
 	var buf [2]byte
-	err := serial.Tx(r.rw, []byte{r.addr}, buf[:])
+	var err error
+	if r.split {
+		err = serial.Tx(r.rw, []byte{r.addr}, buf[:1])
+		if err == nil {
+			err = serial.Tx(r.rw, []byte{r.addrB}, buf[1:])
+		}
+	} else {
+		err = serial.Tx(r.rw, []byte{r.addr}, buf[:])
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,189 @@
+package ioexp
+
+import (
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// ShiftChain drives a chain of 74HC595 output registers and a chain of
+// 74HC165 input registers sharing one clock, so that every transfer both
+// writes the outputs and reads the inputs.
+//
+// Pins 0 through Outputs*8-1 are outputs, numbered as with SN74HC595
+// state. The rest are inputs, numbered as with SN74HC165.
+type ShiftChain struct {
+	cfg ShiftChainConfig
+
+	out []uint8
+	in  []uint8
+	err error
+}
+
+type ShiftChainConfig struct {
+	// CLK is connected to SRCLK of the 595s and CLK of the 165s.
+	CLK driver.OutputPin
+
+	SER  driver.OutputPin
+	RCLK driver.OutputPin
+
+	QH driver.InputPin
+
+	// LOAD may be the same pin as RCLK, as the 595 outputs don't change
+	// when loading.
+	LOAD driver.OutputPin
+
+	// LoadHigh makes LOAD active-high, as on a CD4021.
+	LoadHigh bool
+
+	// Outputs and Inputs are the number of chained output and input
+	// registers.
+	Outputs int
+	Inputs  int
+}
+
+func NewShiftChain(cfg ShiftChainConfig) *ShiftChain {
+	return &ShiftChain{
+		cfg: cfg,
+		out: make([]uint8, cfg.Outputs),
+		in:  make([]uint8, cfg.Inputs),
+	}
+}
+
+func (s *ShiftChain) PinCount() int { return (len(s.out) + len(s.in)) * 8 }
+
+func (s *ShiftChain) Pin(n int) driver.Pin {
+	if n < len(s.out)*8 {
+		return &driver.PinFN{
+			N:       n,
+			SetFunc: s.setPin,
+
+			ModesFunc:   s.PinModes,
+			SetModeFunc: fixedMode(s.PinModes),
+		}
+	}
+	return &driver.PinFN{
+		N:       n,
+		GetFunc: s.getPin,
+
+		ModesFunc:   s.PinModes,
+		SetModeFunc: fixedMode(s.PinModes),
+	}
+}
+
+func (s *ShiftChain) BufferedPin(n int) driver.Pin {
+	if n < len(s.out)*8 {
+		return &driver.PinFN{
+			N:       n,
+			SetFunc: s.setPinBuf,
+
+			ModesFunc:   s.PinModes,
+			SetModeFunc: fixedMode(s.PinModes),
+		}
+	}
+	return &driver.PinFN{
+		N:       n,
+		GetFunc: s.getPinBuf,
+
+		ModesFunc:   s.PinModes,
+		SetModeFunc: fixedMode(s.PinModes),
+	}
+}
+
+// PinModes returns push-pull for outputs, and high-Z for inputs.
+func (s *ShiftChain) PinModes(n int) driver.Mode {
+	if n < len(s.out)*8 {
+		return driver.ModePushPull
+	}
+	return driver.ModeHighZ
+}
+
+func (s *ShiftChain) setPinBuf(n int, v bool) error {
+	if v {
+		s.out[n/8] |= 1 << uint(n%8)
+	} else {
+		s.out[n/8] &^= 1 << uint(n%8)
+	}
+	return nil
+}
+
+func (s *ShiftChain) setPin(n int, v bool) error {
+	s.setPinBuf(n, v)
+	return s.transfer()
+}
+
+func (s *ShiftChain) getPinBuf(n int) (bool, error) {
+	n -= len(s.out) * 8
+	return s.in[n/8]&(1<<uint(n%8)) != 0, nil
+}
+
+func (s *ShiftChain) getPin(n int) (bool, error) {
+	if err := s.transfer(); err != nil {
+		return false, err
+	}
+	return s.getPinBuf(n)
+}
+
+// Flush writes the outputs, also reading the inputs.
+func (s *ShiftChain) Flush() error { return s.transfer() }
+
+// Refresh reads the inputs, also writing the outputs.
+func (s *ShiftChain) Refresh() error { return s.transfer() }
+
+func (s *ShiftChain) transfer() error {
+	outBits := len(s.out) * 8
+	inBits := len(s.in) * 8
+	total := outBits
+	if inBits > total {
+		total = inBits
+	}
+
+	in := make([]uint8, len(s.in))
+	s.set(s.cfg.CLK, false)
+	if inBits > 0 {
+		idle := !s.cfg.LoadHigh
+		s.set(s.cfg.LOAD, !idle)
+		s.set(s.cfg.LOAD, idle)
+	}
+
+	for i := 0; i < total; i++ {
+		// inputs are valid for the first clocks, and outputs must be
+		// shifted last, so they end up in place
+		if i < inBits && s.get(s.cfg.QH) {
+			in[i/8] |= 1 << uint(7-i%8)
+		}
+		if j := i - (total - outBits); j >= 0 {
+			s.set(s.cfg.SER, s.out[j/8]&(1<<uint(j%8)) != 0)
+		}
+		s.set(s.cfg.CLK, true)
+		s.set(s.cfg.CLK, false)
+	}
+
+	if outBits > 0 {
+		// latch on the rising edge, leaving RCLK high, so that it also
+		// works as an idle LOAD
+		s.set(s.cfg.RCLK, false)
+		s.set(s.cfg.RCLK, true)
+	}
+
+	err := s.err
+	s.err = nil
+	if err == nil {
+		copy(s.in, in)
+	}
+	return err
+}
+
+func (s *ShiftChain) set(p driver.OutputPin, v bool) {
+	if s.err != nil {
+		return
+	}
+	s.err = p.Set(v)
+}
+
+func (s *ShiftChain) get(p driver.InputPin) bool {
+	if s.err != nil {
+		return false
+	}
+	var v bool
+	v, s.err = p.Get()
+	return v
+}
@@ -0,0 +1,77 @@
+package ioexp
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func TestShiftChain_SharedLoad(t *testing.T) {
+	r := drivertest.NewRecorder()
+	r.Pin("LD").High()
+	r.Reset()
+	qh := r.Pin("QH")
+	sc := NewShiftChain(ShiftChainConfig{
+		CLK:     r.Pin("CLK"),
+		SER:     r.Pin("SER"),
+		RCLK:    r.Pin("LD"),
+		LOAD:    r.Pin("LD"),
+		QH:      qh,
+		Outputs: 1,
+		Inputs:  2,
+	})
+
+	// the output bits are shifted last, after padding for the longer
+	// input chain, and latched by returning LD high
+	qh.Feed("1010 0000 1100 0011")
+	if err := sc.Pin(0).High(); err != nil {
+		t.Fatal(err)
+	}
+	drivertest.AssertWaveform(t, r, `
+		CLK _ __ -_-_-_-_-_-_-_-_ _-_ _-_ -_-_-_-_-_-_ __
+		SER _ __ ________________ --- ___ ____________ __
+		LD  - _- ---------------- --- --- ------------ _-
+	`)
+
+	// inputs follow the outputs, numbered as with SN74HC165
+	for n, want := range map[int]bool{13: true, 14: false, 15: true, 16: true, 17: true, 18: false, 23: true} {
+		v, err := sc.BufferedPin(n).Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Errorf("pin %d = %v; want %v", n, v, want)
+		}
+	}
+}
+
+func TestShiftChain_MoreOutputs(t *testing.T) {
+	r := drivertest.NewRecorder()
+	r.Pin("LOAD").High()
+	r.Reset()
+	qh := r.Pin("QH")
+	sc := NewShiftChain(ShiftChainConfig{
+		CLK:     r.Pin("CLK"),
+		SER:     r.Pin("SER"),
+		RCLK:    r.Pin("RCLK"),
+		LOAD:    r.Pin("LOAD"),
+		QH:      qh,
+		Outputs: 2,
+		Inputs:  1,
+	})
+
+	// inputs are read on the first clocks, and pin 15 is shifted last
+	qh.Feed("0110 1001 1111 1111")
+	if err := sc.Pin(15).High(); err != nil {
+		t.Fatal(err)
+	}
+	drivertest.AssertWaveform(t, r, `
+		CLK  _ __ -_-_-_-_-_-_-_-_-_-_-_-_-_-_-_ _-_ _
+		SER  _ __ ______________________________ --- -
+		RCLK _ __ ______________________________ ___ -
+		LOAD - _- ------------------------------ --- -
+	`)
+	if sc.in[0] != 0x69 {
+		t.Errorf("inputs = 0x%02x; want 0x69", sc.in[0])
+	}
+}
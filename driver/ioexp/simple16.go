@@ -0,0 +1,181 @@
+package ioexp
+
+import (
+	"io"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// Simple16 is a 16-bit quasi-bidirectional port, written and read as two
+// bytes, low byte first. Pins are used as inputs by setting them high.
+type Simple16 struct {
+	rw io.ReadWriter
+
+	State    uint16
+	readData uint16
+
+	irq     *irq
+	intLast uint16
+}
+
+var _ driver.Port = (*Simple16)(nil)
+
+func NewSimple16(rw io.ReadWriter) *Simple16 {
+	return &Simple16{rw: rw}
+}
+
+func (Simple16) PinCount() int { return 16 }
+
+func (s *Simple16) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:            n,
+		SetInputFunc: s.setPin,
+		SetFunc:      s.setPin,
+		GetFunc:      s.getPin,
+
+		SetInterruptFunc: s.setInterrupt,
+		ModesFunc:        s.PinModes,
+		SetModeFunc:      s.setMode,
+	}
+}
+
+func (s *Simple16) BufferedPin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:            n,
+		SetInputFunc: s.setPinB,
+		SetFunc:      s.setPinB,
+		GetFunc:      s.getPinB,
+
+		SetInterruptFunc: s.setInterrupt,
+		ModesFunc:        s.PinModes,
+		SetModeFunc:      s.setModeBuf,
+	}
+}
+
+func (s *Simple16) getPinB(n int) (bool, error) {
+	return s.readData&(1<<uint(n)) != 0, nil
+}
+
+func (s *Simple16) getPin(n int) (bool, error) {
+	if err := s.Refresh(); err != nil {
+		return false, err
+	}
+	return s.getPinB(n)
+}
+
+func (s *Simple16) setPinB(n int, v bool) error {
+	if v {
+		s.State |= 1 << uint(n)
+	} else {
+		s.State &^= 1 << uint(n)
+	}
+	return nil
+}
+
+func (s *Simple16) setPin(n int, v bool) error {
+	s.setPinB(n, v)
+	return s.Flush()
+}
+
+// PinModes returns the modes supported by every pin. Pins are
+// quasi-bidirectional, so a pin set high is weakly pulled up, and can be
+// used as an input.
+func (Simple16) PinModes(int) driver.Mode {
+	return driver.ModeOpenDrain | driver.ModeInputPullup
+}
+
+func (s *Simple16) setModeBuf(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModeOpenDrain:
+		return nil
+	case driver.ModeInputPullup:
+		return s.setPinB(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
+func (s *Simple16) setMode(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModeOpenDrain:
+		return nil
+	case driver.ModeInputPullup:
+		return s.setPin(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
+func (s *Simple16) Refresh() (err error) {
+	s.readData, err = s.read()
+	return err
+}
+
+func (s *Simple16) Flush() error {
+	_, err := s.rw.Write([]byte{byte(s.State), byte(s.State >> 8)})
+	return err
+}
+
+func (s *Simple16) read() (uint16, error) {
+	var buf [2]byte
+	if _, err := io.ReadFull(s.rw, buf[:]); err != nil {
+		return 0, err
+	}
+	return uint16(buf[0]) | uint16(buf[1])<<8, nil
+}
+
+// SetInterruptPin enables pin interrupts, using the INT output of the chip
+// connected to pin, which is asserted when any input changes.
+//
+// Interrupts are handled by a goroutine that reads the device, so it must
+// not be used concurrently while pin interrupts are set.
+func (s *Simple16) SetInterruptPin(pin driver.InterruptPin) error {
+	if s.irq == nil {
+		var err error
+		s.intLast, err = s.read()
+		if err != nil {
+			return err
+		}
+		s.irq = newIRQ(s.readInterrupt)
+	}
+	return s.irq.attach(pin, false)
+}
+
+// readInterrupt reads the inputs, which clears the interrupt, and
+// compares them with the last read.
+func (s *Simple16) readInterrupt() (uint16, uint16, error) {
+	v, err := s.read()
+	if err != nil {
+		return 0, 0, err
+	}
+	changed := v ^ s.intLast
+	s.intLast = v
+	return changed, v, nil
+}
+
+func (s *Simple16) setInterrupt(n int, edge driver.Edge, fn func()) error {
+	if s.irq == nil {
+		return driver.ErrNotSupported
+	}
+	s.irq.set(n, edge, fn)
+	return nil
+}
+
+// SetMask sets the output state of the pins in mask.
+func (s *Simple16) SetMask(mask, value uint64) error {
+	s.State = s.State&^uint16(mask) | uint16(value&mask)
+	return s.Flush()
+}
+
+// Get reads the state of all pins.
+func (s *Simple16) Get() (uint64, error) {
+	if err := s.Refresh(); err != nil {
+		return 0, err
+	}
+	return uint64(s.readData), nil
+}
+
+// SetDirection sets the pins in mask high, so that they can be read as
+// inputs. The rest keep their output state.
+func (s *Simple16) SetDirection(mask uint64) error {
+	s.State |= uint16(mask)
+	return s.Flush()
+}
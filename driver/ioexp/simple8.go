@@ -11,8 +11,13 @@ type Simple8 struct {
 
 	State    uint8
 	readData uint8
+
+	irq     *irq
+	intLast uint8
 }
 
+var _ driver.Port = (*Simple8)(nil)
+
 func NewSimple8(rw io.ReadWriter) *Simple8 {
 	return &Simple8{rw: rw}
 }
@@ -25,6 +30,10 @@ func (s *Simple8) Pin(n int) driver.Pin {
 		SetInputFunc: s.setPin,
 		SetFunc:      s.setPin,
 		GetFunc:      s.getPin,
+
+		SetInterruptFunc: s.setInterrupt,
+		ModesFunc:        s.PinModes,
+		SetModeFunc:      s.setMode,
 	}
 }
 
@@ -34,6 +43,10 @@ func (s *Simple8) BufferedPin(n int) driver.Pin {
 		SetInputFunc: s.setPinB,
 		SetFunc:      s.setPinB,
 		GetFunc:      s.getPinB,
+
+		SetInterruptFunc: s.setInterrupt,
+		ModesFunc:        s.PinModes,
+		SetModeFunc:      s.setModeBuf,
 	}
 }
 
@@ -63,6 +76,33 @@ func (s *Simple8) setPin(n int, v bool) error {
 	return s.Flush()
 }
 
+// PinModes returns the modes supported by every pin. Pins are
+// quasi-bidirectional, so a pin set high is weakly pulled up, and can be
+// used as an input.
+func (Simple8) PinModes(int) driver.Mode {
+	return driver.ModeOpenDrain | driver.ModeInputPullup
+}
+
+func (s *Simple8) setModeBuf(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModeOpenDrain:
+		return nil
+	case driver.ModeInputPullup:
+		return s.setPinB(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
+func (s *Simple8) setMode(n int, mode driver.Mode) error {
+	switch mode {
+	case driver.ModeOpenDrain:
+		return nil
+	case driver.ModeInputPullup:
+		return s.setPin(n, true)
+	}
+	return driver.ErrNotSupported
+}
+
 func (s *Simple8) Refresh() (err error) {
 	s.readData, err = s.read()
 	return err
@@ -86,3 +126,61 @@ func (p *Simple8) read() (b uint8, err error) {
 	_, err = p.rw.Read(buf[:])
 	return buf[0], err
 }
+
+// SetInterruptPin enables pin interrupts, using the INT output of the chip
+// connected to pin, which is asserted when any input changes.
+//
+// Interrupts are handled by a goroutine that reads the device, so it must
+// not be used concurrently while pin interrupts are set.
+func (s *Simple8) SetInterruptPin(pin driver.InterruptPin) error {
+	if s.irq == nil {
+		var err error
+		s.intLast, err = s.read()
+		if err != nil {
+			return err
+		}
+		s.irq = newIRQ(s.readInterrupt)
+	}
+	return s.irq.attach(pin, false)
+}
+
+// readInterrupt reads the inputs, which clears the interrupt, and
+// compares them with the last read.
+func (s *Simple8) readInterrupt() (uint16, uint16, error) {
+	b, err := s.read()
+	if err != nil {
+		return 0, 0, err
+	}
+	changed := b ^ s.intLast
+	s.intLast = b
+	return uint16(changed), uint16(b), nil
+}
+
+func (s *Simple8) setInterrupt(n int, edge driver.Edge, fn func()) error {
+	if s.irq == nil {
+		return driver.ErrNotSupported
+	}
+	s.irq.set(n, edge, fn)
+	return nil
+}
+
+// SetMask sets the output state of the pins in mask.
+func (s *Simple8) SetMask(mask, value uint64) error {
+	s.State = s.State&^uint8(mask) | uint8(value&mask)
+	return s.Flush()
+}
+
+// Get reads the state of all pins.
+func (s *Simple8) Get() (uint64, error) {
+	if err := s.Refresh(); err != nil {
+		return 0, err
+	}
+	return uint64(s.readData), nil
+}
+
+// SetDirection sets the pins in mask high, so that they can be read as
+// inputs. The rest keep their output state.
+func (s *Simple8) SetDirection(mask uint64) error {
+	s.State |= uint8(mask)
+	return s.Flush()
+}
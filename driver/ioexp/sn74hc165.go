@@ -0,0 +1,138 @@
+package ioexp
+
+import (
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// SN74HC165 reads a chain of parallel-in, serial-out shift registers, like
+// the 74HC165 or CD4021.
+//
+// Pin n is input D(n%8) of register n/8, where register 0 is the one
+// connected to QH. On a CD4021, D0-D7 are P1-P8.
+type SN74HC165 struct {
+	cfg SN74HC165Config
+
+	state []uint8
+	err   error
+}
+
+type SN74HC165Config struct {
+	CLK driver.OutputPin
+
+	// LOAD is SH/LD on a 74HC165, or P/S on a CD4021.
+	LOAD driver.OutputPin
+
+	// QH is the serial output of the register, Q8 on a CD4021.
+	QH driver.InputPin
+
+	// LoadHigh makes LOAD active-high, as on a CD4021.
+	LoadHigh bool
+
+	// Count is the number of chained registers, at least 1.
+	Count int
+}
+
+func NewSN74HC165(cfg SN74HC165Config) *SN74HC165 {
+	if cfg.Count < 1 {
+		cfg.Count = 1
+	}
+	return &SN74HC165{
+		cfg:   cfg,
+		state: make([]uint8, cfg.Count),
+	}
+}
+
+// NewCD4021 is a convenience method that returns a SN74HC165 using an
+// active-high LOAD pin.
+func NewCD4021(cfg SN74HC165Config) *SN74HC165 {
+	cfg.LoadHigh = true
+	return NewSN74HC165(cfg)
+}
+
+func (s *SN74HC165) PinCount() int { return len(s.state) * 8 }
+
+func (s *SN74HC165) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:       n,
+		GetFunc: s.getPin,
+
+		ModesFunc:   s.PinModes,
+		SetModeFunc: fixedMode(s.PinModes),
+	}
+}
+
+func (s *SN74HC165) BufferedPin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:       n,
+		GetFunc: s.getPinBuf,
+
+		ModesFunc:   s.PinModes,
+		SetModeFunc: fixedMode(s.PinModes),
+	}
+}
+
+// PinModes returns the modes supported by every pin.
+func (s *SN74HC165) PinModes(int) driver.Mode { return driver.ModeHighZ }
+
+func (s *SN74HC165) getPinBuf(n int) (bool, error) {
+	return s.state[n/8]&(1<<uint(n%8)) != 0, nil
+}
+
+func (s *SN74HC165) getPin(n int) (bool, error) {
+	if err := s.Refresh(); err != nil {
+		return false, err
+	}
+	return s.getPinBuf(n)
+}
+
+// Flush does nothing, as the registers have no outputs.
+func (s *SN74HC165) Flush() error { return nil }
+
+// Refresh loads the inputs and shifts them in.
+func (s *SN74HC165) Refresh() error {
+	s.set(s.cfg.CLK, false)
+	s.pulse(s.cfg.LOAD, !s.cfg.LoadHigh)
+
+	for i := range s.state {
+		s.state[i] = s.readByte(s.cfg.QH, s.cfg.CLK)
+	}
+
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// readByte reads 8 bits from data, most significant first, clocking after
+// each bit.
+func (s *SN74HC165) readByte(data driver.InputPin, clk driver.OutputPin) (b uint8) {
+	for i := 7; i >= 0; i-- {
+		if s.get(data) {
+			b |= 1 << uint(i)
+		}
+		s.set(clk, true)
+		s.set(clk, false)
+	}
+	return b
+}
+
+// pulse sets p to the active level, then back.
+func (s *SN74HC165) pulse(p driver.OutputPin, idle bool) {
+	s.set(p, !idle)
+	s.set(p, idle)
+}
+
+func (s *SN74HC165) set(p driver.OutputPin, v bool) {
+	if s.err != nil {
+		return
+	}
+	s.err = p.Set(v)
+}
+
+func (s *SN74HC165) get(p driver.InputPin) bool {
+	if s.err != nil {
+		return false
+	}
+	var v bool
+	v, s.err = p.Get()
+	return v
+}
@@ -0,0 +1,62 @@
+package ioexp
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func TestSN74HC165(t *testing.T) {
+	r := drivertest.NewRecorder()
+	r.Pin("LOAD").High()
+	r.Reset()
+	qh := r.Pin("QH")
+	sr := NewSN74HC165(SN74HC165Config{
+		CLK:   r.Pin("CLK"),
+		LOAD:  r.Pin("LOAD"),
+		QH:    qh,
+		Count: 2,
+	})
+
+	// register 0 is shifted out first, D7 first
+	qh.Feed("1010 0000 1100 0011")
+	if err := sr.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	drivertest.AssertWaveform(t, r, `
+		CLK  _ __ -_-_-_-_-_-_-_-_ -_-_-_-_-_-_-_-_
+		LOAD - _- ---------------- ----------------
+	`)
+	for n, want := range map[int]bool{5: true, 6: false, 7: true, 8: true, 9: true, 10: false, 14: true, 15: true} {
+		v, err := sr.BufferedPin(n).Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Errorf("pin %d = %v; want %v", n, v, want)
+		}
+	}
+}
+
+func TestCD4021(t *testing.T) {
+	r := drivertest.NewRecorder()
+	qh := r.Pin("QH")
+	sr := NewCD4021(SN74HC165Config{
+		CLK:  r.Pin("CLK"),
+		LOAD: r.Pin("LOAD"),
+		QH:   qh,
+	})
+
+	// LOAD is active-high
+	qh.Feed("0000 0001")
+	if err := sr.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	drivertest.AssertWaveform(t, r, `
+		CLK  _ __ -_-_-_-_-_-_-_-_
+		LOAD _ -_ ________________
+	`)
+	if v, _ := sr.BufferedPin(0).Get(); !v {
+		t.Error("pin 0 = false; want true")
+	}
+}
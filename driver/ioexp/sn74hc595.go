@@ -12,6 +12,8 @@ type SN74HC595 struct {
 	err   error
 }
 
+var _ driver.Port = (*SN74HC595)(nil)
+
 type SN74HC595Config struct {
 	SRCLK driver.OutputPin
 	RCLK  driver.OutputPin
@@ -47,6 +49,9 @@ func (s *SN74HC595) Pin(n int) driver.Pin {
 	return &driver.PinFN{
 		N:       n,
 		SetFunc: s.setPin,
+
+		ModesFunc:   s.PinModes,
+		SetModeFunc: fixedMode(s.PinModes),
 	}
 }
 
@@ -54,9 +59,15 @@ func (s *SN74HC595) BufferedPin(n int) driver.Pin {
 	return &driver.PinFN{
 		N:       n,
 		SetFunc: s.setPinBuf,
+
+		ModesFunc:   s.PinModes,
+		SetModeFunc: fixedMode(s.PinModes),
 	}
 }
 
+// PinModes returns the modes supported by every pin.
+func (s *SN74HC595) PinModes(int) driver.Mode { return driver.ModePushPull }
+
 func (s *SN74HC595) setPinBuf(n int, v bool) error {
 	if v {
 		s.state[n/8] |= 1 << uint(n%8)
@@ -126,3 +137,27 @@ func (s *SN74HC595) writeBit(val bool) {
 	}
 	s.pulse(s.cfg.SRCLK)
 }
+
+// SetMask sets the output state of the pins in mask.
+func (s *SN74HC595) SetMask(mask, value uint64) error {
+	for i := range s.state {
+		if i >= 8 {
+			break
+		}
+		m := uint8(mask >> (uint(i) * 8))
+		s.state[i] = s.state[i]&^m | uint8(value>>(uint(i)*8))&m
+	}
+	return s.Flush()
+}
+
+// Get returns ErrWriteOnly, as the outputs can't be read.
+func (s *SN74HC595) Get() (uint64, error) { return 0, ErrWriteOnly }
+
+// SetDirection returns driver.ErrNotSupported unless mask is zero, as all
+// pins are outputs.
+func (s *SN74HC595) SetDirection(mask uint64) error {
+	if mask != 0 {
+		return driver.ErrNotSupported
+	}
+	return nil
+}
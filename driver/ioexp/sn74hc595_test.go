@@ -0,0 +1,37 @@
+package ioexp
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func TestSN74HC595(t *testing.T) {
+	r := drivertest.NewRecorder()
+	sr := NewSN74HC595(SN74HC595Config{
+		SRCLK: r.Pin("SRCLK"),
+		SER:   r.Pin("SER"),
+		RCLK:  r.Pin("RCLK"),
+	})
+
+	// cleared first, then shifted LSB first and latched
+	if err := sr.Configure(0x81); err != nil {
+		t.Fatal(err)
+	}
+	drivertest.AssertWaveform(t, r, `
+		SRCLK _ -_-_-_-_-_-_-_-_ _-_ _-_ -_-_-_-_-_ _-_ __
+		SER   _ ________________ --- ___ __________ --- --
+		RCLK  _ ________________ ___ ___ __________ ___ -_
+	`)
+
+	// already clear, so only the new state is shifted
+	r.Reset()
+	if err := sr.Pin(1).High(); err != nil {
+		t.Fatal(err)
+	}
+	drivertest.AssertWaveform(t, r, `
+		SRCLK _ -_ -_ _-_ -_-_-_-_ _-_ __
+		SER   - -- -- ___ ________ --- --
+		RCLK  _ __ __ ___ ________ ___ -_
+	`)
+}
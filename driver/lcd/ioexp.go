@@ -35,6 +35,12 @@ type ExpanderConfig struct {
 	DB2 driver.OutputPin
 	DB3 driver.OutputPin
 
+	// Data, if set, is used to access the data lines at once, with bit n
+	// as DBn, instead of the DB_ pins. EightBit selects whether DB0-DB3
+	// are connected.
+	Data     driver.Port
+	EightBit bool
+
 	// Flush, if set, will be called after updating pins for writing.
 	Flush func() error
 
@@ -47,9 +53,15 @@ func NewExpander(cfg ExpanderConfig) *Expander {
 		backlight:      true,
 		ExpanderConfig: cfg,
 	}
+	if cfg.Data != nil {
+		exp.eightBitMode = cfg.EightBit
+		return exp
+	}
+
 	if cfg.DB0 != nil && cfg.DB1 != nil && cfg.DB2 != nil && cfg.DB3 != nil {
 		exp.eightBitMode = true
 	}
+	exp.Data = driver.PortFromPins(cfg.DB0, cfg.DB1, cfg.DB2, cfg.DB3, cfg.DB4, cfg.DB5, cfg.DB6, cfg.DB7)
 
 	return exp
 }
@@ -63,43 +75,32 @@ func (e *Expander) setPin(o driver.OutputPin, value bool) {
 	e.err = o.Set(value)
 }
 
-func (e *Expander) getPin(o driver.OutputPin) byte {
+func (e *Expander) setData(mask, data byte) {
 	if e.err != nil {
-		return 0
+		return
 	}
-	if ip, ok := o.(driver.InputPin); ok {
-		var v bool
-		v, e.err = ip.Get()
-		if errors.Is(e.err, driver.ErrNotSupported) {
-			e.writeOnly = true
-		}
-		if v {
-			return 1
-		}
+	e.err = e.Data.SetMask(uint64(mask), uint64(data))
+}
+
+func (e *Expander) getData() byte {
+	if e.err != nil {
 		return 0
 	}
-	e.writeOnly = true
-	e.err = driver.ErrNotSupported
-	return 0
+	var v uint64
+	v, e.err = e.Data.Get()
+	if errors.Is(e.err, driver.ErrNotSupported) {
+		e.writeOnly = true
+	}
+	return byte(v)
 }
 
 func (e *Expander) write8Bits(data byte) {
-	e.setPin(e.DB0, data&(1<<0) != 0)
-	e.setPin(e.DB1, data&(1<<1) != 0)
-	e.setPin(e.DB2, data&(1<<2) != 0)
-	e.setPin(e.DB3, data&(1<<3) != 0)
-	e.setPin(e.DB4, data&(1<<4) != 0)
-	e.setPin(e.DB5, data&(1<<5) != 0)
-	e.setPin(e.DB6, data&(1<<6) != 0)
-	e.setPin(e.DB7, data&(1<<7) != 0)
+	e.setData(0xff, data)
 	e.pulseWrite()
 }
 
 func (e *Expander) write4Bits(data byte) {
-	e.setPin(e.DB4, data&(1<<4) != 0)
-	e.setPin(e.DB5, data&(1<<5) != 0)
-	e.setPin(e.DB6, data&(1<<6) != 0)
-	e.setPin(e.DB7, data&(1<<7) != 0)
+	e.setData(0xf0, data)
 	e.pulseWrite()
 }
 
@@ -126,26 +127,14 @@ func (e *Expander) writeByte(data byte) {
 	}
 }
 
-func (e *Expander) read8Bits() (b byte) {
+func (e *Expander) read8Bits() byte {
 	e.refresh()
-	b |= e.getPin(e.DB0) << 0
-	b |= e.getPin(e.DB1) << 1
-	b |= e.getPin(e.DB2) << 2
-	b |= e.getPin(e.DB3) << 3
-	b |= e.getPin(e.DB4) << 4
-	b |= e.getPin(e.DB5) << 5
-	b |= e.getPin(e.DB6) << 6
-	b |= e.getPin(e.DB7) << 7
-	return b
+	return e.getData()
 }
 
-func (e *Expander) read4Bits() (b byte) {
+func (e *Expander) read4Bits() byte {
 	e.refresh()
-	b |= e.getPin(e.DB4) << 4
-	b |= e.getPin(e.DB5) << 5
-	b |= e.getPin(e.DB6) << 6
-	b |= e.getPin(e.DB7) << 7
-	return b
+	return e.getData() & 0xf0
 }
 
 func (e *Expander) eHigh() { e.setPin(e.E, true); e.flush() }
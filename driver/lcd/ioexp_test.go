@@ -0,0 +1,65 @@
+package lcd
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func newTestExpander() (*Expander, *drivertest.Recorder) {
+	r := drivertest.NewRecorder()
+	return NewExpander(ExpanderConfig{
+		RS:  r.Pin("RS"),
+		RW:  r.Pin("RW"),
+		E:   r.Pin("E"),
+		BL:  r.Pin("BL"),
+		DB4: r.Pin("DB4"),
+		DB5: r.Pin("DB5"),
+		DB6: r.Pin("DB6"),
+		DB7: r.Pin("DB7"),
+	}), r
+}
+
+func TestExpander_WriteByte(t *testing.T) {
+	e, r := newTestExpander()
+	if e.IsEightBitMode() {
+		t.Fatal("expected 4-bit mode")
+	}
+
+	if err := e.WriteByte(0xa5); err != nil {
+		t.Fatal(err)
+	}
+
+	// high nibble first, each latched on the falling edge of E
+	drivertest.AssertWaveform(t, r, `
+		RS  _- ---- ------
+		RW  __ ____ ______
+		E   __ __-_ ____-_
+		DB4 __ ____ ------
+		DB5 __ ---- -_____
+		DB6 __ ____ __----
+		DB7 __ _--- ---___
+	`)
+}
+
+func TestExpander_ReadByte(t *testing.T) {
+	e, r := newTestExpander()
+	r.Pin("DB4").Feed("10")
+	r.Pin("DB5").Feed("10")
+	r.Pin("DB6").Feed("01")
+	r.Pin("DB7").Feed("01")
+
+	b, err := e.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != 0x3c {
+		t.Errorf("read %x; want 3c", b)
+	}
+
+	drivertest.AssertWaveform(t, r, `
+		RS _-- ----
+		RW __- ----
+		E  ___ -_-_
+	`)
+}
@@ -38,3 +38,94 @@ func (p machinePin) Output() error {
 	machine.Pin(p).Configure(machine.PinConfig{Mode: machine.PinOutput})
 	return nil
 }
+
+// SetPull configures the pin as an input using the given pull resistor.
+func (p machinePin) SetPull(pull Pull) error {
+	mode := machine.PinInput
+	switch pull {
+	case PullUp:
+		mode = machine.PinInputPullup
+	case PullDown:
+		mode = machine.PinInputPulldown
+	}
+	machine.Pin(p).Configure(machine.PinConfig{Mode: mode})
+	return nil
+}
+
+// Modes returns the modes available on all TinyGo targets.
+func (p machinePin) Modes() Mode {
+	return ModePushPull | ModeHighZ | ModeInputPullup | ModeInputPulldown
+}
+
+func (p machinePin) SetMode(mode Mode) error {
+	var m machine.PinMode
+	switch mode {
+	case ModePushPull:
+		m = machine.PinOutput
+	case ModeHighZ:
+		m = machine.PinInput
+	case ModeInputPullup:
+		m = machine.PinInputPullup
+	case ModeInputPulldown:
+		m = machine.PinInputPulldown
+	default:
+		return ErrNotSupported
+	}
+	machine.Pin(p).Configure(machine.PinConfig{Mode: m})
+	return nil
+}
+
+// SetInterrupt uses the pin change interrupt of the pin.
+func (p machinePin) SetInterrupt(edge Edge, fn func()) error {
+	if fn == nil || edge == EdgeNone {
+		return machine.Pin(p).SetInterrupt(0, nil)
+	}
+
+	var change machine.PinChange
+	switch edge {
+	case EdgeRising:
+		change = machine.PinRising
+	case EdgeFalling:
+		change = machine.PinFalling
+	default:
+		change = machine.PinToggle
+	}
+	return machine.Pin(p).SetInterrupt(change, func(machine.Pin) { fn() })
+}
+
+type machinePort []machine.Pin
+
+// PortFromMachine returns a Port for pins, with bit n as pins[n].
+func PortFromMachine(pins ...machine.Pin) Port {
+	return machinePort(pins)
+}
+
+func (p machinePort) SetMask(mask, value uint64) error {
+	for n, pin := range p {
+		if mask&(1<<uint(n)) != 0 {
+			pin.Set(value&(1<<uint(n)) != 0)
+		}
+	}
+	return nil
+}
+
+func (p machinePort) Get() (uint64, error) {
+	var v uint64
+	for n, pin := range p {
+		if pin.Get() {
+			v |= 1 << uint(n)
+		}
+	}
+	return v, nil
+}
+
+func (p machinePort) SetDirection(mask uint64) error {
+	for n, pin := range p {
+		mode := machine.PinOutput
+		if mask&(1<<uint(n)) != 0 {
+			mode = machine.PinInput
+		}
+		pin.Configure(machine.PinConfig{Mode: mode})
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package driver
+
+import (
+	"errors"
+	"strings"
+)
+
+// Mode is the electrical configuration of a pin. Modes are bit flags, so
+// that a set of supported modes is also a Mode.
+type Mode uint8
+
+const (
+	// ModePushPull drives the pin both high and low.
+	ModePushPull Mode = 1 << iota
+
+	// ModeOpenDrain drives the pin low, and releases it for high.
+	ModeOpenDrain
+
+	// ModeHighZ is an input without a pull resistor.
+	ModeHighZ
+
+	// ModeInputPullup is an input with a pull-up resistor.
+	ModeInputPullup
+
+	// ModeInputPulldown is an input with a pull-down resistor.
+	ModeInputPulldown
+)
+
+var modeNames = []string{"push-pull", "open-drain", "high-z", "input-pullup", "input-pulldown"}
+
+// Has returns true if all modes in mode are in m.
+func (m Mode) Has(mode Mode) bool { return mode != 0 && m&mode == mode }
+
+// String returns the names of the modes in m, separated by commas.
+func (m Mode) String() string {
+	var names []string
+	for i, name := range modeNames {
+		if m&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseMode returns the Mode with the given name, as returned by String.
+func ParseMode(s string) (Mode, error) {
+	for i, name := range modeNames {
+		if s == name {
+			return 1 << uint(i), nil
+		}
+	}
+	return 0, errors.New("unknown pin mode '" + s + "'")
+}
+
+// ModePin is implemented by pins with a configurable mode.
+type ModePin interface {
+	// Modes returns the set of modes supported by the pin.
+	Modes() Mode
+
+	// SetMode configures the pin, returning ErrNotSupported for an
+	// unsupported mode.
+	SetMode(Mode) error
+}
+
+// ModePinner is implemented by Pinners that report the modes supported by
+// each pin.
+type ModePinner interface {
+	PinModes(n int) Mode
+}
+
+// PinModes returns the modes supported by pin n of p, or zero if unknown.
+func PinModes(p Pinner, n int) Mode {
+	if mp, ok := p.(ModePinner); ok {
+		return mp.PinModes(n)
+	}
+	if mp, ok := p.Pin(n).(ModePin); ok {
+		return mp.Modes()
+	}
+	return 0
+}
+
+type openDrainPin struct{ Pin }
+
+// OpenDrain returns a Pin that is only driven low, using the open-drain
+// mode of p if supported. Otherwise it is emulated by switching p to an
+// input for high, which relies on an external pull-up.
+//
+// If p can't be switched to an input, such as a quasi-bidirectional pin,
+// it is driven high and low instead.
+func OpenDrain(p Pin) Pin {
+	if mp, ok := p.(ModePin); ok && mp.Modes().Has(ModeOpenDrain) {
+		if err := mp.SetMode(ModeOpenDrain); err == nil {
+			return p
+		}
+	}
+	return openDrainPin{p}
+}
+
+func (p openDrainPin) Set(v bool) error {
+	if v {
+		return p.High()
+	}
+	return p.Low()
+}
+
+func (p openDrainPin) High() error {
+	if err := p.Pin.Input(); err != nil && !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+	return p.Pin.High()
+}
+
+// Low sets the level before switching to an output, so the pin is never
+// driven high.
+func (p openDrainPin) Low() error {
+	if err := p.Pin.Low(); err != nil {
+		return err
+	}
+	if err := p.Pin.Output(); err != nil && !errors.Is(err, ErrNotSupported) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package driver
+
+import "testing"
+
+func TestMode(t *testing.T) {
+	m := ModePushPull | ModeInputPullup
+	if s := m.String(); s != "push-pull,input-pullup" {
+		t.Errorf("String() = %s; want push-pull,input-pullup", s)
+	}
+	if !m.Has(ModeInputPullup) || m.Has(ModeOpenDrain) || m.Has(0) {
+		t.Errorf("Has: wrong result for %s", m)
+	}
+
+	for _, mode := range []Mode{ModePushPull, ModeOpenDrain, ModeHighZ, ModeInputPullup, ModeInputPulldown} {
+		got, err := ParseMode(mode.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != mode {
+			t.Errorf("ParseMode(%s) = %s", mode, got)
+		}
+	}
+	if _, err := ParseMode("bogus"); err == nil {
+		t.Error("ParseMode(bogus): expected error")
+	}
+}
+
+func TestOpenDrain(t *testing.T) {
+	var level, input bool
+	var mode Mode
+	pin := PinFN{
+		SetFunc:      func(_ int, v bool) error { level = v; return nil },
+		SetInputFunc: func(_ int, v bool) error { input = v; return nil },
+	}
+
+	// emulated by switching to input
+	od := OpenDrain(pin)
+	if err := od.High(); err != nil {
+		t.Fatal(err)
+	}
+	if !input {
+		t.Error("emulated high: expected input")
+	}
+	if err := od.Low(); err != nil {
+		t.Fatal(err)
+	}
+	if input || level {
+		t.Error("emulated low: expected low output")
+	}
+
+	// pins that can't be inputs are driven instead
+	drive := PinFN{SetFunc: func(_ int, v bool) error { level = v; return nil }}
+	od = OpenDrain(drive)
+	if err := od.High(); err != nil {
+		t.Fatal(err)
+	}
+	if !level {
+		t.Error("driven high: expected high output")
+	}
+	if err := od.Low(); err != nil {
+		t.Fatal(err)
+	}
+	if level {
+		t.Error("driven low: expected low output")
+	}
+
+	// native open-drain pins are used as-is
+	pin.ModesFunc = func(int) Mode { return ModePushPull | ModeOpenDrain }
+	pin.SetModeFunc = func(_ int, m Mode) error { mode = m; return nil }
+	od = OpenDrain(pin)
+	if mode != ModeOpenDrain {
+		t.Errorf("mode = %s; want open-drain", mode)
+	}
+	if err := od.High(); err != nil {
+		t.Fatal(err)
+	}
+	if input || !level {
+		t.Error("native high: expected high output")
+	}
+}
@@ -0,0 +1,217 @@
+package driver
+
+import "errors"
+
+// PinMap is a Pinner whose pins are taken from other Pinners, so that
+// several devices can share one numbering, or a board's pinout can be
+// described once. Pins may be inverted, for active-low signals, and named.
+//
+// Source Pinners are compared to find each distinct device, so they must
+// be comparable, as pointers are.
+type PinMap struct {
+	pins []mapPin
+	srcs []Pinner
+}
+
+type mapPin struct {
+	src    Pinner
+	n      int
+	invert bool
+	name   string
+}
+
+var _ BufferedPinner = (*PinMap)(nil)
+
+// NewPinMap returns an empty PinMap.
+func NewPinMap() *PinMap { return &PinMap{} }
+
+// Concat returns a PinMap numbering all pins of each of pinners in turn.
+func Concat(pinners ...Pinner) *PinMap {
+	m := NewPinMap()
+	for _, p := range pinners {
+		for i := 0; i < p.PinCount(); i++ {
+			m.Add(p, i)
+		}
+	}
+	return m
+}
+
+// Select returns a PinMap where pin n is pin table[n] of p.
+func Select(p Pinner, table ...int) *PinMap {
+	m := NewPinMap()
+	for _, n := range table {
+		m.Add(p, n)
+	}
+	return m
+}
+
+// Add adds pin n of p, returning its number. Pins of another PinMap are
+// added from their source, keeping their name and inversion.
+func (m *PinMap) Add(p Pinner, n int) int {
+	mp := mapPin{src: p, n: n}
+	if pm, ok := p.(*PinMap); ok {
+		mp = pm.pins[n]
+		p = mp.src
+	}
+	m.pins = append(m.pins, mp)
+
+	found := false
+	for _, s := range m.srcs {
+		if s == p {
+			found = true
+			break
+		}
+	}
+	if !found {
+		m.srcs = append(m.srcs, p)
+	}
+
+	return len(m.pins) - 1
+}
+
+// SetInvert sets whether pin n is inverted, for both reads and writes.
+func (m *PinMap) SetInvert(n int, v bool) { m.pins[n].invert = v }
+
+// SetName sets the name of pin n.
+func (m *PinMap) SetName(n int, name string) { m.pins[n].name = name }
+
+// Name returns the name of pin n, if any.
+func (m *PinMap) Name(n int) string { return m.pins[n].name }
+
+// Lookup returns the number of the pin with the given name.
+func (m *PinMap) Lookup(name string) (int, bool) {
+	for i, p := range m.pins {
+		if p.name != "" && p.name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (m *PinMap) PinCount() int { return len(m.pins) }
+
+// PinModes returns the modes supported by the source pin of n.
+func (m *PinMap) PinModes(n int) Mode {
+	p := m.pins[n]
+	return PinModes(p.src, p.n)
+}
+
+func (m *PinMap) Pin(n int) Pin {
+	p := m.pins[n]
+	return m.wrap(p, p.src.Pin(p.n))
+}
+
+// BufferedPin returns the buffered pin of the source, if it is a
+// BufferedPinner, or its regular pin otherwise.
+func (m *PinMap) BufferedPin(n int) Pin {
+	p := m.pins[n]
+	if bp, ok := p.src.(BufferedPinner); ok {
+		return m.wrap(p, bp.BufferedPin(p.n))
+	}
+	return m.wrap(p, p.src.Pin(p.n))
+}
+
+func (m *PinMap) wrap(p mapPin, pin Pin) Pin {
+	if p.invert {
+		return InvertPin(pin)
+	}
+	return pin
+}
+
+// Flush calls Flush on each source that is a BufferedPinner.
+func (m *PinMap) Flush() error {
+	var errs []error
+	for _, s := range m.srcs {
+		if bp, ok := s.(BufferedPinner); ok {
+			errs = append(errs, bp.Flush())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Refresh calls Refresh on each source that is a BufferedPinner.
+func (m *PinMap) Refresh() error {
+	var errs []error
+	for _, s := range m.srcs {
+		if bp, ok := s.(BufferedPinner); ok {
+			errs = append(errs, bp.Refresh())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type invertPin struct{ Pin }
+
+var (
+	_ PullPin      = invertPin{}
+	_ PWMPin       = invertPin{}
+	_ InterruptPin = invertPin{}
+	_ ModePin      = invertPin{}
+)
+
+// InvertPin returns a Pin with inverted logic, for active-low signals.
+// Interrupt edges and PWM duty cycles are inverted to match.
+func InvertPin(p Pin) Pin {
+	if inv, ok := p.(invertPin); ok {
+		return inv.Pin
+	}
+	return invertPin{p}
+}
+
+func (p invertPin) Set(v bool) error { return p.Pin.Set(!v) }
+func (p invertPin) High() error      { return p.Pin.Set(false) }
+func (p invertPin) Low() error       { return p.Pin.Set(true) }
+
+func (p invertPin) Get() (bool, error) {
+	v, err := p.Pin.Get()
+	if err != nil {
+		return false, err
+	}
+	return !v, nil
+}
+
+func (p invertPin) SetPull(pull Pull) error {
+	pp, ok := p.Pin.(PullPin)
+	if !ok {
+		return ErrNotSupported
+	}
+	return pp.SetPull(pull)
+}
+
+func (p invertPin) Modes() Mode {
+	mp, ok := p.Pin.(ModePin)
+	if !ok {
+		return 0
+	}
+	return mp.Modes()
+}
+
+func (p invertPin) SetMode(mode Mode) error {
+	mp, ok := p.Pin.(ModePin)
+	if !ok {
+		return ErrNotSupported
+	}
+	return mp.SetMode(mode)
+}
+
+func (p invertPin) SetPWM(freq uint32, duty uint16) error {
+	pwm, ok := p.Pin.(PWMPin)
+	if !ok {
+		return ErrNotSupported
+	}
+	return pwm.SetPWM(freq, 0xffff-duty)
+}
+
+func (p invertPin) SetInterrupt(edge Edge, fn func()) error {
+	ip, ok := p.Pin.(InterruptPin)
+	if !ok {
+		return ErrNotSupported
+	}
+	switch edge {
+	case EdgeRising:
+		edge = EdgeFalling
+	case EdgeFalling:
+		edge = EdgeRising
+	}
+	return ip.SetInterrupt(edge, fn)
+}
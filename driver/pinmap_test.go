@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+)
+
+type testPinner struct {
+	state   []bool
+	flushes int
+}
+
+func (p *testPinner) PinCount() int { return len(p.state) }
+
+func (p *testPinner) Pin(n int) Pin {
+	return PinFN{
+		N:       n,
+		SetFunc: func(n int, v bool) error { p.state[n] = v; return nil },
+		GetFunc: func(n int) (bool, error) { return p.state[n], nil },
+	}
+}
+
+func (p *testPinner) BufferedPin(n int) Pin { return p.Pin(n) }
+func (p *testPinner) Flush() error          { p.flushes++; return nil }
+func (p *testPinner) Refresh() error        { return nil }
+
+func TestPinMap(t *testing.T) {
+	a := &testPinner{state: make([]bool, 2)}
+	b := &testPinner{state: make([]bool, 3)}
+
+	m := Concat(a, b, Select(a, 1, 0))
+	if m.PinCount() != 7 {
+		t.Fatalf("PinCount() = %d; want 7", m.PinCount())
+	}
+	m.SetInvert(2, true)
+
+	if err := m.Pin(1).High(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Pin(2).High(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.state[1] || b.state[0] {
+		t.Errorf("got a=%v b=%v; want a[1] set, b[0] clear", a.state, b.state)
+	}
+
+	// pin 5 is a[1], through the nested Select
+	v, err := m.Pin(5).Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v {
+		t.Error("pin 5: got low; want high")
+	}
+
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if a.flushes != 1 || b.flushes != 1 {
+		t.Errorf("flushes: a=%d b=%d; want 1 each", a.flushes, b.flushes)
+	}
+}
+
+func TestLoadBoard(t *testing.T) {
+	p := &testPinner{state: make([]bool, 4)}
+	m, err := LoadBoard(p, strings.NewReader(`
+# test board
+LED   1
+RESET 3 invert
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, ok := m.Lookup("RESET")
+	if !ok || n != 3 {
+		t.Fatalf("Lookup(RESET) = %d, %v; want 3, true", n, ok)
+	}
+	if err := m.Pin(n).Low(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.state[3] {
+		t.Error("RESET: got low; want high (inverted)")
+	}
+	if m.Name(1) != "LED" {
+		t.Errorf("Name(1) = %s; want LED", m.Name(1))
+	}
+
+	for _, bad := range []string{"LED 9", "LED x", "LED 1\nLED 2", "LED 1 foo", "5 1"} {
+		if _, err := LoadBoard(p, strings.NewReader(bad)); err == nil {
+			t.Errorf("LoadBoard(%q): expected error", bad)
+		}
+	}
+}
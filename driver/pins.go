@@ -56,8 +56,25 @@ type PinFN struct {
 	SetInputFunc func(int, bool) error
 	SetFunc      func(int, bool) error
 	GetFunc      func(int) (bool, error)
+
+	SetPullFunc    func(int, Pull) error
+	ReadAnalogFunc func(int) (uint16, uint16, error)
+	SetPWMFunc     func(int, uint32, uint16) error
+
+	SetInterruptFunc func(int, Edge, func()) error
+
+	ModesFunc   func(int) Mode
+	SetModeFunc func(int, Mode) error
 }
 
+var (
+	_ PullPin      = PinFN{}
+	_ AnalogInput  = PinFN{}
+	_ PWMPin       = PinFN{}
+	_ InterruptPin = PinFN{}
+	_ ModePin      = PinFN{}
+)
+
 func (p PinFN) SetInput(v bool) error {
 	if p.SetInputFunc == nil {
 		return ErrNotSupported
@@ -84,6 +101,48 @@ func (p PinFN) Get() (bool, error) {
 	return p.GetFunc(p.N)
 }
 
+func (p PinFN) SetPull(pull Pull) error {
+	if p.SetPullFunc == nil {
+		return ErrNotSupported
+	}
+	return p.SetPullFunc(p.N, pull)
+}
+
+func (p PinFN) ReadAnalog() (uint16, uint16, error) {
+	if p.ReadAnalogFunc == nil {
+		return 0, 0, ErrNotSupported
+	}
+	return p.ReadAnalogFunc(p.N)
+}
+
+func (p PinFN) SetPWM(freq uint32, duty uint16) error {
+	if p.SetPWMFunc == nil {
+		return ErrNotSupported
+	}
+	return p.SetPWMFunc(p.N, freq, duty)
+}
+
+func (p PinFN) SetInterrupt(edge Edge, fn func()) error {
+	if p.SetInterruptFunc == nil {
+		return ErrNotSupported
+	}
+	return p.SetInterruptFunc(p.N, edge, fn)
+}
+
+func (p PinFN) Modes() Mode {
+	if p.ModesFunc == nil {
+		return 0
+	}
+	return p.ModesFunc(p.N)
+}
+
+func (p PinFN) SetMode(mode Mode) error {
+	if p.SetModeFunc == nil {
+		return ErrNotSupported
+	}
+	return p.SetModeFunc(p.N, mode)
+}
+
 type PinF struct {
 	SetInputFunc func(bool) error
 	SetFunc      func(bool) error
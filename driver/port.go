@@ -0,0 +1,75 @@
+package driver
+
+// Port is implemented by devices that can access many pins at once. Bit n
+// of each mask and value is pin n.
+type Port interface {
+	// SetMask sets the output state of the pins in mask to the
+	// corresponding bits of value, leaving the rest unchanged.
+	SetMask(mask, value uint64) error
+
+	// Get returns the state of all pins.
+	Get() (uint64, error)
+
+	// SetDirection makes the pins in mask inputs, and the rest outputs.
+	SetDirection(mask uint64) error
+}
+
+type pinPort []OutputPin
+
+// PortFromPins returns a Port that accesses each pin in turn, with bit n
+// as pins[n]. Nil pins are ignored.
+//
+// Get and SetDirection return ErrNotSupported if a pin does not implement
+// InputPin or OCPin, respectively.
+func PortFromPins(pins ...OutputPin) Port {
+	return pinPort(pins)
+}
+
+func (p pinPort) SetMask(mask, value uint64) error {
+	for n, pin := range p {
+		if pin == nil || mask&(1<<uint(n)) == 0 {
+			continue
+		}
+		if err := pin.Set(value&(1<<uint(n)) != 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p pinPort) Get() (uint64, error) {
+	var v uint64
+	for n, pin := range p {
+		if pin == nil {
+			continue
+		}
+		in, ok := pin.(InputPin)
+		if !ok {
+			return 0, ErrNotSupported
+		}
+		high, err := in.Get()
+		if err != nil {
+			return 0, err
+		}
+		if high {
+			v |= 1 << uint(n)
+		}
+	}
+	return v, nil
+}
+
+func (p pinPort) SetDirection(mask uint64) error {
+	for n, pin := range p {
+		if pin == nil {
+			continue
+		}
+		oc, ok := pin.(OCPin)
+		if !ok {
+			return ErrNotSupported
+		}
+		if err := oc.SetInput(mask&(1<<uint(n)) != 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPortFromPins(t *testing.T) {
+	var state, input [3]bool
+	pins := make([]OutputPin, 4)
+	for i := range state {
+		pins[i] = PinFN{
+			N:            i,
+			SetFunc:      func(n int, v bool) error { state[n] = v; return nil },
+			GetFunc:      func(n int) (bool, error) { return state[n], nil },
+			SetInputFunc: func(n int, v bool) error { input[n] = v; return nil },
+		}
+	}
+	// pin 3 is left nil
+
+	p := PortFromPins(pins...)
+	if err := p.SetMask(0b1111, 0b0101); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetMask(0b0010, 0b1111); err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0b0111 {
+		t.Errorf("Get() = %b; want 111", v)
+	}
+
+	if err := p.SetDirection(0b0100); err != nil {
+		t.Fatal(err)
+	}
+	if input != [3]bool{false, false, true} {
+		t.Errorf("inputs = %v; want [false false true]", input)
+	}
+
+	wo := PortFromPins(PinF{SetFunc: func(bool) error { return nil }}, &writeOnlyPin{})
+	if _, err := wo.Get(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Get() on write-only pins: got %v; want ErrNotSupported", err)
+	}
+}
+
+type writeOnlyPin struct{}
+
+func (writeOnlyPin) High() error    { return nil }
+func (writeOnlyPin) Low() error     { return nil }
+func (writeOnlyPin) Set(bool) error { return nil }
@@ -0,0 +1,33 @@
+package stepper
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func TestDirect(t *testing.T) {
+	r := drivertest.NewRecorder()
+	d := New4Phase(r.Pin("A"), r.Pin("B"), r.Pin("C"), r.Pin("D"))
+
+	for i := 0; i < 4; i++ {
+		if err := d.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Reverse = true
+	if err := d.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Off(); err != nil {
+		t.Fatal(err)
+	}
+
+	// pins are set in order, so the next phase is on briefly with the last
+	drivertest.AssertWaveform(t, r, `
+		A _ _ __ __ -- __ _
+		B _ _ __ -- -_ _- _
+		C _ _ -- -_ __ __ _
+		D _ - -_ __ __ __ _
+	`)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"errors"
 	"machine"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/xb"
 )
 
 var pins = [12]machine.Pin{
@@ -22,10 +24,10 @@ var pins = [12]machine.Pin{
 
 func setPin(n int, v bool) error {
 	if n >= len(pins) {
-		return errors.New("xb: invalid pin")
+		return xb.ErrBadPin
 	}
 	if n < 0 {
-		return errors.New("xb: invalid pin")
+		return xb.ErrBadPin
 	}
 
 	pins[n].Set(v)
@@ -34,10 +36,10 @@ func setPin(n int, v bool) error {
 
 func getPin(n int) (bool, error) {
 	if n >= len(pins) {
-		return false, errors.New("xb: invalid pin")
+		return false, xb.ErrBadPin
 	}
 	if n < 0 {
-		return false, errors.New("xb: invalid pin")
+		return false, xb.ErrBadPin
 	}
 
 	return pins[n].Get(), nil
@@ -45,17 +47,90 @@ func getPin(n int) (bool, error) {
 
 func setInputPin(n int, v bool) error {
 	if n >= len(pins) {
-		return errors.New("xb: invalid pin")
+		return xb.ErrBadPin
 	}
 	if n < 0 {
-		return errors.New("xb: invalid pin")
+		return xb.ErrBadPin
 	}
 
 	if v {
-		pins[n].Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+		pins[n].Configure(machine.PinConfig{Mode: inputMode(pulls[n])})
 	} else {
 		pins[n].Configure(machine.PinConfig{Mode: machine.PinOutput})
 	}
 
 	return nil
 }
+
+// pulls holds the pull resistor used when each pin is an input.
+var pulls = [len(pins)]driver.Pull{
+	driver.PullUp, driver.PullUp, driver.PullUp, driver.PullUp,
+	driver.PullUp, driver.PullUp, driver.PullUp, driver.PullUp,
+	driver.PullUp, driver.PullUp, driver.PullUp, driver.PullUp,
+}
+
+func inputMode(pull driver.Pull) machine.PinMode {
+	switch pull {
+	case driver.PullUp:
+		return machine.PinInputPullup
+	case driver.PullDown:
+		return machine.PinInputPulldown
+	}
+	return machine.PinInput
+}
+
+// setPull sets the pull resistor and switches the pin to input.
+func setPull(n int, pull driver.Pull) error {
+	if n >= len(pins) || n < 0 {
+		return xb.ErrBadPin
+	}
+
+	pulls[n] = pull
+	return setInputPin(n, true)
+}
+
+// adcRefMV is the ADC reference, VDDANA, with the default gain.
+const adcRefMV = 3300
+
+var adcInit bool
+
+func readAnalog(n int) (uint16, uint16, error) {
+	// D0-D10 are all analog capable, the LED is not.
+	if n >= len(pins)-1 || n < 0 {
+		return 0, 0, xb.ErrBadPin
+	}
+	if !adcInit {
+		machine.InitADC()
+		adcInit = true
+	}
+
+	adc := machine.ADC{Pin: pins[n]}
+	adc.Configure(machine.ADCConfig{})
+	return adc.Get(), adcRefMV, nil
+}
+
+var pwms = [...]*machine.TCC{machine.TCC0, machine.TCC1, machine.TCC2}
+
+// setPWM drives the pin from the first timer it is attached to. All
+// pins on the same timer share the frequency.
+func setPWM(n int, freq uint32, duty uint16) error {
+	if n >= len(pins) || n < 0 {
+		return xb.ErrBadPin
+	}
+
+	for _, pwm := range pwms {
+		ch, err := pwm.Channel(pins[n])
+		if err != nil {
+			continue
+		}
+
+		err = pwm.Configure(machine.PWMConfig{Period: 1e9 / uint64(freq)})
+		if err != nil {
+			return err
+		}
+		pwm.Set(ch, uint32(uint64(pwm.Top())*uint64(duty)/0xffff))
+		return nil
+	}
+
+	return driver.ErrNotSupported
+}
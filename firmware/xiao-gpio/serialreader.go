@@ -1,17 +1,24 @@
 package main
 
-import "machine"
+import (
+	"machine"
+	"runtime"
+)
 
 type serialReader struct{}
 
+// Read yields while waiting so that other goroutines, such as
+// the xb pin watcher, can run.
 func (serialReader) Read(p []byte) (int, error) {
 	for machine.Serial.Buffered() == 0 {
+		runtime.Gosched()
 	}
 	return machine.Serial.Read(p)
 }
 
 func (serialReader) ReadByte() (byte, error) {
 	for machine.Serial.Buffered() == 0 {
+		runtime.Gosched()
 	}
 
 	return machine.Serial.ReadByte()
@@ -1,17 +1,92 @@
 package main
 
 import (
+	"io"
+	"machine"
+
 	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial/i2c"
+	"github.com/mastercactapus/embedded/xb"
 )
 
 type xiao struct{}
 
+var (
+	_ xb.I2CProvider  = xiao{}
+	_ xb.UARTProvider = xiao{}
+)
+
 func (xiao) Pin(n int) driver.Pin {
 	return &driver.PinFN{
-		N:            n,
-		SetInputFunc: setInputPin,
-		SetFunc:      setPin,
-		GetFunc:      getPin,
+		N:              n,
+		SetInputFunc:   setInputPin,
+		SetFunc:        setPin,
+		GetFunc:        getPin,
+		SetPullFunc:    setPull,
+		ReadAnalogFunc: readAnalog,
+		SetPWMFunc:     setPWM,
+		ModesFunc:      pinModes,
 	}
 }
+
+// pinModes returns the modes the xb protocol can select; every pin has
+// both pull resistors.
+func pinModes(int) driver.Mode {
+	return driver.ModePushPull | driver.ModeHighZ | driver.ModeInputPullup | driver.ModeInputPulldown
+}
 func (xiao) PinCount() int { return len(pins) }
+
+// I2C uses the hardware I2C peripheral if the requested pins are the
+// default SDA/SCL pins.
+func (xiao) I2C(cfg xb.I2CConfig) (i2c.Bus, error) {
+	if int(cfg.SDA) >= len(pins) || int(cfg.SCL) >= len(pins) {
+		return nil, driver.ErrNotSupported
+	}
+	if pins[cfg.SDA] != machine.SDA_PIN || pins[cfg.SCL] != machine.SCL_PIN {
+		return nil, driver.ErrNotSupported
+	}
+
+	err := machine.I2C0.Configure(machine.I2CConfig{
+		Frequency: cfg.Baud,
+		SDA:       machine.SDA_PIN,
+		SCL:       machine.SCL_PIN,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return machine.I2C0, nil
+}
+
+// UART uses UART1 if the requested pins are the default TX/RX pins.
+func (xiao) UART(cfg xb.UARTConfig) (io.ReadWriter, error) {
+	if int(cfg.TX) >= len(pins) || int(cfg.RX) >= len(pins) {
+		return nil, driver.ErrNotSupported
+	}
+	if pins[cfg.TX] != machine.UART_TX_PIN || pins[cfg.RX] != machine.UART_RX_PIN {
+		return nil, driver.ErrNotSupported
+	}
+
+	err := machine.UART1.Configure(machine.UARTConfig{
+		BaudRate: cfg.Baud,
+		TX:       machine.UART_TX_PIN,
+		RX:       machine.UART_RX_PIN,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	parity := machine.ParityNone
+	switch cfg.Parity {
+	case xb.ParityEven:
+		parity = machine.ParityEven
+	case xb.ParityOdd:
+		parity = machine.ParityOdd
+	}
+	err = machine.UART1.SetFormat(int(cfg.DataBits), int(cfg.StopBits), parity)
+	if err != nil {
+		return nil, err
+	}
+
+	return machine.UART1, nil
+}
@@ -0,0 +1,63 @@
+package i2c
+
+import (
+	"errors"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// Recoverer is implemented by controllers that can attempt to free
+// a bus held low by a device stuck in the middle of a transfer.
+type Recoverer interface {
+	Recover() error
+}
+
+var ErrBusStuck = errors.New("i2c: bus stuck")
+
+// Recover will attempt to free a stuck bus, if supported by the controller.
+func (i2c *I2C) Recover() error {
+	if r, ok := i2c.Controller.(Recoverer); ok {
+		return r.Recover()
+	}
+
+	return ErrUnsupported
+}
+
+func (s *softCtrl) Recover() error { return Recover(s.sda, s.scl) }
+
+// Recover will clock SCL until SDA is released (at most 9 times) and
+// then send a STOP condition.
+//
+// ErrBusStuck is returned if either line is still held low.
+func Recover(sda, scl driver.Pin) error {
+	s := &softCtrl{sda: sda, scl: scl}
+	s.setHigh(s.sda)
+	s.setHigh(s.scl)
+	s.wait()
+	for i := 0; i < 9 && !s.get(s.sda); i++ {
+		s.setLow(s.scl)
+		s.wait()
+		s.setHigh(s.scl)
+		s.wait()
+	}
+
+	// STOP
+	s.setLow(s.scl)
+	s.wait()
+	s.setLow(s.sda)
+	s.wait()
+	s.setHigh(s.scl)
+	s.wait()
+	s.setHigh(s.sda)
+	s.wait()
+
+	sdaHigh, sclHigh := s.get(s.sda), s.get(s.scl)
+	if err := s.readErr(); err != nil {
+		return err
+	}
+	if !sdaHigh || !sclHigh {
+		return ErrBusStuck
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package i2c
+
+// Scanner is implemented by buses that can probe every address at once.
+type Scanner interface {
+	Scan() (ScanResult, error)
+}
+
+// ScanResult records which 7-bit addresses acknowledged a read or write probe.
+type ScanResult struct {
+	Read, Write [16]byte
+}
+
+// CanRead returns true if addr acknowledged a read probe.
+func (r ScanResult) CanRead(addr byte) bool { return r.Read[addr/8]&(1<<(addr%8)) != 0 }
+
+// CanWrite returns true if addr acknowledged a write probe.
+func (r ScanResult) CanWrite(addr byte) bool { return r.Write[addr/8]&(1<<(addr%8)) != 0 }
+
+// SetRead marks addr as having acknowledged a read probe.
+func (r *ScanResult) SetRead(addr byte) { r.Read[addr/8] |= 1 << (addr % 8) }
+
+// SetWrite marks addr as having acknowledged a write probe.
+func (r *ScanResult) SetWrite(addr byte) { r.Write[addr/8] |= 1 << (addr % 8) }
+
+type pinger interface {
+	Ping(addr byte) error
+	PingW(addr byte) error
+}
+
+// Scan probes every 7-bit address on the bus.
+//
+// If the bus does not implement Scanner and cannot send address-only
+// probes, a one byte read is used and write support is assumed
+// to match.
+func Scan(bus Bus) (res ScanResult, err error) {
+	if s, ok := bus.(Scanner); ok {
+		return s.Scan()
+	}
+
+	p, isPinger := bus.(pinger)
+	var buf [1]byte
+	for i := byte(0); i < 127; i++ {
+		if isPinger {
+			if p.Ping(i) == nil {
+				res.SetRead(i)
+			}
+			if p.PingW(i) == nil {
+				res.SetWrite(i)
+			}
+			continue
+		}
+
+		if i < Min7BitAddr {
+			continue
+		}
+		if bus.Tx(uint16(i), nil, buf[:]) == nil {
+			res.SetRead(i)
+			res.SetWrite(i)
+		}
+	}
+
+	return res, nil
+}
@@ -10,13 +10,14 @@ type softCtrl struct {
 }
 
 // NewSoftController will create a generic I2C controller.
-// Pins should be configured as inputs with pull-up and output
-// should be logic-low.
+//
+// Pins are used in open-drain mode if supported, otherwise they are
+// switched to inputs to release the line, which requires pull-ups.
 func NewSoftController(sda, scl driver.Pin) Controller {
+	sda = driver.OpenDrain(sda)
+	scl = driver.OpenDrain(scl)
 	sda.High()
-	sda.Input()
 	scl.High()
-	scl.Input()
 	return &softCtrl{scl: scl, sda: sda}
 }
 
@@ -30,19 +31,10 @@ func (s *softCtrl) setHigh(p driver.Pin) {
 	if s.err != nil {
 		return
 	}
-
-	s.err = p.Input()
-	if s.err != nil {
-		return
-	}
 	s.err = p.High()
 }
 
 func (s *softCtrl) setLow(p driver.Pin) {
-	if s.err != nil {
-		return
-	}
-	s.err = p.Output()
 	if s.err != nil {
 		return
 	}
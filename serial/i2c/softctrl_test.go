@@ -0,0 +1,43 @@
+package i2c
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func TestSoftController(t *testing.T) {
+	r := drivertest.NewRecorder()
+	sda, scl := r.Pin("SDA"), r.Pin("SCL")
+	c := NewSoftController(sda, scl)
+	r.Reset()
+
+	// ACK, then released for the stop condition
+	sda.Feed("0 1")
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteBit(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteBit(false); err != nil {
+		t.Fatal(err)
+	}
+	nak, err := c.ReadBit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nak {
+		t.Error("got NACK; want ACK")
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// lines are only driven low, never high
+	drivertest.AssertWaveform(t, r, `
+		SDA z__ zzz ___ zz__z __z
+		SCL zz_ _z_ _z_ _zz__ _zz
+	`)
+}
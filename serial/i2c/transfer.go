@@ -0,0 +1,84 @@
+package i2c
+
+// Msg is a single message of a multi-message transfer.
+type Msg struct {
+	Addr uint16
+
+	// Read indicates Data should be filled from the device,
+	// otherwise Data is written.
+	Read bool
+	Data []byte
+}
+
+// Transferer is implemented by buses that can perform a sequence of
+// messages separated by repeated START conditions, with a single STOP
+// at the end.
+type Transferer interface {
+	Transfer(msgs []Msg) error
+}
+
+// Transfer performs msgs on the bus.
+//
+// If the bus does not implement Transferer, a write followed by a read
+// to the same address is sent as a single Tx, and all other messages
+// are sent on their own.
+func Transfer(bus Bus, msgs []Msg) error {
+	if t, ok := bus.(Transferer); ok {
+		return t.Transfer(msgs)
+	}
+
+	for i := 0; i < len(msgs); i++ {
+		m := msgs[i]
+		if m.Read {
+			if err := bus.Tx(m.Addr, nil, m.Data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if i+1 < len(msgs) && msgs[i+1].Read && msgs[i+1].Addr == m.Addr {
+			if err := bus.Tx(m.Addr, m.Data, msgs[i+1].Data); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+
+		if err := bus.Tx(m.Addr, m.Data, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Transfer performs msgs, using a repeated START between each one.
+func (i2c *I2C) Transfer(msgs []Msg) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	defer i2c.Stop()
+
+	for _, m := range msgs {
+		i2c.Start()
+		mode := byte(modeWrite)
+		if m.Read {
+			mode = modeRead
+		}
+		if err := i2c.writeAddress(m.Addr, mode); err != nil {
+			return err
+		}
+
+		var err error
+		if m.Read {
+			_, err = i2c.Read(m.Data)
+		} else {
+			_, err = i2c.Write(m.Data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
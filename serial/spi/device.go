@@ -0,0 +1,69 @@
+package spi
+
+import (
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial"
+)
+
+// Device is a device on an SPI bus, selected by holding its chip select
+// pin low for the duration of each transfer.
+type Device struct {
+	c    Controller
+	cs   driver.OutputPin
+	fill byte
+}
+
+var _ serial.Transmitter = (*Device)(nil)
+
+// NewDevice returns a Device using the chip select pin cs, which is
+// driven high until a transfer.
+func NewDevice(c Controller, cs driver.OutputPin) (*Device, error) {
+	if err := cs.High(); err != nil {
+		return nil, err
+	}
+	return &Device{c: c, cs: cs}, nil
+}
+
+// SetFill sets the byte sent while reading.
+func (d *Device) SetFill(fill byte) { d.fill = fill }
+
+// Tx writes w then reads len(r) bytes, in a single transfer.
+func (d *Device) Tx(w, r []byte) (err error) {
+	if err := d.cs.Low(); err != nil {
+		return err
+	}
+	defer func() {
+		csErr := d.cs.High()
+		if err == nil {
+			err = csErr
+		}
+	}()
+
+	if len(w) > 0 {
+		if _, err = Write(d.c, w); err != nil {
+			return err
+		}
+	}
+	if len(r) > 0 {
+		if _, err = Read(d.c, d.fill, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes p in a single transfer.
+func (d *Device) Write(p []byte) (int, error) {
+	if err := d.Tx(p, nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read reads p in a single transfer.
+func (d *Device) Read(p []byte) (int, error) {
+	if err := d.Tx(nil, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
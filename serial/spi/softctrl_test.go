@@ -0,0 +1,56 @@
+package spi
+
+import (
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver/drivertest"
+)
+
+func TestSoftCtrl(t *testing.T) {
+	r := drivertest.NewRecorder()
+	miso := r.Pin("MISO")
+	s, err := NewSoftCtrl(&Config{Mode: Mode0, SCLK: r.Pin("SCLK"), MOSI: r.Pin("MOSI"), MISO: miso})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	miso.Feed("0011 1100")
+	v, err := s.ReadWriteByte(0xa5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x3c {
+		t.Errorf("read %x; want 3c", v)
+	}
+
+	// data changes while the clock is low, and is sampled on the rising edge
+	drivertest.AssertWaveform(t, r, `
+		SCLK __ -_ _-_ _-_ _-_ -_ _-_ _-_ _-_
+		MOSI _- -- ___ --- ___ __ --- ___ ---
+	`)
+}
+
+func TestSoftCtrl_Mode3(t *testing.T) {
+	r := drivertest.NewRecorder()
+	miso := r.Pin("MISO")
+	s, err := NewSoftCtrl(&Config{Mode: Mode3, SCLK: r.Pin("SCLK"), MOSI: r.Pin("MOSI"), MISO: miso})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Reset()
+
+	miso.Feed("1000 0001")
+	v, err := s.ReadWriteByte(0x81)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x81 {
+		t.Errorf("read %x; want 81", v)
+	}
+
+	// clock idles high, data changes after the falling edge
+	drivertest.AssertWaveform(t, r, `
+		SCLK -_- __- _-_-_-_-_- __-
+		MOSI --- -__ __________ _--
+	`)
+}
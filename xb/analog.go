@@ -0,0 +1,79 @@
+package xb
+
+import (
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/term/ascii"
+)
+
+func (c *Client) setPull(n int, pull driver.Pull) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	_, err := c.tx(&Request{Cmd: setPull, Pin: uint8(n), DataByte: uint8(pull)})
+	return err
+}
+
+func (c *Client) readAnalog(n int) (uint16, uint16, error) {
+	if err := c.Flush(); err != nil {
+		return 0, 0, err
+	}
+	resp, err := c.tx(&Request{Cmd: readAnalog, Pin: uint8(n)})
+	if err != nil {
+		return 0, 0, err
+	}
+	return resp.Value, resp.RefMV, nil
+}
+
+func (c *Client) setPWM(n int, freq uint32, duty uint16) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	_, err := c.tx(&Request{Cmd: setPWM, Pin: uint8(n), Freq: freq, Duty: duty})
+	return err
+}
+
+func (s *Server) setPull(n uint8, pull driver.Pull) error {
+	p, err := s.pin(n)
+	if err != nil {
+		return err
+	}
+	if pull > driver.PullDown {
+		return ascii.Errorf("setPull: pull %d: %w", uint8(pull), ErrBadRequest)
+	}
+	pp, ok := p.(driver.PullPin)
+	if !ok {
+		return driver.ErrNotSupported
+	}
+	return pp.SetPull(pull)
+}
+
+func (s *Server) readAnalog(n uint8) (*Response, error) {
+	p, err := s.pin(n)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := p.(driver.AnalogInput)
+	if !ok {
+		return nil, driver.ErrNotSupported
+	}
+	v, ref, err := a.ReadAnalog()
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Value: v, RefMV: ref}, nil
+}
+
+func (s *Server) setPWM(n uint8, freq uint32, duty uint16) error {
+	p, err := s.pin(n)
+	if err != nil {
+		return err
+	}
+	if freq == 0 {
+		return ascii.Errorf("setPWM: frequency: %w", ErrBadRequest)
+	}
+	pwm, ok := p.(driver.PWMPin)
+	if !ok {
+		return driver.ErrNotSupported
+	}
+	return pwm.SetPWM(freq, duty)
+}
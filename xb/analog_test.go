@@ -0,0 +1,124 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+type analogPins struct {
+	pull []driver.Pull
+	freq uint32
+	duty uint16
+}
+
+func (p *analogPins) PinCount() int { return len(p.pull) }
+func (p *analogPins) Pin(n int) driver.Pin {
+	pin := &driver.PinFN{
+		N: n,
+		SetPullFunc: func(n int, pull driver.Pull) error {
+			p.pull[n] = pull
+			return nil
+		},
+	}
+	if n == 0 {
+		pin.ModesFunc = func(int) driver.Mode {
+			return driver.ModePushPull | driver.ModeOpenDrain | driver.ModeHighZ | driver.ModeInputPullup
+		}
+	}
+	if n == 1 {
+		pin.ReadAnalogFunc = func(int) (uint16, uint16, error) { return 0x8000, 3300, nil }
+		pin.SetPWMFunc = func(_ int, freq uint32, duty uint16) error {
+			p.freq, p.duty = freq, duty
+			return nil
+		}
+	}
+	return pin
+}
+
+func TestClient_Analog(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	dev := &analogPins{pull: make([]driver.Pull, 4)}
+	go NewServer(reqR, respW, dev).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Pin(2).(driver.PullPin).SetPull(driver.PullDown); err != nil {
+		t.Fatal(err)
+	}
+	if dev.pull[2] != driver.PullDown {
+		t.Errorf("pin 2: got pull %d; want PullDown", dev.pull[2])
+	}
+
+	mv, err := driver.Millivolts(c.Pin(1).(driver.AnalogInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mv != 1650 {
+		t.Errorf("got %dmV; want 1650mV", mv)
+	}
+
+	if err := c.Pin(1).(driver.PWMPin).SetPWM(1000, 0x4000); err != nil {
+		t.Fatal(err)
+	}
+	if dev.freq != 1000 || dev.duty != 0x4000 {
+		t.Errorf("got %dHz duty %d; want 1000Hz duty 16384", dev.freq, dev.duty)
+	}
+
+	_, _, err = c.Pin(0).(driver.AnalogInput).ReadAnalog()
+	if !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("got %v; want ErrNotSupported", err)
+	}
+	err = c.Pin(1).(driver.PWMPin).SetPWM(0, 1)
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("got %v; want ErrBadRequest", err)
+	}
+}
+
+func TestClient_Modes(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	dev := &analogPins{pull: make([]driver.Pull, 4)}
+	go NewServer(reqR, respW, dev).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// open-drain can't be selected by the client
+	want := driver.ModePushPull | driver.ModeHighZ | driver.ModeInputPullup
+	if m := c.PinModes(0); m != want {
+		t.Errorf("pin 0: got modes %s; want %s", m, want)
+	}
+	if m := c.PinModes(2); m != driver.ModePushPull|driver.ModeHighZ {
+		t.Errorf("pin 2: got modes %s; want push-pull and high-Z", m)
+	}
+
+	for _, p := range []driver.Pin{c.Pin(0), c.BufferedPin(0)} {
+		dev.pull[0] = driver.PullUp
+		if err := p.(driver.ModePin).SetMode(driver.ModeHighZ); err != nil {
+			t.Fatal(err)
+		}
+		if dev.pull[0] != driver.PullNone {
+			t.Errorf("pin 0: got pull %d after high-Z; want PullNone", dev.pull[0])
+		}
+	}
+
+	err = c.Pin(2).(driver.ModePin).SetMode(driver.ModeInputPulldown)
+	if !errors.Is(err, driver.ErrNotSupported) {
+		t.Errorf("got %v; want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,147 @@
+package xb
+
+import (
+	"time"
+
+	"github.com/mastercactapus/embedded/term/ascii"
+)
+
+const (
+	batchSet uint8 = iota + 1
+	batchInput
+	batchGet
+	batchDelay
+)
+
+// MaxBatchOps is the maximum number of operations sent in a single
+// request. Larger batches are split into parts that are sent one after
+// another.
+const MaxBatchOps = 32
+
+// Batch is a sequence of pin operations that are executed together on the
+// device. Up to MaxBatchOps operations run without any other requests in
+// between; requests from other goroutines may run between the parts of a
+// larger batch.
+//
+// The zero value is an empty batch ready to use.
+type Batch struct {
+	ops  []byte
+	gets int
+}
+
+func (b *Batch) add(op, arg1, arg2 uint8) {
+	b.ops = append(b.ops, op, arg1, arg2)
+}
+
+func boolByte(v bool) uint8 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// Set adds an operation to set the output state of a pin.
+func (b *Batch) Set(pin int, v bool) { b.add(batchSet, uint8(pin), boolByte(v)) }
+
+// SetInput adds an operation to switch a pin to input (true) or output (false).
+func (b *Batch) SetInput(pin int, v bool) { b.add(batchInput, uint8(pin), boolByte(v)) }
+
+// Get adds an operation to read the state of a pin. It returns the index
+// of the result in the slice returned by Client.Do.
+func (b *Batch) Get(pin int) int {
+	b.add(batchGet, uint8(pin), 0)
+	b.gets++
+	return b.gets - 1
+}
+
+// Delay adds a pause, with microsecond resolution, before the next operation.
+func (b *Batch) Delay(d time.Duration) {
+	us := d.Microseconds()
+	for us > 0 {
+		n := us
+		if n > 0xffff {
+			n = 0xffff
+		}
+		b.add(batchDelay, uint8(n>>8), uint8(n))
+		us -= n
+	}
+}
+
+// Len returns the number of operations in the batch.
+func (b *Batch) Len() int { return len(b.ops) / 3 }
+
+// Reset clears all operations from the batch.
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+	b.gets = 0
+}
+
+// Do executes the batch on the device and returns the result of every
+// Get operation, in order.
+func (c *Client) Do(b *Batch) ([]bool, error) {
+	results := make([]bool, 0, b.gets)
+
+	// each part must finish before the next is sent, so that a
+	// retransmitted part can't run after a later one
+	const maxLen = MaxBatchOps * 3
+	for off := 0; off < len(b.ops); off += maxLen {
+		end := off + maxLen
+		if end > len(b.ops) {
+			end = len(b.ops)
+		}
+		resp, err := c.tx(&Request{Cmd: pinBatch, Data: b.ops[off:end]})
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(resp.DataByte); i++ {
+			results = append(results, resp.Data[i/8]&(1<<uint(i%8)) != 0)
+		}
+	}
+
+	return results, nil
+}
+
+// runBatch executes the operations in data, stopping at the first error.
+//
+// Get results are packed into the response Data, LSB first, with
+// DataByte holding the number of results.
+func (s *Server) runBatch(data []byte) (*Response, error) {
+	if len(data)%3 != 0 || len(data)/3 > MaxBatchOps {
+		return nil, ascii.Errorf("pinBatch: length %d: %w", len(data), ErrBadRequest)
+	}
+
+	resp := &Response{Data: make([]byte, (len(data)/3+7)/8)}
+	var gets int
+	for i := 0; i < len(data); i += 3 {
+		op, a, b := data[i], data[i+1], data[i+2]
+		if op != batchDelay && int(a) >= len(s.pins) {
+			return nil, ascii.Errorf("pinBatch: op %d: %w", i/3, ErrBadPin)
+		}
+
+		var err error
+		switch op {
+		case batchSet:
+			err = s.pins[a].Set(b != 0)
+		case batchInput:
+			err = s.pins[a].SetInput(b != 0)
+		case batchGet:
+			var v bool
+			v, err = s.pins[a].Get()
+			if v {
+				resp.Data[gets/8] |= 1 << uint(gets%8)
+			}
+			gets++
+		case batchDelay:
+			time.Sleep(time.Duration(uint16(a)<<8|uint16(b)) * time.Microsecond)
+		default:
+			err = ErrBadRequest
+		}
+		if err != nil {
+			return nil, ascii.Errorf("pinBatch: op %d: %w", i/3, err)
+		}
+	}
+	resp.Data = resp.Data[:(gets+7)/8]
+	resp.DataByte = uint8(gets)
+
+	return resp, nil
+}
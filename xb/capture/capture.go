@@ -0,0 +1,46 @@
+// Package capture holds logic-analyzer captures and writes them in
+// formats understood by waveform viewers.
+package capture
+
+import "errors"
+
+// Capture is a sequence of digital samples.
+//
+// Each sample is Width bytes, with channel 0 in the least significant
+// bit of the first byte.
+type Capture struct {
+	// Channels holds the name of each channel, in bit order.
+	Channels []string
+
+	// Rate is the sample rate in Hz.
+	Rate uint64
+
+	// Trigger is the index of the first sample after the trigger
+	// condition was met, or -1 if there was no trigger.
+	Trigger int
+
+	Width int
+	Data  []byte
+}
+
+var ErrInvalid = errors.New("capture: invalid capture")
+
+// Len returns the number of samples.
+func (c *Capture) Len() int {
+	if c.Width == 0 {
+		return 0
+	}
+	return len(c.Data) / c.Width
+}
+
+// Bit returns the state of channel ch in sample i.
+func (c *Capture) Bit(i, ch int) bool {
+	return c.Data[i*c.Width+ch/8]&(1<<uint(ch%8)) != 0
+}
+
+func (c *Capture) validate() error {
+	if c.Rate == 0 || c.Width == 0 || c.Width*8 < len(c.Channels) || len(c.Data)%c.Width != 0 {
+		return ErrInvalid
+	}
+	return nil
+}
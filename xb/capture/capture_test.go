@@ -0,0 +1,95 @@
+package capture
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func testCapture() *Capture {
+	return &Capture{
+		Channels: []string{"D0", "D1"},
+		Rate:     1e6,
+		Trigger:  1,
+		Width:    1,
+		Data:     []byte{0b00, 0b01, 0b01, 0b11, 0b10},
+	}
+}
+
+func TestCapture_WriteVCD(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testCapture().WriteVCD(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = `$timescale 1 ns $end
+$scope module capture $end
+$var wire 1 ! D0 $end
+$var wire 1 " D1 $end
+$upscope $end
+$enddefinitions $end
+#0
+$dumpvars
+0!
+0"
+$end
+#1000
+1!
+#3000
+1"
+#4000
+0!
+#5000
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestCapture_WriteSigrok(t *testing.T) {
+	var buf bytes.Buffer
+	c := testCapture()
+	if err := c.WriteSigrok(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+
+	if string(files["version"]) != "2" {
+		t.Errorf("version: got %q", files["version"])
+	}
+	if !bytes.Contains(files["metadata"], []byte("samplerate=1 MHz\n")) {
+		t.Errorf("metadata missing samplerate:\n%s", files["metadata"])
+	}
+	if !bytes.Contains(files["metadata"], []byte("probe2=D1\n")) {
+		t.Errorf("metadata missing probe2:\n%s", files["metadata"])
+	}
+	if !bytes.Equal(files["logic-1-1"], c.Data) {
+		t.Errorf("data: got %v; want %v", files["logic-1-1"], c.Data)
+	}
+}
+
+func TestVCDID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 500; i++ {
+		id := vcdID(i)
+		if seen[id] {
+			t.Fatalf("duplicate id %q for %d", id, i)
+		}
+		seen[id] = true
+	}
+}
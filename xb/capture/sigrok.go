@@ -0,0 +1,63 @@
+package capture
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+)
+
+// WriteSigrok writes the capture as a sigrok session (.sr) file.
+func (c *Capture) WriteSigrok(w io.Writer) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	z := zip.NewWriter(w)
+
+	f, err := z.Create("version")
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(f, "2"); err != nil {
+		return err
+	}
+
+	f, err = z.Create("metadata")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "[global]\nsigrok version=0.5.1\n\n")
+	fmt.Fprintf(f, "[device 1]\ncapturefile=logic-1\n")
+	fmt.Fprintf(f, "total probes=%d\n", len(c.Channels))
+	fmt.Fprintf(f, "samplerate=%s\n", rateString(c.Rate))
+	fmt.Fprintf(f, "total analog=0\n")
+	for i, name := range c.Channels {
+		fmt.Fprintf(f, "probe%d=%s\n", i+1, name)
+	}
+	if _, err = fmt.Fprintf(f, "unitsize=%d\n", c.Width); err != nil {
+		return err
+	}
+
+	f, err = z.Create("logic-1-1")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(c.Data); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// rateString formats a sample rate the way sigrok does.
+func rateString(hz uint64) string {
+	switch {
+	case hz >= 1e9 && hz%1e9 == 0:
+		return fmt.Sprintf("%d GHz", hz/1e9)
+	case hz >= 1e6 && hz%1e6 == 0:
+		return fmt.Sprintf("%d MHz", hz/1e6)
+	case hz >= 1e3 && hz%1e3 == 0:
+		return fmt.Sprintf("%d kHz", hz/1e3)
+	}
+	return fmt.Sprintf("%d Hz", hz)
+}
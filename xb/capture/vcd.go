@@ -0,0 +1,64 @@
+package capture
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteVCD writes the capture as a Value Change Dump.
+//
+// The timescale is 1ns, so sample rates above 1GHz lose precision.
+func (c *Capture) WriteVCD(w io.Writer) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "$timescale 1 ns $end")
+	fmt.Fprintln(bw, "$scope module capture $end")
+	for i, name := range c.Channels {
+		fmt.Fprintf(bw, "$var wire 1 %s %s $end\n", vcdID(i), name)
+	}
+	fmt.Fprintln(bw, "$upscope $end")
+	fmt.Fprintln(bw, "$enddefinitions $end")
+
+	ns := func(i int) uint64 { return uint64(i) * 1e9 / c.Rate }
+	for i := 0; i < c.Len(); i++ {
+		var changed bool
+		for ch := range c.Channels {
+			v := c.Bit(i, ch)
+			if i > 0 && v == c.Bit(i-1, ch) {
+				continue
+			}
+			if !changed {
+				fmt.Fprintf(bw, "#%d\n", ns(i))
+				if i == 0 {
+					fmt.Fprintln(bw, "$dumpvars")
+				}
+				changed = true
+			}
+			if v {
+				fmt.Fprintf(bw, "1%s\n", vcdID(ch))
+			} else {
+				fmt.Fprintf(bw, "0%s\n", vcdID(ch))
+			}
+		}
+		if i == 0 {
+			fmt.Fprintln(bw, "$end")
+		}
+	}
+	fmt.Fprintf(bw, "#%d\n", ns(c.Len()))
+
+	return bw.Flush()
+}
+
+// vcdID returns a short printable identifier for a channel.
+func vcdID(n int) string {
+	const first, count = '!', '~' - '!' + 1
+	id := string(rune(first + n%count))
+	for n /= count; n > 0; n /= count {
+		id += string(rune(first + n%count))
+	}
+	return id
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"hash/crc32"
 	"io"
+	"sync"
 )
 
 type Header struct {
@@ -16,6 +17,31 @@ type Header struct {
 	DataCRC   uint32
 }
 
+const headerSize = 14
+
+// MaxChunkLen is the largest chunk ReadChunk will accept.
+const MaxChunkLen = 1 << 16
+
+var (
+	ErrHeaderCRC = errors.New("xb: chunk header CRC mismatch")
+	ErrDataCRC   = errors.New("xb: chunk data CRC mismatch")
+	ErrChunkLen  = errors.New("xb: chunk too large")
+)
+
+// Framing selects how chunks are delimited on the wire.
+type Framing uint8
+
+const (
+	// FramingRaw sends a header starting with a 0xfe magic byte
+	// followed by the data. It is the default.
+	FramingRaw Framing = iota
+
+	// FramingCOBS sends the type, data and CRC encoded with COBS and
+	// terminated by a zero byte. Frame boundaries are unambiguous, so
+	// a reader resyncs at the next zero byte after corruption.
+	FramingCOBS
+)
+
 func (h *Header) CRC() uint32 {
 	var crcPart [6]byte
 	crcPart[0] = h.Magic
@@ -24,29 +50,147 @@ func (h *Header) CRC() uint32 {
 	return crc32.ChecksumIEEE(crcPart[:])
 }
 
+func (h *Header) put(b []byte) {
+	b[0] = h.Magic
+	b[1] = h.Type
+	binary.LittleEndian.PutUint32(b[2:], h.Length)
+	binary.LittleEndian.PutUint32(b[6:], h.HeaderCRC)
+	binary.LittleEndian.PutUint32(b[10:], h.DataCRC)
+}
+
+var framePool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// WriteChunk writes a single raw-framed chunk to w.
 func WriteChunk(w io.Writer, typeCode byte, data []byte) error {
+	buf := framePool.Get().(*[]byte)
+	defer framePool.Put(buf)
+
+	*buf = appendRaw((*buf)[:0], typeCode, data)
+	_, err := w.Write(*buf)
+	return err
+}
+
+func appendRaw(dst []byte, typeCode byte, data []byte) []byte {
 	h := Header{
 		Magic:  0xfe,
 		Type:   typeCode,
 		Length: uint32(len(data)),
 	}
-	h.HeaderCRC = h.CRC()
 	h.DataCRC = crc32.ChecksumIEEE(data)
 
-	err := binary.Write(w, binary.LittleEndian, h)
-	if err != nil {
-		return err
+	// build the header in place; a local array would escape
+	// through crc32 and allocate
+	start := len(dst)
+	dst = append(dst, make([]byte, headerSize)...)
+	hdr := dst[start:]
+	h.put(hdr)
+	binary.LittleEndian.PutUint32(hdr[6:], crc32.ChecksumIEEE(hdr[:6]))
+
+	return append(dst, data...)
+}
+
+func appendCOBS(dst []byte, typeCode byte, data []byte) []byte {
+	// CRC of the type byte, computed directly to avoid allocating a slice
+	crc := ^uint32(0)
+	crc = crc32.IEEETable[byte(crc)^typeCode] ^ (crc >> 8)
+	crc = crc32.Update(^crc, crc32.IEEETable, data)
+	var tail [4]byte
+	binary.LittleEndian.PutUint32(tail[:], crc)
+
+	var e cobsEnc
+	dst = e.start(dst)
+	dst = e.append(dst, typeCode)
+	for _, b := range data {
+		dst = e.append(dst, b)
+	}
+	for _, b := range tail {
+		dst = e.append(dst, b)
 	}
+	dst = e.finish(dst)
 
-	_, err = w.Write(data)
-	if err != nil {
-		return err
+	return append(dst, 0)
+}
+
+// ChunkWriter writes chunks using a reusable buffer, so each chunk is
+// sent with a single Write call.
+//
+// A ChunkWriter is not safe for concurrent use.
+type ChunkWriter struct {
+	w       io.Writer
+	framing Framing
+	buf     []byte
+}
+
+// NewChunkWriter returns a ChunkWriter writing to w with the given framing.
+func NewChunkWriter(w io.Writer, f Framing) *ChunkWriter {
+	return &ChunkWriter{w: w, framing: f}
+}
+
+// WriteChunk writes a single chunk.
+func (cw *ChunkWriter) WriteChunk(typeCode byte, data []byte) error {
+	if cw.framing == FramingCOBS {
+		cw.buf = appendCOBS(cw.buf[:0], typeCode, data)
+	} else {
+		cw.buf = appendRaw(cw.buf[:0], typeCode, data)
 	}
 
+	_, err := cw.w.Write(cw.buf)
 	return err
 }
 
+// ChunkReader reads chunks without allocating once its buffer has grown
+// to fit the largest chunk seen.
+type ChunkReader struct {
+	r       *bufio.Reader
+	framing Framing
+	buf     []byte
+	frame   []byte
+}
+
+// NewChunkReader returns a ChunkReader reading from r with the given framing.
+func NewChunkReader(r io.Reader, f Framing) *ChunkReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &ChunkReader{r: br, framing: f}
+}
+
+// Next reads the next chunk.
+//
+// The returned data is only valid until the next call to Next and must
+// be copied if it is to be kept.
+//
+// After ErrHeaderCRC, ErrDataCRC or ErrChunkLen it is safe to call Next
+// again to resync with the stream.
+func (cr *ChunkReader) Next() (byte, []byte, error) {
+	if cr.framing == FramingCOBS {
+		return cr.nextCOBS()
+	}
+	return cr.nextRaw()
+}
+
+// ReadChunk reads the next raw-framed chunk from r.
+//
+// On a CRC mismatch only the magic byte is consumed, so calling
+// ReadChunk again will resync on the next chunk even if it started
+// inside the corrupted one.
 func ReadChunk(r *bufio.Reader) (byte, []byte, error) {
+	cr := ChunkReader{r: r}
+	typeCode, data, err := cr.nextRaw()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	cp := make([]byte, len(data))
+	copy(cp, data)
+	return typeCode, cp, nil
+}
+
+func (cr *ChunkReader) nextRaw() (byte, []byte, error) {
+	r := cr.r
 	for {
 		b, err := r.ReadByte()
 		if err != nil {
@@ -58,25 +202,123 @@ func ReadChunk(r *bufio.Reader) (byte, []byte, error) {
 		}
 	}
 
-	var h Header
-	err := binary.Read(r, binary.LittleEndian, &h)
+	hdr, err := r.Peek(headerSize)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	if h.HeaderCRC != h.CRC() {
-		return 0, nil, errors.New("xb: chunk header CRC mismatch")
+	h := Header{
+		Magic:     hdr[0],
+		Type:      hdr[1],
+		Length:    binary.LittleEndian.Uint32(hdr[2:]),
+		HeaderCRC: binary.LittleEndian.Uint32(hdr[6:]),
+		DataCRC:   binary.LittleEndian.Uint32(hdr[10:]),
+	}
+	if h.HeaderCRC != crc32.ChecksumIEEE(hdr[:6]) {
+		r.Discard(1)
+		return 0, nil, ErrHeaderCRC
+	}
+	if h.Length > MaxChunkLen {
+		r.Discard(1)
+		return 0, nil, ErrChunkLen
+	}
+
+	if int(h.Length) > r.Size()-headerSize {
+		// too large to buffer, read it directly
+		r.Discard(headerSize)
+		cr.buf = grow(cr.buf, int(h.Length))
+		_, err = io.ReadFull(r, cr.buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		if h.DataCRC != crc32.ChecksumIEEE(cr.buf) {
+			return 0, nil, ErrDataCRC
+		}
+
+		return h.Type, cr.buf, nil
 	}
 
-	data := make([]byte, h.Length)
-	_, err = io.ReadFull(r, data)
+	frame, err := r.Peek(headerSize + int(h.Length))
 	if err != nil {
 		return 0, nil, err
 	}
+	if h.DataCRC != crc32.ChecksumIEEE(frame[headerSize:]) {
+		r.Discard(1)
+		return 0, nil, ErrDataCRC
+	}
+
+	// Discard doesn't touch the buffer, so the data stays
+	// valid until the next read.
+	r.Discard(len(frame))
+	return h.Type, frame[headerSize:], nil
+}
+
+// maxCOBSFrame is the largest encoded frame for a MaxChunkLen chunk.
+const maxCOBSFrame = 1 + MaxChunkLen + 4 + (MaxChunkLen+5)/254 + 2
+
+func (cr *ChunkReader) nextCOBS() (byte, []byte, error) {
+	var frame []byte
+	for {
+		line, err := cr.r.ReadSlice(0)
+		if err == bufio.ErrBufferFull {
+			if len(cr.frame)+len(line) > maxCOBSFrame {
+				cr.frame = cr.frame[:0]
+				if err := cr.skipFrame(); err != nil {
+					return 0, nil, err
+				}
+				return 0, nil, ErrChunkLen
+			}
+			cr.frame = append(cr.frame, line...)
+			continue
+		}
+		if err != nil {
+			cr.frame = cr.frame[:0]
+			return 0, nil, err
+		}
+
+		if len(cr.frame) > 0 {
+			cr.frame = append(cr.frame, line...)
+			frame = cr.frame
+			cr.frame = cr.frame[:0]
+		} else {
+			frame = line
+		}
+		frame = frame[:len(frame)-1]
+		if len(frame) == 0 {
+			// empty frames may be used to flush a partial frame
+			continue
+		}
+		break
+	}
+
+	var err error
+	cr.buf, err = cobsDecode(cr.buf[:0], frame)
+	if err != nil || len(cr.buf) < 5 {
+		return 0, nil, ErrDataCRC
+	}
+
+	msg := cr.buf[:len(cr.buf)-4]
+	if crc32.ChecksumIEEE(msg) != binary.LittleEndian.Uint32(cr.buf[len(msg):]) {
+		return 0, nil, ErrDataCRC
+	}
+
+	return msg[0], msg[1:], nil
+}
 
-	if h.DataCRC != crc32.ChecksumIEEE(data) {
-		return 0, nil, errors.New("xb: chunk data CRC mismatch")
+// skipFrame discards input up to and including the next zero byte.
+func (cr *ChunkReader) skipFrame() error {
+	for {
+		_, err := cr.r.ReadSlice(0)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return err
 	}
+}
 
-	return h.Type, data, nil
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
 }
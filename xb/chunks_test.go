@@ -3,6 +3,9 @@ package xb
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"io"
+	"math/rand"
 	"testing"
 )
 
@@ -30,3 +33,165 @@ func TestReadWrite(t *testing.T) {
 		t.Fatal("wrong data")
 	}
 }
+
+func TestCOBS(t *testing.T) {
+	long := bytes.Repeat([]byte{1}, 600)
+	for i := 0; i < len(long); i += 253 {
+		long[i] = 0
+	}
+	payloads := [][]byte{
+		nil,
+		{0},
+		{0, 0, 0},
+		{0xfe, 0, 0xfe},
+		bytes.Repeat([]byte{0xff}, 253),
+		bytes.Repeat([]byte{0xff}, 254),
+		bytes.Repeat([]byte{0xff}, 255),
+		long,
+	}
+
+	var buf bytes.Buffer
+	w := NewChunkWriter(&buf, FramingCOBS)
+	for _, p := range payloads {
+		if err := w.WriteChunk('Q', p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bytes.Count(buf.Bytes(), []byte{0}) != len(payloads) {
+		t.Fatal("zero bytes in encoded frames")
+	}
+
+	r := NewChunkReader(&buf, FramingCOBS)
+	for i, p := range payloads {
+		tc, data, err := r.Next()
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if tc != 'Q' {
+			t.Errorf("chunk %d: got type %q; want 'Q'", i, tc)
+		}
+		if !bytes.Equal(data, p) {
+			t.Errorf("chunk %d: got %x; want %x", i, data, p)
+		}
+	}
+}
+
+func TestCOBS_Resync(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChunkWriter(&buf, FramingCOBS)
+	w.WriteChunk('Q', []byte("first"))
+	buf.Bytes()[3] ^= 0x10
+	w.WriteChunk('Q', []byte("second"))
+
+	r := NewChunkReader(&buf, FramingCOBS)
+	_, _, err := r.Next()
+	if !errors.Is(err, ErrDataCRC) {
+		t.Fatalf("got %v; want ErrDataCRC", err)
+	}
+	_, data, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("got %q; want second", data)
+	}
+}
+
+func TestChunkReader_Large(t *testing.T) {
+	data := make([]byte, 8192)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, f := range []Framing{FramingRaw, FramingCOBS} {
+		var buf bytes.Buffer
+		w := NewChunkWriter(&buf, f)
+		w.WriteChunk('R', data)
+		w.WriteChunk('R', data[:10])
+
+		r := NewChunkReader(&buf, f)
+		_, got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("framing %d: large chunk mismatch", f)
+		}
+		_, got, err = r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data[:10]) {
+			t.Errorf("framing %d: small chunk mismatch", f)
+		}
+	}
+}
+
+// loopReader repeats data forever.
+type loopReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.pos:])
+	r.pos = (r.pos + n) % len(r.data)
+	return n, nil
+}
+
+func benchPayload() []byte {
+	data := make([]byte, 256)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func BenchmarkWriteChunk(b *testing.B) {
+	data := benchPayload()
+	for _, f := range []Framing{FramingRaw, FramingCOBS} {
+		b.Run(framingName(f), func(b *testing.B) {
+			w := NewChunkWriter(io.Discard, f)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w.WriteChunk('R', data)
+			}
+		})
+	}
+}
+
+func BenchmarkReadChunk(b *testing.B) {
+	data := benchPayload()
+	for _, f := range []Framing{FramingRaw, FramingCOBS} {
+		var buf bytes.Buffer
+		NewChunkWriter(&buf, f).WriteChunk('R', data)
+
+		b.Run(framingName(f), func(b *testing.B) {
+			r := NewChunkReader(&loopReader{data: buf.Bytes()}, f)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := r.Next(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("copy", func(b *testing.B) {
+		var buf bytes.Buffer
+		WriteChunk(&buf, 'R', data)
+		r := bufio.NewReader(&loopReader{data: buf.Bytes()})
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := ReadChunk(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func framingName(f Framing) string {
+	if f == FramingCOBS {
+		return "cobs"
+	}
+	return "raw"
+}
@@ -1,25 +1,84 @@
+// Package xb implements a request/response protocol for controlling the
+// pins and buses of a remote device over a serial link.
+//
+// A Client is safe for concurrent use. Requests are retransmitted until
+// answered, and requests from different goroutines are pipelined up to
+// ClientConfig.MaxInFlight; the requests made by a single call, such as
+// the parts of a large Batch or SPI write, are sent one at a time.
 package xb
 
 import (
-	"bufio"
 	"errors"
-	"fmt"
 	"io"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/mastercactapus/embedded/driver"
 	"github.com/mastercactapus/embedded/serial/i2c"
 	"github.com/mastercactapus/embedded/serial/spi"
+	"github.com/mastercactapus/embedded/xb/tlv"
 )
 
 type Client struct {
-	r *bufio.Reader
-	w io.Writer
+	r *ChunkReader
+	w *ChunkWriter
+
+	cfg ClientConfig
+
+	wMx    sync.Mutex
+	mx     sync.Mutex
+	nextID uint16
+	calls  map[uint16]*call
+	err    error
+	window chan struct{}
 
 	pinCount int
+	modes    []driver.Mode
+
+	bufMx    sync.Mutex
+	buf      Batch
+	bufState uint64
+
+	events chan Event
+
+	intMx      sync.Mutex
+	interrupts map[uint8]func()
+
+	uartMx sync.Mutex
+	uart   *uartClient
 }
 
+var (
+	_ driver.BufferedPinner = (*Client)(nil)
+	_ driver.Port           = (*Client)(nil)
+)
+
+// ClientConfig controls request timing for a Client.
+type ClientConfig struct {
+	// Timeout is how long to wait for a response before
+	// retransmitting a request. Defaults to 250ms.
+	Timeout time.Duration
+
+	// Retries is the number of times a request is retransmitted
+	// before giving up with ErrTimeout. Defaults to 3.
+	Retries int
+
+	// MaxInFlight limits the number of requests that may be
+	// outstanding at once. Only requests from different goroutines,
+	// and the reads of a Capture, are pipelined; a single call waits
+	// for each request before sending the next, so that a retransmit
+	// can't run after a later one. Defaults to 4, and is capped at 8,
+	// the number of responses the server remembers for retransmits.
+	MaxInFlight int
+
+	// Framing selects how chunks are delimited; it must match the server.
+	Framing Framing
+}
+
+// ErrTimeout is returned when no response was received after all retries.
+var ErrTimeout = errors.New("xb: request timed out")
+
 var (
 	_ spi.ReadController      = (*spiClient)(nil)
 	_ spi.ReadWriteController = (*spiClient)(nil)
@@ -41,14 +100,42 @@ func (bw bw) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// NewClient is a convenience method that returns a Client using the default ClientConfig.
 func NewClient(r io.Reader, w io.Writer) (*Client, error) {
+	return NewClientConfig(r, w, ClientConfig{})
+}
+
+// NewClientConfig returns a new Client after resetting the remote device.
+//
+// Responses are read from r by a background goroutine until it returns an error.
+func NewClientConfig(r io.Reader, w io.Writer, cfg ClientConfig) (*Client, error) {
 	// pr, pw := io.Pipe()
 	// go io.Copy(io.MultiWriter(log.Writer(), pw), r)
 
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 250 * time.Millisecond
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = 3
+	}
+	if cfg.MaxInFlight == 0 {
+		cfg.MaxInFlight = 4
+	}
+	if cfg.MaxInFlight > recentResponses {
+		cfg.MaxInFlight = recentResponses
+	}
+
 	c := &Client{
-		r: bufio.NewReader(r),
-		w: w,
+		r:      NewChunkReader(r, cfg.Framing),
+		w:      NewChunkWriter(w, cfg.Framing),
+		cfg:    cfg,
+		calls:  make(map[uint16]*call),
+		window: make(chan struct{}, cfg.MaxInFlight),
+		events: make(chan Event, 64),
+
+		interrupts: make(map[uint8]func()),
 	}
+	go c.readLoop()
 
 	resp, err := c.tx(&Request{Cmd: reset})
 	if err != nil {
@@ -63,37 +150,192 @@ func NewClient(r io.Reader, w io.Writer) (*Client, error) {
 		return nil, errors.New("xb: too many pins")
 	}
 
+	// older servers don't report pin modes
+	c.modes = make([]driver.Mode, c.pinCount)
+	for n := range c.modes {
+		c.modes[n] = driver.ModePushPull | driver.ModeHighZ
+		if len(resp.Data) == c.pinCount {
+			c.modes[n] = driver.Mode(resp.Data[n]) & clientModes
+		}
+	}
+
 	return c, nil
 }
 
-func (c *Client) tx(r *Request) (Response, error) {
-	if err := WriteChunk(c.w, 'Q', r.encode()); err != nil {
-		return Response{}, err
-	}
+// call is an outstanding request.
+type call struct {
+	c    *Client
+	id   uint16
+	data []byte
+	ch   chan Response
+
+	timeout  time.Duration
+	deadline time.Time
+	tries    int
+}
 
+func (c *Client) readLoop() {
 	for {
-		typeCode, data, err := ReadChunk(c.r)
+		typeCode, data, err := c.r.Next()
+		if errors.Is(err, ErrHeaderCRC) || errors.Is(err, ErrDataCRC) || errors.Is(err, ErrChunkLen) {
+			// lost requests will be retransmitted
+			continue
+		}
 		if err != nil {
-			return Response{}, err
+			c.mx.Lock()
+			c.err = err
+			for id, cl := range c.calls {
+				close(cl.ch)
+				delete(c.calls, id)
+			}
+			c.mx.Unlock()
+			close(c.events)
+			return
 		}
+
+		// responses and UART data are handed to other goroutines
+		data = append([]byte(nil), data...)
+
 		switch typeCode {
 		case 'R':
 			var resp Response
-			resp.decode(data)
-			if resp.Err != "" {
-				return resp, errors.New(resp.Err)
+			if err := resp.decode(data); err != nil {
+				// the request will be retransmitted
+				log.Println("xb: bad response:", err)
+				continue
 			}
-			return resp, nil
+			c.mx.Lock()
+			cl := c.calls[resp.ID]
+			delete(c.calls, resp.ID)
+			c.mx.Unlock()
+			if cl == nil {
+				// duplicate or late response
+				continue
+			}
+			cl.ch <- resp
 		case 'E':
 			log.Println("xb: error:", string(data))
 		case 'L':
 			log.Println("xb: remote log:", string(data))
+		case 'U':
+			c.uartData(data)
+		case 'V':
+			var ev Event
+			if err := tlv.Unmarshal(data, &ev); err != nil {
+				log.Println("xb: bad event:", err)
+				continue
+			}
+			c.interrupt(ev)
+			select {
+			case c.events <- ev:
+			default:
+			}
 		default:
-			return Response{}, fmt.Errorf("xb: unknown type code %q", typeCode)
+			log.Printf("xb: unknown type code %q", typeCode)
+		}
+	}
+}
+
+// start sends a request without waiting for the response.
+func (c *Client) start(r *Request) (*call, error) {
+	return c.startTimeout(r, c.cfg.Timeout)
+}
+
+// startTimeout is like start, but waits at least timeout before
+// retransmitting the request.
+func (c *Client) startTimeout(r *Request, timeout time.Duration) (*call, error) {
+	if timeout < c.cfg.Timeout {
+		timeout = c.cfg.Timeout
+	}
+	c.window <- struct{}{}
+
+	c.mx.Lock()
+	if c.err != nil {
+		c.mx.Unlock()
+		<-c.window
+		return nil, c.err
+	}
+	c.nextID++
+	for c.nextID == 0 || c.calls[c.nextID] != nil {
+		c.nextID++
+	}
+	r.ID = c.nextID
+	cl := &call{
+		c:       c,
+		id:      r.ID,
+		data:    r.encode(),
+		ch:      make(chan Response, 1),
+		timeout: timeout,
+	}
+	c.calls[cl.id] = cl
+	c.mx.Unlock()
+
+	if err := cl.send(); err != nil {
+		cl.cancel()
+		return nil, err
+	}
+
+	return cl, nil
+}
+
+func (cl *call) send() error {
+	cl.deadline = time.Now().Add(cl.timeout)
+	cl.c.wMx.Lock()
+	defer cl.c.wMx.Unlock()
+	return cl.c.w.WriteChunk('Q', cl.data)
+}
+
+func (cl *call) cancel() {
+	cl.c.mx.Lock()
+	delete(cl.c.calls, cl.id)
+	cl.c.mx.Unlock()
+	<-cl.c.window
+}
+
+// wait blocks until the response arrives, retransmitting the
+// request each time the deadline passes.
+func (cl *call) wait() (Response, error) {
+	t := time.NewTimer(time.Until(cl.deadline))
+	defer t.Stop()
+	for {
+		select {
+		case resp, ok := <-cl.ch:
+			<-cl.c.window
+			if !ok {
+				cl.c.mx.Lock()
+				err := cl.c.err
+				cl.c.mx.Unlock()
+				return Response{}, err
+			}
+			if resp.Err != "" || resp.Code != CodeOK {
+				return resp, &RemoteError{Code: resp.Code, Msg: resp.Err}
+			}
+			return resp, nil
+		case <-t.C:
+		}
+
+		if cl.tries >= cl.c.cfg.Retries {
+			cl.cancel()
+			return Response{}, ErrTimeout
+		}
+		cl.tries++
+		if err := cl.send(); err != nil {
+			cl.cancel()
+			return Response{}, err
 		}
+		t.Reset(cl.timeout)
 	}
 }
 
+func (c *Client) tx(r *Request) (Response, error) {
+	cl, err := c.start(r)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return cl.wait()
+}
+
 func (c *Client) Ping() error {
 	_, err := c.tx(&Request{Cmd: hello})
 	return err
@@ -102,25 +344,112 @@ func (c *Client) Ping() error {
 func (c *Client) PinCount() int { return c.pinCount }
 
 func (c *Client) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:              n,
+		GetFunc:        c.getPin,
+		SetInputFunc:   c.setInput,
+		SetFunc:        c.setPin,
+		SetPullFunc:    c.setPull,
+		ReadAnalogFunc: c.readAnalog,
+		SetPWMFunc:     c.setPWM,
+
+		SetInterruptFunc: c.setInterrupt,
+		ModesFunc:        c.PinModes,
+		SetModeFunc:      c.setMode,
+	}
+}
+
+// BufferedPin returns a pin whose operations are queued, in order, until
+// Flush is called or an unbuffered pin is used. Get returns the state
+// from the last call to Refresh.
+func (c *Client) BufferedPin(n int) driver.Pin {
 	return &driver.PinFN{
 		N:            n,
-		GetFunc:      c.getPin,
-		SetInputFunc: c.setInput,
-		SetFunc:      c.setPin,
+		GetFunc:      c.getPinBuf,
+		SetInputFunc: c.setInputBuf,
+		SetFunc:      c.setPinBuf,
+
+		ModesFunc:   c.PinModes,
+		SetModeFunc: c.setModeBuf,
+	}
+}
+
+func (c *Client) setInputBuf(n int, v bool) error {
+	c.bufMx.Lock()
+	c.buf.SetInput(n, v)
+	c.bufMx.Unlock()
+	return nil
+}
+
+func (c *Client) setPinBuf(n int, v bool) error {
+	c.bufMx.Lock()
+	c.buf.Set(n, v)
+	c.bufMx.Unlock()
+	return nil
+}
+
+func (c *Client) getPinBuf(n int) (bool, error) {
+	c.bufMx.Lock()
+	defer c.bufMx.Unlock()
+	return c.bufState&(1<<uint(n)) != 0, nil
+}
+
+// Flush sends all queued buffered pin operations as a batch.
+func (c *Client) Flush() error {
+	c.bufMx.Lock()
+	defer c.bufMx.Unlock()
+
+	if c.buf.Len() == 0 {
+		return nil
+	}
+	_, err := c.Do(&c.buf)
+	c.buf.Reset()
+	return err
+}
+
+// Refresh flushes any queued operations and then reads the state of all pins.
+func (c *Client) Refresh() error {
+	c.bufMx.Lock()
+	defer c.bufMx.Unlock()
+
+	for i := 0; i < c.pinCount; i++ {
+		c.buf.Get(i)
 	}
+	res, err := c.Do(&c.buf)
+	c.buf.Reset()
+	if err != nil {
+		return err
+	}
+
+	c.bufState = 0
+	for i, v := range res {
+		if v {
+			c.bufState |= 1 << uint(i)
+		}
+	}
+	return nil
 }
 
 func (c *Client) setInput(n int, v bool) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
 	_, err := c.tx(&Request{Cmd: setInput, Pin: uint8(n), State: v})
 	return err
 }
 
 func (c *Client) setPin(n int, v bool) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
 	_, err := c.tx(&Request{Cmd: setPin, Pin: uint8(n), State: v})
 	return err
 }
 
 func (c *Client) getPin(n int) (bool, error) {
+	if err := c.Flush(); err != nil {
+		return false, err
+	}
 	resp, err := c.tx(&Request{Cmd: getPin, Pin: uint8(n)})
 	if err != nil {
 		return false, err
@@ -128,6 +457,105 @@ func (c *Client) getPin(n int) (bool, error) {
 	return resp.State, nil
 }
 
+// SetMask sets the output state of the pins in mask, as a single batch.
+func (c *Client) SetMask(mask, value uint64) error {
+	var b Batch
+	for i := 0; i < c.pinCount; i++ {
+		if mask&(1<<uint(i)) != 0 {
+			b.Set(i, value&(1<<uint(i)) != 0)
+		}
+	}
+	return c.doPort(&b)
+}
+
+// Get reads the state of all pins, as a single batch, also updating the
+// state returned by buffered pins.
+func (c *Client) Get() (uint64, error) {
+	if err := c.Refresh(); err != nil {
+		return 0, err
+	}
+	c.bufMx.Lock()
+	defer c.bufMx.Unlock()
+	return c.bufState, nil
+}
+
+// SetDirection makes the pins in mask inputs, and the rest outputs, as a
+// single batch.
+func (c *Client) SetDirection(mask uint64) error {
+	var b Batch
+	for i := 0; i < c.pinCount; i++ {
+		b.SetInput(i, mask&(1<<uint(i)) != 0)
+	}
+	return c.doPort(&b)
+}
+
+// doPort flushes any queued operations, then runs b.
+func (c *Client) doPort(b *Batch) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	_, err := c.Do(b)
+	return err
+}
+
+// clientModes are the pin modes the protocol can select.
+const clientModes = driver.ModePushPull | driver.ModeHighZ | driver.ModeInputPullup | driver.ModeInputPulldown
+
+// PinModes returns the modes supported by a pin, as reported by the
+// device on reset.
+func (c *Client) PinModes(n int) driver.Mode {
+	if n < 0 || n >= c.pinCount {
+		return 0
+	}
+	return c.modes[n]
+}
+
+// hasPull reports if the pin has a pull resistor, which must be cleared
+// for high-Z.
+func (c *Client) hasPull(n int) bool {
+	return c.PinModes(n)&(driver.ModeInputPullup|driver.ModeInputPulldown) != 0
+}
+
+func (c *Client) setMode(n int, mode driver.Mode) error {
+	if !c.PinModes(n).Has(mode) {
+		return driver.ErrNotSupported
+	}
+	switch mode {
+	case driver.ModePushPull:
+		return c.setInput(n, false)
+	case driver.ModeHighZ:
+		if c.hasPull(n) {
+			return c.setPull(n, driver.PullNone)
+		}
+		return c.setInput(n, true)
+	case driver.ModeInputPullup:
+		return c.setPull(n, driver.PullUp)
+	case driver.ModeInputPulldown:
+		return c.setPull(n, driver.PullDown)
+	}
+	return driver.ErrNotSupported
+}
+
+// setModeBuf queues direction changes, but sets pull resistors
+// immediately, as they can't be batched.
+func (c *Client) setModeBuf(n int, mode driver.Mode) error {
+	if !c.PinModes(n).Has(mode) {
+		return driver.ErrNotSupported
+	}
+	switch mode {
+	case driver.ModePushPull:
+		return c.setInputBuf(n, false)
+	case driver.ModeHighZ:
+		if !c.hasPull(n) {
+			return c.setInputBuf(n, true)
+		}
+	}
+	return c.setMode(n, mode)
+}
+
 type spiClient Client
 
 func (c *Client) SPI(cfg SPIConfig) (spi.Controller, error) {
@@ -146,20 +574,22 @@ func (c *spiClient) SetFill(fill byte) error {
 
 func (c *spiClient) Write(data []byte) (int, error) {
 	// TODO: serial buffer issue?
-	if len(data) > 96 {
-		n, err := c.Write(data[:96])
-		if err != nil {
+	const maxChunk = 96
+
+	// chunks are sent one at a time, as a retransmitted chunk would
+	// otherwise be clocked out after the ones following it
+	var n int
+	for n < len(data) {
+		end := n + maxChunk
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := (*Client)(c).tx(&Request{Cmd: spiWrite, Data: data[n:end]}); err != nil {
 			return n, err
 		}
-		n, err = c.Write(data[96:])
-		return n + 96, err
+		n = end
 	}
-
-	_, err := (*Client)(c).tx(&Request{Cmd: spiWrite, Data: data})
-	if err != nil {
-		return 0, err
-	}
-	return len(data), nil
+	return n, nil
 }
 
 func (c *spiClient) Read(data []byte) (int, error) {
@@ -188,19 +618,19 @@ func (c *spiClient) ReadWriteByte(b byte) (byte, error) {
 	return resp.DataByte, nil
 }
 
-func (c *Client) I2C(cfg I2CConfig) i2c.Bus {
+func (c *Client) I2C(cfg I2CConfig) (i2c.Bus, error) {
 	_, err := c.tx(&Request{Cmd: i2cSetup, I2CConfig: &cfg})
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return (*i2cClient)(c)
+	return (*i2cClient)(c), nil
 }
 
 type i2cClient Client
 
 func (c *i2cClient) Tx(addr uint16, w, r []byte) error {
-	resp, err := (*Client)(c).tx(&Request{Cmd: i2cTx, Data: w, ReadN: uint16(len(r))})
+	resp, err := (*Client)(c).tx(&Request{Cmd: i2cTx, I2CAddr: addr, Data: w, ReadN: uint16(len(r))})
 	if err != nil {
 		return err
 	}
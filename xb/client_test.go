@@ -0,0 +1,226 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// lossyPipe is an in-memory pipe that randomly drops bytes.
+type lossyPipe struct {
+	*io.PipeWriter
+
+	mx   sync.Mutex
+	rand *rand.Rand
+	drop float64
+}
+
+func newLossyPipe(seed int64, drop float64) (*io.PipeReader, *lossyPipe) {
+	pr, pw := io.Pipe()
+	return pr, &lossyPipe{PipeWriter: pw, rand: rand.New(rand.NewSource(seed)), drop: drop}
+}
+
+func (p *lossyPipe) Write(data []byte) (int, error) {
+	p.mx.Lock()
+	kept := make([]byte, 0, len(data))
+	for _, b := range data {
+		if p.rand.Float64() < p.drop {
+			continue
+		}
+		kept = append(kept, b)
+	}
+	p.mx.Unlock()
+
+	_, err := p.PipeWriter.Write(kept)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+type testPins struct {
+	mx    sync.Mutex
+	state []bool
+}
+
+func (p *testPins) PinCount() int { return len(p.state) }
+func (p *testPins) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:            n,
+		SetInputFunc: func(int, bool) error { return nil },
+		SetFunc: func(n int, v bool) error {
+			p.mx.Lock()
+			defer p.mx.Unlock()
+			p.state[n] = v
+			return nil
+		},
+		GetFunc: func(n int) (bool, error) {
+			p.mx.Lock()
+			defer p.mx.Unlock()
+			return p.state[n], nil
+		},
+	}
+}
+
+func newTestClient(t *testing.T, drop float64, cfg ClientConfig) (*Client, *testPins) {
+	t.Helper()
+
+	reqR, reqW := newLossyPipe(1, drop)
+	respR, respW := newLossyPipe(2, drop)
+	pins := &testPins{state: make([]bool, 8)}
+	srv := NewServer(reqR, respW, pins)
+	srv.Framing = cfg.Framing
+	go srv.Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+
+	c, err := NewClientConfig(respR, reqW, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, pins
+}
+
+func TestClient_Lossy(t *testing.T) {
+	for _, f := range []Framing{FramingRaw, FramingCOBS} {
+		t.Run(framingName(f), func(t *testing.T) {
+			testClientLossy(t, f)
+		})
+	}
+}
+
+func testClientLossy(t *testing.T, f Framing) {
+	c, pins := newTestClient(t, 0.01, ClientConfig{Timeout: 20 * time.Millisecond, Retries: 50, Framing: f})
+
+	var wg sync.WaitGroup
+	for n := 0; n < c.PinCount(); n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			p := c.Pin(n)
+			for i := 0; i < 20; i++ {
+				want := i%2 == 0
+				if err := p.Set(want); err != nil {
+					t.Error(err)
+					return
+				}
+				got, err := p.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got != want {
+					t.Errorf("pin %d: got %v; want %v", n, got, want)
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	for n, v := range pins.state {
+		if v {
+			t.Errorf("pin %d: expected low", n)
+		}
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	c, _ := newTestClient(t, 0, ClientConfig{Timeout: 10 * time.Millisecond, Retries: 2})
+
+	// drop everything from now on
+	c.w = NewChunkWriter(io.Discard, FramingRaw)
+
+	err := c.Ping()
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v; want ErrTimeout", err)
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	c, pins := newTestClient(t, 0.01, ClientConfig{Timeout: 20 * time.Millisecond, Retries: 50})
+
+	var b Batch
+	b.Set(1, true)
+	b.Delay(time.Millisecond)
+	idx := b.Get(1)
+	b.Set(1, false)
+	b.Get(1)
+	for i := 0; i < 40; i++ {
+		b.Set(2, i%2 == 0)
+		b.Get(2)
+	}
+
+	res, err := c.Do(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 42 {
+		t.Fatalf("got %d results; want 42", len(res))
+	}
+	if !res[idx] || res[1] {
+		t.Errorf("pin 1: got %v, %v; want true, false", res[0], res[1])
+	}
+	for i, v := range res[2:] {
+		if v != (i%2 == 0) {
+			t.Errorf("pin 2 read %d: got %v", i, v)
+		}
+	}
+
+	p := c.BufferedPin(3)
+	if err := p.High(); err != nil {
+		t.Fatal(err)
+	}
+	if pins.state[3] {
+		t.Error("pin 3 set before Flush")
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v {
+		t.Error("pin 3: expected high after Refresh")
+	}
+}
+
+func TestClient_Port(t *testing.T) {
+	c, pins := newTestClient(t, 0, ClientConfig{Timeout: time.Second})
+
+	if err := c.SetMask(0b1111, 0b1010); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetMask(0b0001, 0b0001); err != nil {
+		t.Fatal(err)
+	}
+	v, err := c.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0b1011 {
+		t.Errorf("Get() = %b; want 1011", v)
+	}
+
+	pins.mx.Lock()
+	pins.state[5] = true
+	pins.mx.Unlock()
+	v, err = c.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0b101011 {
+		t.Errorf("Get() = %b; want 101011", v)
+	}
+}
@@ -0,0 +1,60 @@
+package xb
+
+import "errors"
+
+var errCOBS = errors.New("xb: invalid COBS data")
+
+// cobsEnc encodes a stream of bytes with Consistent Overhead Byte Stuffing,
+// so that the output never contains a zero byte.
+type cobsEnc struct {
+	codeIdx int
+	code    byte
+}
+
+func (e *cobsEnc) start(dst []byte) []byte {
+	e.codeIdx = len(dst)
+	e.code = 1
+	return append(dst, 0)
+}
+
+func (e *cobsEnc) append(dst []byte, b byte) []byte {
+	if b == 0 {
+		dst[e.codeIdx] = e.code
+		return e.start(dst)
+	}
+
+	dst = append(dst, b)
+	e.code++
+	if e.code == 0xff {
+		dst[e.codeIdx] = e.code
+		return e.start(dst)
+	}
+	return dst
+}
+
+func (e *cobsEnc) finish(dst []byte) []byte {
+	dst[e.codeIdx] = e.code
+	return dst
+}
+
+// cobsDecode appends the decoding of src, without its delimiter, to dst.
+func cobsDecode(dst, src []byte) ([]byte, error) {
+	for len(src) > 0 {
+		code := src[0]
+		if code == 0 || int(code) > len(src) {
+			return dst, errCOBS
+		}
+		for _, b := range src[1:code] {
+			if b == 0 {
+				return dst, errCOBS
+			}
+		}
+
+		dst = append(dst, src[1:code]...)
+		src = src[code:]
+		if code != 0xff && len(src) > 0 {
+			dst = append(dst, 0)
+		}
+	}
+	return dst, nil
+}
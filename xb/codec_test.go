@@ -0,0 +1,97 @@
+package xb
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/mastercactapus/embedded/xb/tlv"
+	"github.com/mastercactapus/embedded/xb/tlv/tlvgen"
+)
+
+// nilEmpty replaces empty byte slices with nil, since they aren't encoded.
+func nilEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	cfg := &quick.Config{MaxCount: 2000}
+
+	err := quick.Check(func(req Request) bool {
+		req.Data = nilEmpty(req.Data)
+		req.Ext = nilEmpty(req.Ext)
+		var got Request
+		if err := got.decode(req.encode()); err != nil {
+			t.Log(err)
+			return false
+		}
+		return reflect.DeepEqual(got, req)
+	}, cfg)
+	if err != nil {
+		t.Error("Request:", err)
+	}
+
+	err = quick.Check(func(resp Response) bool {
+		resp.Data = nilEmpty(resp.Data)
+		resp.Ext = nilEmpty(resp.Ext)
+		var got Response
+		if err := got.decode(resp.encode()); err != nil {
+			t.Log(err)
+			return false
+		}
+		return reflect.DeepEqual(got, resp)
+	}, cfg)
+	if err != nil {
+		t.Error("Response:", err)
+	}
+
+	err = quick.Check(func(ev Event) bool {
+		var got Event
+		if err := tlv.Unmarshal(tlv.Marshal(&ev), &got); err != nil {
+			t.Log(err)
+			return false
+		}
+		return got == ev
+	}, cfg)
+	if err != nil {
+		t.Error("Event:", err)
+	}
+}
+
+// TestCodec_Truncated checks that every prefix of a message either decodes
+// or returns an error, without panicking.
+func TestCodec_Truncated(t *testing.T) {
+	err := quick.Check(func(req Request) bool {
+		data := req.encode()
+		for i := range data {
+			var got Request
+			got.decode(data[:i])
+		}
+		return true
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCodec_Generated(t *testing.T) {
+	src, err := tlvgen.Generate(".", "messages_tlv.go", []string{
+		"Request", "Response", "SPIConfig", "I2CConfig", "CaptureConfig", "UARTConfig", "Event", "captureInfo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cur, err := os.ReadFile("messages_tlv.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, cur) {
+		t.Error("messages_tlv.go is out of date; run go generate")
+	}
+}
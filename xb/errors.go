@@ -0,0 +1,86 @@
+package xb
+
+import (
+	"errors"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+// ErrCode identifies the cause of a failed request.
+type ErrCode uint8
+
+const (
+	// CodeOK indicates the request succeeded.
+	CodeOK ErrCode = iota
+
+	// CodeUnknown is used for errors without a more specific code,
+	// the Response Err field describes the problem.
+	CodeUnknown
+
+	CodeUnsupported
+	CodeBadPin
+	CodeBadRequest
+	CodeNotInitialized
+	CodePinNotSupported
+
+	CodeI2CNack
+	CodeI2CBadAddr
+	CodeI2CBusStuck
+
+	CodeTriggerTimeout
+	CodeNoReplay
+)
+
+var (
+	ErrUnsupported    = errors.New("xb: unsupported command")
+	ErrBadPin         = errors.New("xb: invalid pin")
+	ErrBadRequest     = errors.New("xb: invalid request")
+	ErrNotInitialized = errors.New("xb: not initialized")
+)
+
+var codeErrs = [...]error{
+	CodeUnsupported:     ErrUnsupported,
+	CodeBadPin:          ErrBadPin,
+	CodeBadRequest:      ErrBadRequest,
+	CodeNotInitialized:  ErrNotInitialized,
+	CodePinNotSupported: driver.ErrNotSupported,
+	CodeI2CNack:         i2c.ErrNack,
+	CodeI2CBadAddr:      i2c.ErrBadAddr,
+	CodeI2CBusStuck:     i2c.ErrBusStuck,
+	CodeTriggerTimeout:  ErrTriggerTimeout,
+	CodeNoReplay:        ErrNoReplay,
+}
+
+// Err returns the sentinel error for the code, or nil for
+// CodeOK and CodeUnknown.
+func (c ErrCode) Err() error {
+	if int(c) >= len(codeErrs) {
+		return nil
+	}
+	return codeErrs[c]
+}
+
+func errCode(err error) ErrCode {
+	if err == nil {
+		return CodeOK
+	}
+	for code, e := range codeErrs {
+		if e != nil && errors.Is(err, e) {
+			return ErrCode(code)
+		}
+	}
+	return CodeUnknown
+}
+
+// RemoteError is an error reported by the device.
+//
+// It unwraps to the sentinel error for Code, if any, so it
+// can be checked with errors.Is.
+type RemoteError struct {
+	Code ErrCode
+	Msg  string
+}
+
+func (e *RemoteError) Error() string { return e.Msg }
+func (e *RemoteError) Unwrap() error { return e.Code.Err() }
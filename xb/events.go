@@ -0,0 +1,138 @@
+package xb
+
+import (
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/term/ascii"
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+// Edge selects which pin transitions generate an Event.
+type Edge = driver.Edge
+
+const (
+	EdgeNone    = driver.EdgeNone
+	EdgeRising  = driver.EdgeRising
+	EdgeFalling = driver.EdgeFalling
+	EdgeBoth    = driver.EdgeBoth
+)
+
+// Event is an unsolicited notification of a pin change, sent by the
+// device as a 'V' chunk.
+type Event struct {
+	Pin   uint8 `tlv:"p"`
+	State bool  `tlv:"s"`
+
+	// Time is the device uptime, in microseconds, when the change
+	// was detected. It wraps roughly every 71 minutes.
+	Time uint32 `tlv:"t"`
+}
+
+// Watch arms edge detection on a pin. Matching changes are delivered
+// on the Events channel. EdgeNone disarms the pin.
+func (c *Client) Watch(pin int, edge Edge) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	_, err := c.tx(&Request{Cmd: pinWatch, Pin: uint8(pin), DataByte: uint8(edge)})
+	return err
+}
+
+// setInterrupt watches a pin, calling fn from the reader goroutine on
+// each matching event.
+func (c *Client) setInterrupt(n int, edge Edge, fn func()) error {
+	if fn == nil {
+		edge = EdgeNone
+	}
+
+	c.intMx.Lock()
+	if edge == EdgeNone {
+		delete(c.interrupts, uint8(n))
+	} else {
+		c.interrupts[uint8(n)] = fn
+	}
+	c.intMx.Unlock()
+
+	return c.Watch(n, edge)
+}
+
+// interrupt calls the interrupt handler for an event, if any.
+func (c *Client) interrupt(ev Event) {
+	c.intMx.Lock()
+	fn := c.interrupts[ev.Pin]
+	c.intMx.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// Events returns the channel pin change events are delivered on.
+//
+// Events are dropped if the channel is full. The channel is closed once
+// reading from the device fails.
+func (c *Client) Events() <-chan Event { return c.events }
+
+func (s *Server) setWatch(pin uint8, edge Edge) error {
+	p, err := s.pin(pin)
+	if err != nil {
+		return err
+	}
+	if edge&^EdgeBoth != 0 {
+		return ascii.Errorf("pinWatch: edge %d: %w", uint8(edge), ErrBadRequest)
+	}
+
+	v, err := p.Get()
+	if err != nil {
+		return err
+	}
+	s.last[pin] = v
+	s.watch[pin] = edge
+
+	if edge != EdgeNone && !s.watching {
+		s.watching = true
+		go s.watchLoop()
+	}
+	return nil
+}
+
+func (s *Server) watchLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.PollInterval):
+		}
+
+		s.mx.Lock()
+		s.pollWatches()
+		s.mx.Unlock()
+	}
+}
+
+func (s *Server) pollWatches() {
+	for n, edge := range s.watch {
+		if edge == EdgeNone {
+			continue
+		}
+
+		v, err := s.pins[n].Get()
+		if err != nil {
+			continue
+		}
+		if v == s.last[n] {
+			continue
+		}
+		s.last[n] = v
+
+		if v && edge&EdgeRising == 0 {
+			continue
+		}
+		if !v && edge&EdgeFalling == 0 {
+			continue
+		}
+
+		ev := Event{Pin: uint8(n), State: v, Time: uint32(time.Since(s.start).Microseconds())}
+		s.writeChunk('V', tlv.Marshal(&ev))
+	}
+}
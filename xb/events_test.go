@@ -0,0 +1,147 @@
+package xb
+
+import (
+	"bufio"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+func TestClient_Events(t *testing.T) {
+	c, pins := newTestClient(t, 0, ClientConfig{Timeout: time.Second})
+
+	set := func(n int, v bool) {
+		pins.mx.Lock()
+		pins.state[n] = v
+		pins.mx.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := c.Watch(2, EdgeRising); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Watch(3, EdgeBoth); err != nil {
+		t.Fatal(err)
+	}
+
+	set(2, true)
+	set(2, false)
+	set(3, true)
+	set(3, false)
+
+	want := []Event{{Pin: 2, State: true}, {Pin: 3, State: true}, {Pin: 3, State: false}}
+	var last uint32
+	for _, w := range want {
+		select {
+		case ev := <-c.Events():
+			if ev.Pin != w.Pin || ev.State != w.State {
+				t.Errorf("got pin %d=%v; want pin %d=%v", ev.Pin, ev.State, w.Pin, w.State)
+			}
+			if ev.Time < last {
+				t.Errorf("timestamp went backwards: %d < %d", ev.Time, last)
+			}
+			last = ev.Time
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+	}
+
+	select {
+	case ev := <-c.Events():
+		t.Errorf("unexpected event: %+v", ev)
+	default:
+	}
+}
+
+func TestClient_Interrupt(t *testing.T) {
+	c, pins := newTestClient(t, 0, ClientConfig{Timeout: time.Second})
+
+	ip, ok := c.Pin(4).(driver.InterruptPin)
+	if !ok {
+		t.Fatal("pin does not implement driver.InterruptPin")
+	}
+	ch := make(chan struct{}, 10)
+	if err := ip.SetInterrupt(driver.EdgeFalling, func() { ch <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []bool{true, false, true, false} {
+		pins.mx.Lock()
+		pins.state[4] = v
+		pins.mx.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(ch) != 2 {
+		t.Errorf("got %d interrupts; want 2", len(ch))
+	}
+
+	if err := ip.SetInterrupt(driver.EdgeNone, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_WatchHighPins(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	pins := &testPins{state: make([]bool, 70)}
+	go NewServer(reqR, respW, pins).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+
+	go WriteChunk(reqW, 'Q', (&Request{ID: 1, Cmd: pinWatch, Pin: 66, DataByte: uint8(EdgeBoth)}).encode())
+	r := bufio.NewReader(respR)
+	typeCode, data, err := ReadChunk(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := resp.decode(data); typeCode != 'R' || err != nil || resp.Err != "" {
+		t.Fatalf("got %c chunk (%v, %s); want response", typeCode, err, resp.Err)
+	}
+
+	for _, v := range []bool{true, false} {
+		pins.mx.Lock()
+		pins.state[2] = v
+		pins.state[66] = v
+		pins.mx.Unlock()
+
+		typeCode, data, err = ReadChunk(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ev Event
+		if err := tlv.Unmarshal(data, &ev); typeCode != 'V' || err != nil {
+			t.Fatalf("got %c chunk (%v); want event", typeCode, err)
+		}
+		if ev.Pin != 66 || ev.State != v {
+			t.Errorf("got pin %d=%v; want pin 66=%v", ev.Pin, ev.State, v)
+		}
+	}
+}
+
+func TestClient_EventsClosed(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go NewServer(reqR, respW, &testPins{state: make([]bool, 8)}).Serve()
+	t.Cleanup(func() { reqW.Close() })
+
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	respW.Close()
+
+	select {
+	case _, ok := <-c.Events():
+		if ok {
+			t.Error("got event; want closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Events not closed after read error")
+	}
+}
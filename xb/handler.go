@@ -0,0 +1,65 @@
+package xb
+
+import (
+	"github.com/mastercactapus/embedded/term/ascii"
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+// CmdUser is the first command number available for custom commands.
+// Lower numbers are reserved for built-in commands.
+const CmdUser uint8 = 0x80
+
+// HandlerFunc handles a custom command.
+//
+// The request, including its Data and Ext, is only valid until the
+// handler returns.
+type HandlerFunc func(Request) (*Response, error)
+
+// Handle registers a handler for a custom command. It must be called
+// before Serve.
+//
+// Handlers are called one at a time, and not concurrently with any
+// built-in command. Handle panics if cmd is less than CmdUser or
+// already registered.
+func (s *Server) Handle(cmd uint8, h HandlerFunc) {
+	if cmd < CmdUser {
+		panic(ascii.Sprintf("xb: command %d is reserved", cmd))
+	}
+	if s.handlers == nil {
+		s.handlers = make(map[uint8]HandlerFunc)
+	}
+	if _, ok := s.handlers[cmd]; ok {
+		panic(ascii.Sprintf("xb: duplicate handler for command %d", cmd))
+	}
+	s.handlers[cmd] = h
+}
+
+// Call sends a custom command to the server and waits for the response.
+//
+// Errors returned by the handler are returned as a *RemoteError.
+func (c *Client) Call(cmd uint8, req Request) (*Response, error) {
+	if cmd < CmdUser {
+		return nil, ErrBadRequest
+	}
+
+	req.Cmd = cmd
+	resp, err := c.tx(&req)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Message is a custom sub-message carried in the Ext field of a Request
+// or Response. Implementations can be written by hand or generated with
+// tlvgen.
+type Message interface {
+	tlv.Marshaler
+	tlv.Unmarshaler
+}
+
+// EncodeMessage returns the encoded form of m, for use as Ext.
+func EncodeMessage(m Message) []byte { return tlv.Marshal(m) }
+
+// DecodeMessage decodes data, usually from Ext, into m.
+func DecodeMessage(data []byte, m Message) error { return tlv.Unmarshal(data, m) }
@@ -0,0 +1,106 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+type motorCmd struct {
+	Speed   uint16
+	Reverse bool
+	Name    string
+}
+
+func (m *motorCmd) MarshalTLV(e *tlv.Encoder) {
+	e.Uint16(1, m.Speed)
+	e.Bool(2, m.Reverse)
+	e.String(3, m.Name)
+}
+
+func (m *motorCmd) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = motorCmd{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			m.Speed = d.Uint16()
+		case 2:
+			m.Reverse = d.Bool()
+		case 3:
+			m.Name = d.String()
+		}
+	}
+	return d.Err()
+}
+
+func TestServer_Handle(t *testing.T) {
+	const (
+		cmdMotor = CmdUser + iota
+		cmdFail
+		cmdMissing
+	)
+
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	srv := NewServer(reqR, respW, &testPins{state: make([]bool, 2)})
+
+	var got motorCmd
+	srv.Handle(cmdMotor, func(req Request) (*Response, error) {
+		if err := DecodeMessage(req.Ext, &got); err != nil {
+			return nil, err
+		}
+		reply := motorCmd{Speed: got.Speed / 2, Name: "ack"}
+		return &Response{Ext: EncodeMessage(&reply)}, nil
+	})
+	srv.Handle(cmdFail, func(req Request) (*Response, error) {
+		return nil, ErrBadPin
+	})
+	go srv.Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := motorCmd{Speed: 1000, Reverse: true, Name: "left"}
+	resp, err := c.Call(cmdMotor, Request{Ext: EncodeMessage(&want)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("server got %+v; want %+v", got, want)
+	}
+	var reply motorCmd
+	if err := DecodeMessage(resp.Ext, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != (motorCmd{Speed: 500, Name: "ack"}) {
+		t.Errorf("unexpected reply %+v", reply)
+	}
+
+	_, err = c.Call(cmdFail, Request{})
+	if !errors.Is(err, ErrBadPin) {
+		t.Errorf("got %v; want ErrBadPin", err)
+	}
+	_, err = c.Call(cmdMissing, Request{})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got %v; want ErrUnsupported", err)
+	}
+	_, err = c.Call(setPin, Request{})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("got %v; want ErrBadRequest", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for reserved command")
+		}
+	}()
+	srv.Handle(setPin, nil)
+}
@@ -0,0 +1,151 @@
+package xb
+
+import (
+	"errors"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/serial/i2c"
+	"github.com/mastercactapus/embedded/term/ascii"
+)
+
+// I2CProvider can be implemented by the Pinner passed to NewServer to use
+// a hardware I2C peripheral. It should return driver.ErrNotSupported if the
+// requested pins can't be used, and the server will fall back to a software
+// controller.
+type I2CProvider interface {
+	I2C(cfg I2CConfig) (i2c.Bus, error)
+}
+
+var (
+	_ i2c.Scanner    = (*i2cClient)(nil)
+	_ i2c.Transferer = (*i2cClient)(nil)
+	_ i2c.Recoverer  = (*i2cClient)(nil)
+)
+
+func (s *Server) setupI2C(cfg I2CConfig) error {
+	if int(cfg.SDA) >= len(s.pins) || int(cfg.SCL) >= len(s.pins) {
+		return ascii.Errorf("i2cSetup: %w", ErrBadPin)
+	}
+	s.i2cCfg = cfg
+
+	if p, ok := s.dev.(I2CProvider); ok {
+		bus, err := p.I2C(cfg)
+		if err == nil {
+			s.i2c = bus
+			return nil
+		}
+		if !errors.Is(err, driver.ErrNotSupported) {
+			return err
+		}
+	}
+
+	bus := i2c.New(i2c.NewSoftController(s.pins[cfg.SDA], s.pins[cfg.SCL]))
+	if cfg.Baud != 0 {
+		err := bus.SetBaudrate(cfg.Baud)
+		if err != nil && !errors.Is(err, i2c.ErrUnsupported) {
+			return err
+		}
+	}
+	s.i2c = bus
+	return nil
+}
+
+// encodeI2CMsgs encodes each message as a flag byte (bit 0 set for reads),
+// the address and length as big-endian uint16, followed by the data for writes.
+func encodeI2CMsgs(msgs []i2c.Msg) []byte {
+	var data []byte
+	for _, m := range msgs {
+		var flags byte
+		if m.Read {
+			flags |= 1
+		}
+		data = append(data, flags, byte(m.Addr>>8), byte(m.Addr), byte(len(m.Data)>>8), byte(len(m.Data)))
+		if !m.Read {
+			data = append(data, m.Data...)
+		}
+	}
+	return data
+}
+
+var errI2CMsgs = ascii.Errorf("i2cTransfer: invalid messages: %w", ErrBadRequest)
+
+// maxI2CMsgs is the most messages a single transfer may contain.
+const maxI2CMsgs = 16
+
+// decodeI2CMsgs decodes messages from encodeI2CMsgs. Reads are limited
+// to maxReadN bytes in total.
+func decodeI2CMsgs(data []byte) ([]i2c.Msg, error) {
+	var msgs []i2c.Msg
+	var readN int
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, errI2CMsgs
+		}
+		if len(msgs) == maxI2CMsgs {
+			return nil, ascii.Errorf("i2cTransfer: more than %d messages: %w", maxI2CMsgs, ErrBadRequest)
+		}
+		m := i2c.Msg{
+			Read: data[0]&1 != 0,
+			Addr: uint16(data[1])<<8 | uint16(data[2]),
+		}
+		n := int(data[3])<<8 | int(data[4])
+		data = data[5:]
+		if m.Read {
+			readN += n
+			if readN > maxReadN {
+				return nil, ascii.Errorf("i2cTransfer: read of %d bytes: %w", readN, ErrBadRequest)
+			}
+			m.Data = make([]byte, n)
+		} else {
+			if len(data) < n {
+				return nil, errI2CMsgs
+			}
+			m.Data = data[:n]
+			data = data[n:]
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// Scan probes all addresses on the device in a single request.
+func (c *i2cClient) Scan() (res i2c.ScanResult, err error) {
+	resp, err := (*Client)(c).tx(&Request{Cmd: i2cScan})
+	if err != nil {
+		return res, err
+	}
+	if len(resp.Data) != len(res.Read)+len(res.Write) {
+		return res, errors.New("xb: invalid scan response")
+	}
+	copy(res.Read[:], resp.Data)
+	copy(res.Write[:], resp.Data[len(res.Read):])
+	return res, nil
+}
+
+// Recover attempts to free a stuck bus on the device and then
+// reconfigures it.
+func (c *i2cClient) Recover() error {
+	_, err := (*Client)(c).tx(&Request{Cmd: i2cRecover})
+	return err
+}
+
+// Transfer performs all msgs on the device in a single request.
+func (c *i2cClient) Transfer(msgs []i2c.Msg) error {
+	resp, err := (*Client)(c).tx(&Request{Cmd: i2cTransfer, Data: encodeI2CMsgs(msgs)})
+	if err != nil {
+		return err
+	}
+
+	data := resp.Data
+	for _, m := range msgs {
+		if !m.Read {
+			continue
+		}
+		if len(data) < len(m.Data) {
+			return errors.New("xb: short transfer response")
+		}
+		copy(m.Data, data)
+		data = data[len(m.Data):]
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package xb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+// fakeBus is a bus with a single register-based device at 0x42.
+type fakeBus struct {
+	reg  byte
+	regs [256]byte
+}
+
+func (b *fakeBus) Tx(addr uint16, w, r []byte) error {
+	if addr != 0x42 {
+		return i2c.ErrNack
+	}
+	if len(w) > 0 {
+		b.reg = w[0]
+		for _, v := range w[1:] {
+			b.regs[b.reg] = v
+			b.reg++
+		}
+	}
+	for i := range r {
+		r[i] = b.regs[b.reg]
+		b.reg++
+	}
+	return nil
+}
+
+type i2cTestPins struct {
+	*testPins
+	bus *fakeBus
+}
+
+func (p i2cTestPins) I2C(cfg I2CConfig) (i2c.Bus, error) { return p.bus, nil }
+
+func TestClient_I2C(t *testing.T) {
+	reqR, reqW := newLossyPipe(1, 0)
+	respR, respW := newLossyPipe(2, 0)
+	dev := i2cTestPins{testPins: &testPins{state: make([]bool, 8)}, bus: &fakeBus{}}
+	go NewServer(reqR, respW, dev).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+
+	c, err := NewClientConfig(respR, reqW, ClientConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus, err := c.I2C(I2CConfig{SDA: 4, SCL: 5, Baud: 400e3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := i2c.Scan(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := byte(0); i < 127; i++ {
+		if res.CanRead(i) != (i == 0x42) {
+			t.Errorf("scan 0x%02x: got %v", i, res.CanRead(i))
+		}
+	}
+
+	if err := bus.Tx(0x42, []byte{0x10, 1, 2, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Tx(0x43, []byte{0x10}, nil); err == nil {
+		t.Error("expected error for missing device")
+	}
+
+	buf := make([]byte, 3)
+	err = i2c.Transfer(bus, []i2c.Msg{
+		{Addr: 0x42, Data: []byte{0x11}},
+		{Addr: 0x42, Read: true, Data: buf[:1]},
+		{Addr: 0x42, Data: []byte{0x10}},
+		{Addr: 0x42, Read: true, Data: buf[1:]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte{2, 1, 2}) {
+		t.Errorf("got %v; want [2 1 2]", buf)
+	}
+}
+
+func TestDecodeI2CMsgs_Limits(t *testing.T) {
+	read := func(n int) i2c.Msg { return i2c.Msg{Addr: 0x42, Read: true, Data: make([]byte, n)} }
+
+	if _, err := decodeI2CMsgs(encodeI2CMsgs([]i2c.Msg{read(maxReadN / 2), read(maxReadN / 2)})); err != nil {
+		t.Errorf("read of maxReadN: %v", err)
+	}
+
+	_, err := decodeI2CMsgs(encodeI2CMsgs([]i2c.Msg{read(maxReadN / 2), read(maxReadN/2 + 1)}))
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("read over maxReadN: got %v; want ErrBadRequest", err)
+	}
+
+	_, err = decodeI2CMsgs(encodeI2CMsgs([]i2c.Msg{read(0xffff)}))
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("read of 0xffff: got %v; want ErrBadRequest", err)
+	}
+
+	msgs := make([]i2c.Msg, maxI2CMsgs+1)
+	for i := range msgs {
+		msgs[i] = read(0)
+	}
+	_, err = decodeI2CMsgs(encodeI2CMsgs(msgs))
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("%d messages: got %v; want ErrBadRequest", len(msgs), err)
+	}
+}
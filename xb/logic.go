@@ -0,0 +1,322 @@
+package xb
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/term/ascii"
+	"github.com/mastercactapus/embedded/xb/capture"
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+// Trigger is the condition that starts a capture.
+type Trigger uint8
+
+const (
+	TriggerNone Trigger = iota
+	TriggerHigh
+	TriggerLow
+	TriggerRising
+	TriggerFalling
+)
+
+// CaptureConfig configures a logic-analyzer capture on the device.
+type CaptureConfig struct {
+	// Mask selects the pins to sample.
+	Mask uint64 `tlv:"1"`
+
+	// Rate is the sample rate in Hz. The device samples as fast as it
+	// can if it can't keep up. Samples may take at most 10 seconds at
+	// this rate.
+	Rate uint32 `tlv:"2"`
+
+	// Samples is the total number of samples to capture, including
+	// PreTrigger samples.
+	Samples uint16 `tlv:"3"`
+
+	// PreTrigger is the number of samples to keep from before the
+	// trigger condition was met.
+	PreTrigger uint16 `tlv:"4"`
+
+	Trigger    Trigger `tlv:"5"`
+	TriggerPin uint8   `tlv:"6"`
+
+	// Timeout is how long, in milliseconds, to wait for the trigger.
+	// Defaults to 1000, and may be at most 10000.
+	Timeout uint32 `tlv:"7"`
+}
+
+// captureInfo describes a completed capture held by the device.
+type captureInfo struct {
+	Samples  uint16 `tlv:"1"`
+	Trigger  uint16 `tlv:"2"`
+	Width    uint8  `tlv:"3"`
+	Duration uint32 `tlv:"4"` // in microseconds
+}
+
+// maxCaptureLen is the largest capture buffer the server will allocate.
+const maxCaptureLen = 8192
+
+// The device serves nothing else during a capture, so both waiting for
+// the trigger and sampling are limited.
+const (
+	maxTriggerTimeout = 10 * time.Second
+	maxCaptureTime    = 10 * time.Second
+)
+
+var ErrTriggerTimeout = errors.New("xb: capture trigger timed out")
+
+// Capture runs a capture on the device and downloads the samples.
+func (c *Client) Capture(cfg CaptureConfig) (*capture.Capture, error) {
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
+	if cfg.Timeout == 0 {
+		timeout = time.Second
+	}
+	if cfg.Rate > 0 {
+		timeout += time.Duration(cfg.Samples) * time.Second / time.Duration(cfg.Rate)
+	}
+	cl, err := c.startTimeout(&Request{Cmd: captureStart, CaptureConfig: &cfg}, timeout+c.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cl.wait()
+	if err != nil {
+		return nil, err
+	}
+
+	var info captureInfo
+	if err := tlv.Unmarshal(resp.Data, &info); err != nil {
+		return nil, err
+	}
+	if info.Width == 0 {
+		return nil, errors.New("xb: invalid capture response")
+	}
+
+	res := &capture.Capture{
+		Rate:    uint64(cfg.Rate),
+		Trigger: -1,
+		Width:   int(info.Width),
+		Data:    make([]byte, int(info.Samples)*int(info.Width)),
+	}
+	if info.Duration > 0 && info.Samples > 1 {
+		// actual rate, in case the device couldn't keep up
+		res.Rate = uint64(info.Samples-1) * 1e6 / uint64(info.Duration)
+	}
+	if cfg.Trigger != TriggerNone {
+		res.Trigger = int(info.Trigger)
+	}
+	for i := 0; i < 64; i++ {
+		if cfg.Mask&(1<<uint(i)) != 0 {
+			res.Channels = append(res.Channels, "D"+strconv.Itoa(i))
+		}
+	}
+
+	// pipeline the reads, waiting for them in order
+	var calls []*call
+	var off int
+	waitNext := func() {
+		resp, wErr := calls[0].wait()
+		calls = calls[1:]
+		if err == nil && wErr != nil {
+			err = wErr
+		}
+		if err != nil {
+			return
+		}
+		off += copy(res.Data[off:], resp.Data)
+	}
+	for start := 0; start < len(res.Data) && err == nil; start += maxReadN {
+		n := len(res.Data) - start
+		if n > maxReadN {
+			n = maxReadN
+		}
+		if len(calls) == c.cfg.MaxInFlight {
+			waitNext()
+			if err != nil {
+				break
+			}
+		}
+
+		cl, sErr := c.start(&Request{Cmd: captureRead, Offset: uint32(start), ReadN: uint16(n)})
+		if sErr != nil {
+			err = sErr
+			break
+		}
+		calls = append(calls, cl)
+	}
+	for len(calls) > 0 {
+		waitNext()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *Server) readCapture(off uint32, n uint16) (*Response, error) {
+	// int is 32 bits on most devices
+	end := uint64(off) + uint64(n)
+	if end > uint64(len(s.capture)) {
+		return nil, ascii.Errorf("captureRead: offset %d: %w", off, ErrBadRequest)
+	}
+
+	return &Response{Data: s.capture[off:end]}, nil
+}
+
+func (s *Server) runCapture(cfg CaptureConfig) (*Response, error) {
+	s.capture = nil
+
+	var pins []driver.Pin
+	for i := 0; i < 64; i++ {
+		if cfg.Mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if i >= len(s.pins) {
+			return nil, ascii.Errorf("capture: %w", ErrBadPin)
+		}
+		pins = append(pins, s.pins[i])
+	}
+	width := (len(pins) + 7) / 8
+	switch {
+	case len(pins) == 0, cfg.Rate == 0, cfg.Samples == 0, cfg.PreTrigger > cfg.Samples:
+		return nil, ascii.Errorf("capture: %w", ErrBadRequest)
+	case int(cfg.Samples)*width > maxCaptureLen:
+		return nil, ascii.Errorf("capture: %d samples too large: %w", cfg.Samples, ErrBadRequest)
+	case cfg.Trigger > TriggerFalling:
+		return nil, ascii.Errorf("capture: trigger %d: %w", uint8(cfg.Trigger), ErrBadRequest)
+	case uint64(cfg.Timeout)*uint64(time.Millisecond) > uint64(maxTriggerTimeout):
+		return nil, ascii.Errorf("capture: timeout %dms: %w", cfg.Timeout, ErrBadRequest)
+	case uint64(cfg.Samples)*uint64(time.Second)/uint64(cfg.Rate) > uint64(maxCaptureTime):
+		return nil, ascii.Errorf("capture: %d samples at %dHz: %w", cfg.Samples, cfg.Rate, ErrBadRequest)
+	}
+	trigPin, err := s.pin(cfg.TriggerPin)
+	if cfg.Trigger != TriggerNone && err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = time.Second
+	}
+
+	buf := make([]byte, int(cfg.Samples)*width)
+	period := time.Second / time.Duration(cfg.Rate)
+	read := func(dst []byte) error {
+		for i := range dst {
+			dst[i] = 0
+		}
+		for i, p := range pins {
+			v, err := p.Get()
+			if err != nil {
+				return err
+			}
+			if v {
+				dst[i/8] |= 1 << uint(i%8)
+			}
+		}
+		return nil
+	}
+
+	// Samples before the trigger go into a ring at the start of buf.
+	pre := int(cfg.PreTrigger)
+	var nPre, ringIdx int
+	var prev, triggered bool
+	start := time.Now()
+	next := start
+	if cfg.Trigger == TriggerRising || cfg.Trigger == TriggerFalling {
+		if prev, err = trigPin.Get(); err != nil {
+			return nil, err
+		}
+	}
+	cur := make([]byte, width)
+	var trigTime, lastTime time.Time
+	for !triggered {
+		sleepUntil(next)
+		next = next.Add(period)
+		trigTime = time.Now()
+		if trigTime.Sub(start) > timeout {
+			return nil, ErrTriggerTimeout
+		}
+
+		if err := read(cur); err != nil {
+			return nil, err
+		}
+
+		switch cfg.Trigger {
+		case TriggerNone:
+			triggered = true
+		case TriggerHigh, TriggerLow:
+			v, err := trigPin.Get()
+			if err != nil {
+				return nil, err
+			}
+			triggered = v == (cfg.Trigger == TriggerHigh)
+		case TriggerRising, TriggerFalling:
+			v, err := trigPin.Get()
+			if err != nil {
+				return nil, err
+			}
+			triggered = v != prev && v == (cfg.Trigger == TriggerRising)
+			prev = v
+		}
+		if triggered || pre == 0 {
+			continue
+		}
+
+		copy(buf[ringIdx*width:], cur)
+		ringIdx = (ringIdx + 1) % pre
+		if nPre < pre {
+			nPre++
+		}
+	}
+
+	// put the pre-trigger samples in order
+	if nPre == pre && ringIdx > 0 {
+		ring := buf[:pre*width]
+		rotated := make([]byte, len(ring))
+		n := copy(rotated, ring[ringIdx*width:])
+		copy(rotated[n:], ring[:ringIdx*width])
+		copy(ring, rotated)
+	}
+
+	total := nPre + int(cfg.Samples) - pre
+	copy(buf[nPre*width:], cur)
+	for i := nPre + 1; i < total; i++ {
+		sleepUntil(next)
+		next = next.Add(period)
+		lastTime = time.Now()
+		if err := read(buf[i*width : (i+1)*width]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Estimate the span of all samples from the ones taken after
+	// the trigger, since waiting for it may take any amount of time.
+	var dur time.Duration
+	if post := total - nPre; post > 1 {
+		dur = lastTime.Sub(trigTime) * time.Duration(total-1) / time.Duration(post-1)
+	}
+
+	s.capture = buf[:total*width]
+	info := captureInfo{
+		Samples:  uint16(total),
+		Trigger:  uint16(nPre),
+		Width:    uint8(width),
+		Duration: uint32(dur.Microseconds()),
+	}
+	return &Response{Data: tlv.Marshal(&info)}, nil
+}
+
+// sleepUntil waits for t without spinning, so other goroutines, such as
+// the UART bridge, keep running during a capture.
+func sleepUntil(t time.Time) {
+	if d := time.Until(t); d > 0 {
+		time.Sleep(d)
+	}
+}
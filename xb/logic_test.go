@@ -0,0 +1,114 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/mastercactapus/embedded/driver"
+)
+
+// capturePins toggles pin 0 on every read, holds pin 1 high and raises
+// pin 2 after it has been read 10 times.
+type capturePins struct {
+	toggle bool
+	reads  int
+}
+
+func (p *capturePins) PinCount() int { return 3 }
+func (p *capturePins) Pin(n int) driver.Pin {
+	return &driver.PinFN{
+		N:            n,
+		SetInputFunc: func(int, bool) error { return nil },
+		GetFunc: func(n int) (bool, error) {
+			switch n {
+			case 0:
+				p.toggle = !p.toggle
+				return p.toggle, nil
+			case 1:
+				return true, nil
+			}
+			p.reads++
+			return p.reads > 10, nil
+		},
+	}
+}
+
+func TestClient_Capture(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go NewServer(reqR, respW, &capturePins{}).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.Capture(CaptureConfig{
+		Mask:       0b11,
+		Rate:       100e3,
+		Samples:    1000,
+		PreTrigger: 4,
+		Trigger:    TriggerRising,
+		TriggerPin: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Len() != 1000 {
+		t.Fatalf("got %d samples; want 1000", res.Len())
+	}
+	if res.Trigger != 4 {
+		t.Errorf("got trigger at %d; want 4", res.Trigger)
+	}
+	if len(res.Channels) != 2 || res.Channels[1] != "D1" {
+		t.Errorf("got channels %v; want [D0 D1]", res.Channels)
+	}
+	for i := 0; i < res.Len(); i++ {
+		if !res.Bit(i, 1) {
+			t.Fatalf("sample %d: D1 low", i)
+		}
+		if i > 0 && res.Bit(i, 0) == res.Bit(i-1, 0) {
+			t.Fatalf("sample %d: D0 did not toggle", i)
+		}
+	}
+
+	_, err = c.Capture(CaptureConfig{Mask: 1, Rate: 1000, Samples: 10, Trigger: TriggerFalling, TriggerPin: 1, Timeout: 10})
+	if !errors.Is(err, ErrTriggerTimeout) {
+		t.Errorf("got %v; want ErrTriggerTimeout", err)
+	}
+
+	for _, cfg := range []CaptureConfig{
+		{Mask: 1, Rate: 1000, Samples: 10, Trigger: TriggerHigh, TriggerPin: 1, Timeout: 60000},
+		{Mask: 1, Rate: 1, Samples: 100},
+	} {
+		_, err = c.Capture(cfg)
+		if !errors.Is(err, ErrBadRequest) {
+			t.Errorf("%+v: got %v; want ErrBadRequest", cfg, err)
+		}
+	}
+}
+
+func TestServer_ReadCapture(t *testing.T) {
+	s := &Server{capture: make([]byte, 16)}
+
+	resp, err := s.readCapture(8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 8 {
+		t.Errorf("got %d bytes; want 8", len(resp.Data))
+	}
+
+	for _, off := range []uint32{9, math.MaxUint32 - 4, math.MaxUint32} {
+		_, err = s.readCapture(off, 8)
+		if !errors.Is(err, ErrBadRequest) {
+			t.Errorf("offset %d: got %v; want ErrBadRequest", off, err)
+		}
+	}
+}
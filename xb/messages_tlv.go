@@ -0,0 +1,275 @@
+// Code generated by tlvgen. DO NOT EDIT.
+
+package xb
+
+import "github.com/mastercactapus/embedded/xb/tlv"
+
+func (m *Request) MarshalTLV(e *tlv.Encoder) {
+	e.Uint16('i', m.ID)
+	e.Uint8('c', m.Cmd)
+	e.Uint8('p', m.Pin)
+	e.Bool('s', m.State)
+	e.Uint16('a', m.I2CAddr)
+	e.Uint8('b', m.DataByte)
+	e.Uint16('n', m.ReadN)
+	e.Data('d', m.Data)
+	e.Uint32('o', m.Offset)
+	e.Uint32('f', m.Freq)
+	e.Uint16('u', m.Duty)
+	if m.I2CConfig != nil {
+		e.Message('I', m.I2CConfig)
+	}
+	if m.SPIConfig != nil {
+		e.Message('S', m.SPIConfig)
+	}
+	if m.CaptureConfig != nil {
+		e.Message('C', m.CaptureConfig)
+	}
+	if m.UARTConfig != nil {
+		e.Message('U', m.UARTConfig)
+	}
+	e.Data('X', m.Ext)
+}
+
+func (m *Request) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = Request{}
+	for d.Next() {
+		switch d.Tag() {
+		case 'i':
+			m.ID = d.Uint16()
+		case 'c':
+			m.Cmd = d.Uint8()
+		case 'p':
+			m.Pin = d.Uint8()
+		case 's':
+			m.State = d.Bool()
+		case 'a':
+			m.I2CAddr = d.Uint16()
+		case 'b':
+			m.DataByte = d.Uint8()
+		case 'n':
+			m.ReadN = d.Uint16()
+		case 'd':
+			m.Data = d.Data()
+		case 'o':
+			m.Offset = d.Uint32()
+		case 'f':
+			m.Freq = d.Uint32()
+		case 'u':
+			m.Duty = d.Uint16()
+		case 'I':
+			m.I2CConfig = new(I2CConfig)
+			d.Message(m.I2CConfig)
+		case 'S':
+			m.SPIConfig = new(SPIConfig)
+			d.Message(m.SPIConfig)
+		case 'C':
+			m.CaptureConfig = new(CaptureConfig)
+			d.Message(m.CaptureConfig)
+		case 'U':
+			m.UARTConfig = new(UARTConfig)
+			d.Message(m.UARTConfig)
+		case 'X':
+			m.Ext = d.Data()
+		}
+	}
+	return d.Err()
+}
+
+func (m *Response) MarshalTLV(e *tlv.Encoder) {
+	e.Uint16('i', m.ID)
+	e.String('e', m.Err)
+	e.Uint8('x', uint8(m.Code))
+	e.Bool('s', m.State)
+	e.Uint8('p', m.PinCount)
+	e.Uint8('b', m.DataByte)
+	e.Data('d', m.Data)
+	e.Uint16('v', m.Value)
+	e.Uint16('r', m.RefMV)
+	e.Data('X', m.Ext)
+}
+
+func (m *Response) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = Response{}
+	for d.Next() {
+		switch d.Tag() {
+		case 'i':
+			m.ID = d.Uint16()
+		case 'e':
+			m.Err = d.String()
+		case 'x':
+			m.Code = ErrCode(d.Uint8())
+		case 's':
+			m.State = d.Bool()
+		case 'p':
+			m.PinCount = d.Uint8()
+		case 'b':
+			m.DataByte = d.Uint8()
+		case 'd':
+			m.Data = d.Data()
+		case 'v':
+			m.Value = d.Uint16()
+		case 'r':
+			m.RefMV = d.Uint16()
+		case 'X':
+			m.Ext = d.Data()
+		}
+	}
+	return d.Err()
+}
+
+func (m *SPIConfig) MarshalTLV(e *tlv.Encoder) {
+	e.Uint8(1, m.Mode)
+	e.Uint32(2, m.Baud)
+	e.Uint8(3, m.MISO)
+	e.Uint8(4, m.MOSI)
+	e.Uint8(5, m.SCLK)
+}
+
+func (m *SPIConfig) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = SPIConfig{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			m.Mode = d.Uint8()
+		case 2:
+			m.Baud = d.Uint32()
+		case 3:
+			m.MISO = d.Uint8()
+		case 4:
+			m.MOSI = d.Uint8()
+		case 5:
+			m.SCLK = d.Uint8()
+		}
+	}
+	return d.Err()
+}
+
+func (m *I2CConfig) MarshalTLV(e *tlv.Encoder) {
+	e.Uint8(1, m.SDA)
+	e.Uint8(2, m.SCL)
+	e.Uint32(3, m.Baud)
+}
+
+func (m *I2CConfig) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = I2CConfig{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			m.SDA = d.Uint8()
+		case 2:
+			m.SCL = d.Uint8()
+		case 3:
+			m.Baud = d.Uint32()
+		}
+	}
+	return d.Err()
+}
+
+func (m *CaptureConfig) MarshalTLV(e *tlv.Encoder) {
+	e.Uint64(1, m.Mask)
+	e.Uint32(2, m.Rate)
+	e.Uint16(3, m.Samples)
+	e.Uint16(4, m.PreTrigger)
+	e.Uint8(5, uint8(m.Trigger))
+	e.Uint8(6, m.TriggerPin)
+	e.Uint32(7, m.Timeout)
+}
+
+func (m *CaptureConfig) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = CaptureConfig{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			m.Mask = d.Uint64()
+		case 2:
+			m.Rate = d.Uint32()
+		case 3:
+			m.Samples = d.Uint16()
+		case 4:
+			m.PreTrigger = d.Uint16()
+		case 5:
+			m.Trigger = Trigger(d.Uint8())
+		case 6:
+			m.TriggerPin = d.Uint8()
+		case 7:
+			m.Timeout = d.Uint32()
+		}
+	}
+	return d.Err()
+}
+
+func (m *UARTConfig) MarshalTLV(e *tlv.Encoder) {
+	e.Uint8(1, m.TX)
+	e.Uint8(2, m.RX)
+	e.Uint32(3, m.Baud)
+	e.Uint8(4, uint8(m.Parity))
+	e.Uint8(5, m.DataBits)
+	e.Uint8(6, m.StopBits)
+}
+
+func (m *UARTConfig) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = UARTConfig{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			m.TX = d.Uint8()
+		case 2:
+			m.RX = d.Uint8()
+		case 3:
+			m.Baud = d.Uint32()
+		case 4:
+			m.Parity = Parity(d.Uint8())
+		case 5:
+			m.DataBits = d.Uint8()
+		case 6:
+			m.StopBits = d.Uint8()
+		}
+	}
+	return d.Err()
+}
+
+func (m *Event) MarshalTLV(e *tlv.Encoder) {
+	e.Uint8('p', m.Pin)
+	e.Bool('s', m.State)
+	e.Uint32('t', m.Time)
+}
+
+func (m *Event) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = Event{}
+	for d.Next() {
+		switch d.Tag() {
+		case 'p':
+			m.Pin = d.Uint8()
+		case 's':
+			m.State = d.Bool()
+		case 't':
+			m.Time = d.Uint32()
+		}
+	}
+	return d.Err()
+}
+
+func (m *captureInfo) MarshalTLV(e *tlv.Encoder) {
+	e.Uint16(1, m.Samples)
+	e.Uint16(2, m.Trigger)
+	e.Uint8(3, m.Width)
+	e.Uint32(4, m.Duration)
+}
+
+func (m *captureInfo) UnmarshalTLV(d *tlv.Decoder) error {
+	*m = captureInfo{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			m.Samples = d.Uint16()
+		case 2:
+			m.Trigger = d.Uint16()
+		case 3:
+			m.Width = d.Uint8()
+		case 4:
+			m.Duration = d.Uint32()
+		}
+	}
+	return d.Err()
+}
@@ -0,0 +1,395 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+// Proxy shares a single device connection between multiple clients.
+//
+// Request IDs are rewritten so that clients can't collide with each other,
+// responses are routed back to the client that made the request, UART
+// data to the client that set up the UART and pin events to the clients
+// watching for them. Remote log and error chunks are sent to every client.
+//
+// The device only remembers its last few responses, which isn't enough
+// to recognize a retransmitted request once several clients share it, so
+// the Proxy answers retransmits it already has a response for itself.
+//
+// A client's reset is answered by the Proxy rather than the device, so
+// that it only releases the pin watches and UART held by that client.
+type Proxy struct {
+	r io.Reader
+	w io.Writer
+
+	wMx sync.Mutex
+
+	mx     sync.Mutex
+	nextID uint16
+	routes map[uint16]proxyRoute
+	ids    map[proxyRoute]uint16
+	resps  map[uint16][]byte
+	order  []uint16
+	conns  map[*proxyConn]struct{}
+
+	// pinCount and pinModes are learned from the first reset the device
+	// answers.
+	pinCount uint8
+	pinModes []byte
+
+	// uart is the client that last set up the UART bridge.
+	uart *proxyConn
+
+	// Framing selects how chunks are delimited with the device.
+	Framing Framing
+
+	// ConnFraming selects how chunks are delimited with clients.
+	ConnFraming Framing
+
+	cw *ChunkWriter
+}
+
+// proxyRoutes is the number of ID mappings, and their responses,
+// remembered so that a retransmitted request is not run twice.
+const proxyRoutes = 256
+
+type proxyRoute struct {
+	conn *proxyConn
+	id   uint16
+}
+
+type proxyConn struct {
+	out chan proxyChunk
+
+	// watch holds the edges the client has armed, by pin.
+	watch map[uint8]Edge
+}
+
+type proxyChunk struct {
+	typeCode byte
+	data     []byte
+}
+
+// NewProxy returns a new Proxy for the device connected to r and w.
+func NewProxy(r io.Reader, w io.Writer) *Proxy {
+	return &Proxy{
+		r:      r,
+		w:      w,
+		routes: make(map[uint16]proxyRoute),
+		ids:    make(map[proxyRoute]uint16),
+		resps:  make(map[uint16][]byte),
+		conns:  make(map[*proxyConn]struct{}),
+	}
+}
+
+// Run reads chunks from the device and routes them to clients until
+// reading fails.
+func (p *Proxy) Run() error {
+	r := NewChunkReader(p.r, p.Framing)
+	for {
+		typeCode, data, err := r.Next()
+		if errors.Is(err, ErrHeaderCRC) || errors.Is(err, ErrDataCRC) || errors.Is(err, ErrChunkLen) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch typeCode {
+		case 'R':
+		case 'U':
+			p.sendUART(proxyChunk{typeCode: typeCode, data: append([]byte(nil), data...)})
+			continue
+		case 'V':
+			p.sendEvent(data)
+			continue
+		default:
+			p.broadcast(proxyChunk{typeCode: typeCode, data: append([]byte(nil), data...)})
+			continue
+		}
+
+		var resp Response
+		if err := resp.decode(data); err != nil {
+			// clients will retransmit
+			continue
+		}
+		p.mx.Lock()
+		id := resp.ID
+		rt, ok := p.routes[id]
+		if ok && resp.PinCount != 0 {
+			// only reset responses carry the pin count
+			p.pinCount = resp.PinCount
+			p.pinModes = append([]byte(nil), resp.Data...)
+		}
+		if ok {
+			resp.ID = rt.id
+			data = resp.encode()
+			p.resps[id] = data
+		}
+		p.mx.Unlock()
+		if !ok {
+			continue
+		}
+
+		rt.conn.send(proxyChunk{typeCode: 'R', data: data})
+	}
+}
+
+func (p *Proxy) broadcast(c proxyChunk) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for conn := range p.conns {
+		conn.send(c)
+	}
+}
+
+func (p *Proxy) sendUART(c proxyChunk) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.uart != nil {
+		p.uart.send(c)
+	}
+}
+
+// sendEvent sends a pin event to the clients watching for its edge.
+func (p *Proxy) sendEvent(data []byte) {
+	var ev Event
+	if err := tlv.Unmarshal(data, &ev); err != nil {
+		return
+	}
+	edge := EdgeFalling
+	if ev.State {
+		edge = EdgeRising
+	}
+
+	c := proxyChunk{typeCode: 'V', data: append([]byte(nil), data...)}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	for conn := range p.conns {
+		if conn.watch[ev.Pin]&edge != 0 {
+			conn.send(c)
+		}
+	}
+}
+
+// send queues a chunk for the client, dropping it if the client
+// isn't keeping up. Lost responses will be retransmitted by the client.
+func (c *proxyConn) send(chunk proxyChunk) {
+	select {
+	case c.out <- chunk:
+	default:
+	}
+}
+
+// upstreamID returns the device-side ID for a client request, and the
+// response to it if the device has already answered.
+func (p *Proxy) upstreamID(rt proxyRoute) (uint16, []byte) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if id, ok := p.ids[rt]; ok {
+		return id, p.resps[id]
+	}
+
+	p.nextID++
+	for p.nextID == 0 {
+		p.nextID++
+	}
+	id := p.nextID
+
+	if len(p.order) == proxyRoutes {
+		old := p.order[0]
+		p.order = p.order[1:]
+		delete(p.ids, p.routes[old])
+		delete(p.routes, old)
+		delete(p.resps, old)
+	}
+	if old, ok := p.routes[id]; ok {
+		delete(p.ids, old)
+	}
+	delete(p.resps, id)
+	p.routes[id] = rt
+	p.ids[rt] = id
+	p.order = append(p.order, id)
+
+	return id, nil
+}
+
+// ServeConn forwards requests from a single client until reading from
+// rw fails.
+func (p *Proxy) ServeConn(rw io.ReadWriter) error {
+	conn := &proxyConn{out: make(chan proxyChunk, 32), watch: make(map[uint8]Edge)}
+	p.mx.Lock()
+	p.conns[conn] = struct{}{}
+	p.mx.Unlock()
+
+	done := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		w := NewChunkWriter(rw, p.ConnFraming)
+		for {
+			select {
+			case c := <-conn.out:
+				if err := w.WriteChunk(c.typeCode, c.data); err != nil {
+					writeErr <- err
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := p.readConn(conn, NewChunkReader(rw, p.ConnFraming), writeErr)
+	close(done)
+
+	p.mx.Lock()
+	delete(p.conns, conn)
+	p.mx.Unlock()
+
+	if relErr := p.release(conn); err == nil {
+		err = relErr
+	}
+
+	return err
+}
+
+// track records the device state a client request claims, so that it
+// can be released when the client resets or disconnects.
+func (p *Proxy) track(conn *proxyConn, req *Request) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	switch req.Cmd {
+	case pinWatch:
+		edge := Edge(req.DataByte)
+		if edge&^EdgeBoth != 0 {
+			// left for the device to reject
+			break
+		}
+		if edge == EdgeNone {
+			delete(conn.watch, req.Pin)
+		} else {
+			conn.watch[req.Pin] = edge
+		}
+		// the device holds a single watch per pin for all clients
+		req.DataByte = uint8(p.watchEdge(req.Pin))
+	case uartSetup:
+		p.uart = conn
+	case uartClose:
+		if p.uart == conn {
+			p.uart = nil
+		}
+	}
+}
+
+// release forgets the client's request IDs and undoes its pin watches
+// and UART bridge on the device. Watches on pins also armed by another
+// client are narrowed to the edges still wanted.
+func (p *Proxy) release(conn *proxyConn) error {
+	var reqs []*Request
+
+	p.mx.Lock()
+	for id, rt := range p.routes {
+		if rt.conn == conn {
+			delete(p.ids, rt)
+			delete(p.routes, id)
+			delete(p.resps, id)
+		}
+	}
+	for pin := range conn.watch {
+		delete(conn.watch, pin)
+		reqs = append(reqs, &Request{Cmd: pinWatch, Pin: pin, DataByte: uint8(p.watchEdge(pin))})
+	}
+	if p.uart == conn {
+		p.uart = nil
+		reqs = append(reqs, &Request{Cmd: uartClose})
+	}
+	p.mx.Unlock()
+
+	// ID 0 responses are not routed to any client
+	for _, req := range reqs {
+		if err := p.writeReq(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchEdge returns the edges any client is watching on a pin. It must
+// be called with mx held.
+func (p *Proxy) watchEdge(pin uint8) Edge {
+	edge := EdgeNone
+	for conn := range p.conns {
+		edge |= conn.watch[pin]
+	}
+	return edge
+}
+
+func (p *Proxy) writeReq(req *Request) error {
+	p.wMx.Lock()
+	defer p.wMx.Unlock()
+	if p.cw == nil {
+		p.cw = NewChunkWriter(p.w, p.Framing)
+	}
+	return p.cw.WriteChunk('Q', req.encode())
+}
+
+func (p *Proxy) readConn(conn *proxyConn, r *ChunkReader, writeErr chan error) error {
+	for {
+		select {
+		case err := <-writeErr:
+			return err
+		default:
+		}
+
+		typeCode, data, err := r.Next()
+		if errors.Is(err, ErrHeaderCRC) || errors.Is(err, ErrDataCRC) || errors.Is(err, ErrChunkLen) {
+			conn.send(proxyChunk{typeCode: 'E', data: []byte(err.Error())})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if typeCode != 'Q' {
+			conn.send(proxyChunk{typeCode: 'E', data: []byte("unknown type code")})
+			continue
+		}
+
+		var req Request
+		if err := req.decode(data); err != nil {
+			conn.send(proxyChunk{typeCode: 'E', data: []byte(err.Error())})
+			continue
+		}
+
+		if req.Cmd == reset {
+			// resetting the device would clear every client's state
+			p.mx.Lock()
+			n, modes := p.pinCount, p.pinModes
+			p.mx.Unlock()
+			if n != 0 {
+				if err := p.release(conn); err != nil {
+					return err
+				}
+				resp := &Response{ID: req.ID, PinCount: n, Data: modes}
+				conn.send(proxyChunk{typeCode: 'R', data: resp.encode()})
+				continue
+			}
+		}
+
+		id, resp := p.upstreamID(proxyRoute{conn: conn, id: req.ID})
+		if resp != nil {
+			conn.send(proxyChunk{typeCode: 'R', data: resp})
+			continue
+		}
+		p.track(conn, &req)
+
+		req.ID = id
+		if err := p.writeReq(&req); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package xb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+type pipeConn struct {
+	io.Reader
+	io.Writer
+}
+
+// newTestProxy starts a Server and a Proxy in front of it, returning a
+// func that connects a new client to the proxy.
+func newTestProxy(t *testing.T) (*testPins, *Server, func() (io.Reader, io.WriteCloser)) {
+	t.Helper()
+
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	pins := &testPins{state: make([]bool, 8)}
+	srv := NewServer(reqR, respW, pins)
+	go srv.Serve()
+
+	p := NewProxy(respR, reqW)
+	go p.Run()
+
+	var closers []io.Closer
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c.Close()
+		}
+		reqW.Close()
+		respW.Close()
+	})
+	dial := func() (io.Reader, io.WriteCloser) {
+		cr, sw := io.Pipe()
+		sr, cw := io.Pipe()
+		closers = append(closers, cw, sw)
+		go p.ServeConn(pipeConn{Reader: sr, Writer: sw})
+		return cr, cw
+	}
+
+	return pins, srv, dial
+}
+
+func TestProxy(t *testing.T) {
+	_, srv, dial := newTestProxy(t)
+
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		r, w := dial()
+		c, err := NewClientConfig(r, w, ClientConfig{Timeout: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			p := c.Pin(n)
+			for i := 0; i < 20; i++ {
+				want := i%2 == 0
+				if err := p.Set(want); err != nil {
+					t.Error(err)
+					return
+				}
+				got, err := p.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got != want {
+					t.Errorf("pin %d: got %v; want %v", n, got, want)
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	// logs go to everyone
+	r1, _ := dial()
+	r2, _ := dial()
+	time.Sleep(10 * time.Millisecond)
+	srv.logf("hello %d", 42)
+	for _, r := range []io.Reader{r1, r2} {
+		typeCode, data, err := ReadChunk(bufio.NewReader(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typeCode != 'L' || string(data) != "hello 42" {
+			t.Errorf("got %q %q; want log chunk", typeCode, data)
+		}
+	}
+}
+
+func TestProxy_Reset(t *testing.T) {
+	pins, srv, dial := newTestProxy(t)
+
+	set := func(n int, v bool) {
+		pins.mx.Lock()
+		pins.state[n] = v
+		pins.mx.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	r, w := dial()
+	a, err := NewClientConfig(r, w, ClientConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Watch(2, EdgeBoth); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second client must not disarm the first client's watch
+	r, bw := dial()
+	b, err := NewClientConfig(r, bw, ClientConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.PinCount() != 8 {
+		t.Errorf("PinCount = %d; want 8", b.PinCount())
+	}
+
+	set(2, true)
+	select {
+	case ev := <-a.Events():
+		if ev.Pin != 2 || !ev.State {
+			t.Errorf("got pin %d=%v; want pin 2=true", ev.Pin, ev.State)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	select {
+	case ev := <-b.Events():
+		t.Errorf("unexpected event for unwatched pin: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// disconnecting releases the watch
+	w.Close()
+	time.Sleep(20 * time.Millisecond)
+	srv.mx.Lock()
+	edge := srv.watch[2]
+	srv.mx.Unlock()
+	if edge != EdgeNone {
+		t.Errorf("pin 2 watch = %d after disconnect; want EdgeNone", edge)
+	}
+}
+
+func TestProxy_WatchUnion(t *testing.T) {
+	pins, _, dial := newTestProxy(t)
+
+	set := func(n int, v bool) {
+		pins.mx.Lock()
+		pins.state[n] = v
+		pins.mx.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+	newClient := func() *Client {
+		r, w := dial()
+		c, err := NewClientConfig(r, w, ClientConfig{Timeout: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	a, b := newClient(), newClient()
+	if err := a.Watch(3, EdgeRising); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Watch(3, EdgeFalling); err != nil {
+		t.Fatal(err)
+	}
+
+	// both edges stay armed on the device
+	set(3, true)
+	select {
+	case ev := <-a.Events():
+		if ev.Pin != 3 || !ev.State {
+			t.Errorf("got pin %d=%v; want pin 3=true", ev.Pin, ev.State)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for rising edge")
+	}
+
+	set(3, false)
+	select {
+	case ev := <-b.Events():
+		if ev.Pin != 3 || ev.State {
+			t.Errorf("got pin %d=%v; want pin 3=false", ev.Pin, ev.State)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for falling edge")
+	}
+}
+
+func TestProxy_Retransmit(t *testing.T) {
+	pins, _, dial := newTestProxy(t)
+	r, w := dial()
+	br := bufio.NewReader(r)
+
+	do := func(req *Request) {
+		t.Helper()
+		if err := WriteChunk(w, 'Q', req.encode()); err != nil {
+			t.Fatal(err)
+		}
+		_, data, err := ReadChunk(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp Response
+		if err := resp.decode(data); err != nil {
+			t.Fatal(err)
+		}
+		if resp.ID != req.ID {
+			t.Fatalf("got response %d; want %d", resp.ID, req.ID)
+		}
+	}
+
+	set := &Request{ID: 7, Cmd: setPin, Pin: 1, State: true}
+	do(set)
+	pins.mx.Lock()
+	pins.state[1] = false
+	pins.mx.Unlock()
+
+	// push the request out of the device's response cache
+	for i := 0; i < recentResponses+2; i++ {
+		do(&Request{ID: uint16(100 + i), Cmd: getPin, Pin: 2})
+	}
+
+	do(set)
+	pins.mx.Lock()
+	defer pins.mx.Unlock()
+	if pins.state[1] {
+		t.Error("retransmitted setPin ran twice")
+	}
+}
+
+func TestProxy_Route(t *testing.T) {
+	var dev bytes.Buffer
+	WriteChunk(&dev, 'U', []byte("uart"))
+	for _, ev := range []Event{{Pin: 3, State: true}, {Pin: 3}, {Pin: 4, State: true}} {
+		WriteChunk(&dev, 'V', tlv.Marshal(&ev))
+	}
+	WriteChunk(&dev, 'L', []byte("log"))
+
+	p := NewProxy(&dev, io.Discard)
+	newConn := func(watch map[uint8]Edge) *proxyConn {
+		conn := &proxyConn{out: make(chan proxyChunk, 8), watch: watch}
+		p.conns[conn] = struct{}{}
+		return conn
+	}
+	a := newConn(map[uint8]Edge{3: EdgeRising})
+	b := newConn(map[uint8]Edge{3: EdgeBoth, 4: EdgeFalling})
+	p.uart = b
+
+	if err := p.Run(); err != io.EOF {
+		t.Fatalf("Run: got %v; want EOF", err)
+	}
+
+	got := func(conn *proxyConn) (s []string) {
+		close(conn.out)
+		for c := range conn.out {
+			if c.typeCode == 'V' {
+				var ev Event
+				if err := tlv.Unmarshal(c.data, &ev); err != nil {
+					t.Fatal(err)
+				}
+				s = append(s, fmt.Sprintf("V %d=%v", ev.Pin, ev.State))
+				continue
+			}
+			s = append(s, fmt.Sprintf("%c %s", c.typeCode, c.data))
+		}
+		return s
+	}
+	if s := got(a); !reflect.DeepEqual(s, []string{"V 3=true", "L log"}) {
+		t.Errorf("client a got %q", s)
+	}
+	if s := got(b); !reflect.DeepEqual(s, []string{"U uart", "V 3=true", "V 3=false", "L log"}) {
+		t.Errorf("client b got %q", s)
+	}
+}
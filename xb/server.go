@@ -1,10 +1,11 @@
 package xb
 
 import (
-	"bufio"
 	"errors"
 	"io"
 	"runtime"
+	"sync"
+	"time"
 
 	"github.com/mastercactapus/embedded/driver"
 	"github.com/mastercactapus/embedded/serial/i2c"
@@ -13,14 +14,56 @@ import (
 )
 
 type Server struct {
-	w io.Writer
-	r *bufio.Reader
+	w   io.Writer
+	cw  *ChunkWriter
+	r   *ChunkReader
+	wMx sync.Mutex
+
+	// mx is held while accessing pins or bus state.
+	mx sync.Mutex
 
 	dev  driver.Pinner
 	pins []driver.Pin
 
-	spi *spi.SoftCtrl
-	i2c *i2c.I2C
+	spi    *spi.SoftCtrl
+	i2c    i2c.Bus
+	i2cCfg I2CConfig
+
+	// recent holds the last few responses so that a retransmitted
+	// request is answered without being executed a second time.
+	recent    [recentResponses]sentResponse
+	recentIdx int
+
+	start    time.Time
+	watch    []Edge
+	watching bool
+	last     []bool
+	done     chan struct{}
+	served   sync.Once
+
+	capture []byte
+
+	uart     io.ReadWriter
+	uartDone chan struct{}
+
+	handlers map[uint8]HandlerFunc
+
+	// PollInterval is how often watched pins are checked for changes.
+	PollInterval time.Duration
+
+	// Framing selects how chunks are delimited; it must be set
+	// before calling Serve.
+	Framing Framing
+}
+
+// recentResponses is the number of responses the server remembers. A
+// client with more requests in flight could have a retransmit executed
+// a second time.
+const recentResponses = 8
+
+type sentResponse struct {
+	id   uint16
+	data []byte
 }
 
 func NewServer(r io.Reader, w io.Writer, dev driver.Pinner) *Server {
@@ -29,142 +72,331 @@ func NewServer(r io.Reader, w io.Writer, dev driver.Pinner) *Server {
 		pins[i] = dev.Pin(i)
 	}
 	return &Server{
-		dev:  dev,
-		pins: pins,
-		w:    w,
-		r:    bufio.NewReader(r),
+		dev:   dev,
+		pins:  pins,
+		w:     w,
+		r:     NewChunkReader(r, FramingRaw),
+		start: time.Now(),
+		watch: make([]Edge, len(pins)),
+		last:  make([]bool, len(pins)),
+		done:  make(chan struct{}),
+
+		PollInterval: time.Millisecond,
 	}
 }
 
+func (s *Server) writeChunk(typeCode byte, data []byte) error {
+	s.wMx.Lock()
+	defer s.wMx.Unlock()
+	if s.cw == nil {
+		s.cw = NewChunkWriter(s.w, s.Framing)
+	}
+	return s.cw.WriteChunk(typeCode, data)
+}
+
 func (s *Server) logf(format string, args ...interface{}) {
-	WriteChunk(s.w, 'L', []byte(ascii.Sprintf(format, args...)))
+	s.writeChunk('L', []byte(ascii.Sprintf(format, args...)))
+}
+
+func (s *Server) writeResp(resp *Response) error {
+	data := resp.encode()
+	if resp.ID != 0 {
+		s.recent[s.recentIdx] = sentResponse{id: resp.ID, data: data}
+		s.recentIdx = (s.recentIdx + 1) % len(s.recent)
+	}
+	return s.writeChunk('R', data)
 }
-func (s *Server) writeResp(resp *Response) error { return WriteChunk(s.w, 'R', resp.encode()) }
 
+// resend will re-write a previously sent response for id, if there is one.
+func (s *Server) resend(id uint16) bool {
+	if id == 0 {
+		return false
+	}
+	for _, r := range s.recent {
+		if r.id != id {
+			continue
+		}
+		s.writeChunk('R', r.data)
+		return true
+	}
+	return false
+}
+
+var errServed = errors.New("xb: Serve called more than once")
+
+// Serve handles requests until reading fails. Pin watches and the UART
+// bridge stop when it returns, so it may only be called once.
 func (s *Server) Serve() error {
+	first := false
+	s.served.Do(func() { first = true })
+	if !first {
+		return errServed
+	}
+	defer close(s.done)
+	s.r.framing = s.Framing
 	for {
 		runtime.GC()
-		typeCode, data, err := ReadChunk(s.r)
-		if err != nil {
-			WriteChunk(s.w, 'E', []byte(err.Error()))
+		typeCode, data, err := s.r.Next()
+		if errors.Is(err, ErrHeaderCRC) || errors.Is(err, ErrDataCRC) || errors.Is(err, ErrChunkLen) {
+			s.writeChunk('E', []byte(err.Error()))
 			continue
 		}
+		if err != nil {
+			return err
+		}
 		var req Request
 		switch typeCode {
 		case 'Q':
-			req.decode(data)
+			if err := req.decode(data); err != nil {
+				s.writeResp(&Response{ID: req.ID, Err: err.Error(), Code: CodeBadRequest})
+				continue
+			}
 		default:
-			WriteChunk(s.w, 'E', []byte("unknown type code"))
+			s.writeChunk('E', []byte("unknown type code"))
 			continue
 		}
 
+		if req.Cmd == reset {
+			// a new client may reuse old IDs
+			s.recent = [len(s.recent)]sentResponse{}
+		} else if s.resend(req.ID) {
+			continue
+		}
+
+		s.mx.Lock()
 		resp, err := s.handle(req)
+		s.mx.Unlock()
 		if err != nil {
-			// ignore err
-			s.writeResp(&Response{Err: err.Error()})
-			continue
+			resp = &Response{Err: err.Error(), Code: errCode(err)}
 		}
 		if resp == nil {
 			resp = &Response{}
 		}
+		resp.ID = req.ID
 
 		s.writeResp(resp)
 	}
 }
 
+// maxReadN is the largest read a single request may ask for.
+const maxReadN = 512
+
+func (s *Server) pin(n uint8) (driver.Pin, error) {
+	if int(n) >= len(s.pins) {
+		return nil, ErrBadPin
+	}
+	return s.pins[n], nil
+}
+
+// pinModes returns the driver.Mode of each pin, one byte per pin. Pins
+// that don't report their modes are assumed to be push-pull or high-Z.
+func (s *Server) pinModes() []byte {
+	modes := make([]byte, len(s.pins))
+	for n := range modes {
+		m := driver.PinModes(s.dev, n)
+		if m == 0 {
+			m = driver.ModePushPull | driver.ModeHighZ
+		}
+		modes[n] = byte(m)
+	}
+	return modes
+}
+
 func (s *Server) handle(req Request) (*Response, error) {
-	for {
-		switch req.Cmd {
-		case reset:
-			return &Response{PinCount: uint8(len(s.pins))}, nil
-		case hello:
-			return nil, nil
-		case setInput:
-			return nil, s.pins[req.Pin].SetInput(req.State)
-		case setPin:
-			return nil, s.pins[req.Pin].Set(req.State)
-		case getPin:
-			state, err := s.pins[req.Pin].Get()
-			return &Response{State: state}, err
-		case i2cSetup:
-			s.i2c = nil
-			if req.I2CConfig == nil {
-				return nil, errors.New("i2cSetup: missing I2CConfig")
-			}
-			s.i2c = i2c.New(i2c.NewSoftController(s.pins[req.I2CConfig.SDA], s.pins[req.I2CConfig.SCL]))
-			return nil, nil
-		case i2cTx:
-			if s.i2c == nil {
-				return nil, errors.New("i2cTx: i2c not initialized")
-			}
-			buf := make([]byte, req.ReadN)
-			if err := s.i2c.Tx(req.I2CAddr, []byte(req.Data), buf); err != nil {
-				return nil, err
-			}
-			return &Response{Data: buf}, nil
-		case spiSetup:
-			s.spi = nil
-			if req.SPIConfig == nil {
-				return nil, errors.New("spiSetup: missing SPIConfig")
-			}
-			cfg := &spi.Config{
-				Mode: spi.Mode(req.SPIConfig.Mode),
-				MOSI: s.dev.Pin(int(req.SPIConfig.MOSI)),
-				MISO: s.dev.Pin(int(req.SPIConfig.MISO)),
-				SCLK: s.dev.Pin(int(req.SPIConfig.SCLK)),
-				Baud: int(req.SPIConfig.Baud),
-			}
-			spi, err := spi.NewSoftCtrl(cfg)
-			if err != nil {
-				return nil, err
-			}
+	if req.ReadN > maxReadN {
+		return nil, ascii.Errorf("read of %d bytes: %w", req.ReadN, ErrBadRequest)
+	}
 
-			s.spi = spi
-			return nil, nil
-		case spiReadWriteByte:
-			if s.spi == nil {
-				return nil, errors.New("spiReadWriteByte: spi not initialized")
+	switch req.Cmd {
+	case ignore, hello:
+		return nil, nil
+	case reset:
+		for i := range s.watch {
+			s.watch[i] = EdgeNone
+		}
+		s.closeUART()
+		return &Response{PinCount: uint8(len(s.pins)), Data: s.pinModes()}, nil
+	case setInput:
+		p, err := s.pin(req.Pin)
+		if err != nil {
+			return nil, err
+		}
+		return nil, p.SetInput(req.State)
+	case setPin:
+		p, err := s.pin(req.Pin)
+		if err != nil {
+			return nil, err
+		}
+		return nil, p.Set(req.State)
+	case getPin:
+		p, err := s.pin(req.Pin)
+		if err != nil {
+			return nil, err
+		}
+		state, err := p.Get()
+		return &Response{State: state}, err
+	case pinBatch:
+		return s.runBatch(req.Data)
+	case pinWatch:
+		return nil, s.setWatch(req.Pin, Edge(req.DataByte))
+	case captureStart:
+		if req.CaptureConfig == nil {
+			return nil, ascii.Errorf("capture: missing CaptureConfig: %w", ErrBadRequest)
+		}
+		return s.runCapture(*req.CaptureConfig)
+	case captureRead:
+		return s.readCapture(req.Offset, req.ReadN)
+	case setPull:
+		return nil, s.setPull(req.Pin, driver.Pull(req.DataByte))
+	case readAnalog:
+		return s.readAnalog(req.Pin)
+	case setPWM:
+		return nil, s.setPWM(req.Pin, req.Freq, req.Duty)
+	case uartSetup:
+		if req.UARTConfig == nil {
+			return nil, ascii.Errorf("uartSetup: missing UARTConfig: %w", ErrBadRequest)
+		}
+		return nil, s.setupUART(*req.UARTConfig)
+	case uartWrite:
+		return nil, s.writeUART(req.Data)
+	case uartClose:
+		s.closeUART()
+		return nil, nil
+	case i2cSetup:
+		s.i2c = nil
+		if req.I2CConfig == nil {
+			return nil, ascii.Errorf("i2cSetup: missing I2CConfig: %w", ErrBadRequest)
+		}
+		return nil, s.setupI2C(*req.I2CConfig)
+	case i2cTx:
+		if s.i2c == nil {
+			return nil, ascii.Errorf("i2cTx: i2c %w", ErrNotInitialized)
+		}
+		buf := make([]byte, req.ReadN)
+		if err := s.i2c.Tx(req.I2CAddr, []byte(req.Data), buf); err != nil {
+			return nil, err
+		}
+		return &Response{Data: buf}, nil
+	case i2cScan:
+		if s.i2c == nil {
+			return nil, ascii.Errorf("i2cScan: i2c %w", ErrNotInitialized)
+		}
+		res, err := i2c.Scan(s.i2c)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: append(res.Read[:], res.Write[:]...)}, nil
+	case i2cRecover:
+		if s.i2c == nil {
+			return nil, ascii.Errorf("i2cRecover: i2c %w", ErrNotInitialized)
+		}
+		s.i2c = nil
+		err := i2c.Recover(s.pins[s.i2cCfg.SDA], s.pins[s.i2cCfg.SCL])
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.setupI2C(s.i2cCfg)
+	case i2cTransfer:
+		if s.i2c == nil {
+			return nil, ascii.Errorf("i2cTransfer: i2c %w", ErrNotInitialized)
+		}
+		msgs, err := decodeI2CMsgs(req.Data)
+		if err != nil {
+			return nil, err
+		}
+		if err := i2c.Transfer(s.i2c, msgs); err != nil {
+			return nil, err
+		}
+		var data []byte
+		for _, m := range msgs {
+			if m.Read {
+				data = append(data, m.Data...)
 			}
+		}
+		return &Response{Data: data}, nil
+	case spiSetup:
+		s.spi = nil
+		if req.SPIConfig == nil {
+			return nil, ascii.Errorf("spiSetup: missing SPIConfig: %w", ErrBadRequest)
+		}
+		mosi, err := s.pin(req.SPIConfig.MOSI)
+		if err != nil {
+			return nil, err
+		}
+		miso, err := s.pin(req.SPIConfig.MISO)
+		if err != nil {
+			return nil, err
+		}
+		sclk, err := s.pin(req.SPIConfig.SCLK)
+		if err != nil {
+			return nil, err
+		}
+		if req.SPIConfig.Mode > 3 {
+			return nil, ascii.Errorf("spiSetup: mode %d: %w", req.SPIConfig.Mode, ErrBadRequest)
+		}
+		cfg := &spi.Config{
+			Mode: spi.Mode(req.SPIConfig.Mode),
+			MOSI: mosi,
+			MISO: miso,
+			SCLK: sclk,
+			Baud: int(req.SPIConfig.Baud),
+		}
+		spi, err := spi.NewSoftCtrl(cfg)
+		if err != nil {
+			return nil, err
+		}
 
-			b, err := s.spi.ReadWriteByte(req.DataByte)
-			if err != nil {
-				return nil, err
-			}
-			return &Response{DataByte: b}, nil
-		case spiRead:
-			if s.spi == nil {
-				return nil, errors.New("spiRead: spi not initialized")
-			}
+		s.spi = spi
+		return nil, nil
+	case spiReadWriteByte:
+		if s.spi == nil {
+			return nil, ascii.Errorf("spiReadWriteByte: spi %w", ErrNotInitialized)
+		}
 
-			buf := make([]byte, req.ReadN)
-			if _, err := s.spi.Read(buf); err != nil {
-				return nil, err
-			}
-			return &Response{Data: buf}, nil
-		case spiWrite:
-			if s.spi == nil {
-				return nil, errors.New("spiWrite: spi not initialized")
-			}
+		b, err := s.spi.ReadWriteByte(req.DataByte)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{DataByte: b}, nil
+	case spiRead:
+		if s.spi == nil {
+			return nil, ascii.Errorf("spiRead: spi %w", ErrNotInitialized)
+		}
 
-			if _, err := s.spi.Write(req.Data); err != nil {
-				return nil, err
-			}
-			return nil, nil
-		case spiSetFill:
-			if s.spi == nil {
-				return nil, errors.New("spiSetFill: spi not initialized")
-			}
+		buf := make([]byte, req.ReadN)
+		if _, err := s.spi.Read(buf); err != nil {
+			return nil, err
+		}
+		return &Response{Data: buf}, nil
+	case spiWrite:
+		if s.spi == nil {
+			return nil, ascii.Errorf("spiWrite: spi %w", ErrNotInitialized)
+		}
 
-			return nil, s.spi.SetFill(req.DataByte)
-		case spiReadWrite:
-			if s.spi == nil {
-				return nil, errors.New("spiReadWrite: spi not initialized")
-			}
+		if _, err := s.spi.Write(req.Data); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case spiSetFill:
+		if s.spi == nil {
+			return nil, ascii.Errorf("spiSetFill: spi %w", ErrNotInitialized)
+		}
 
-			if _, err := s.spi.ReadWrite(req.Data); err != nil {
-				return nil, err
-			}
-			return &Response{Data: req.Data}, nil
+		return nil, s.spi.SetFill(req.DataByte)
+	case spiReadWrite:
+		if s.spi == nil {
+			return nil, ascii.Errorf("spiReadWrite: spi %w", ErrNotInitialized)
+		}
+
+		if _, err := s.spi.ReadWrite(req.Data); err != nil {
+			return nil, err
 		}
+		return &Response{Data: req.Data}, nil
 	}
+
+	if h, ok := s.handlers[req.Cmd]; ok {
+		return h(req)
+	}
+
+	return nil, ascii.Errorf("command %d: %w", req.Cmd, ErrUnsupported)
 }
@@ -0,0 +1,118 @@
+package xb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mastercactapus/embedded/serial/i2c"
+)
+
+func TestServer_Errors(t *testing.T) {
+	c, _ := newTestClient(t, 0, ClientConfig{Timeout: time.Second})
+
+	check := func(name string, err, want error) {
+		t.Helper()
+		if !errors.Is(err, want) {
+			t.Errorf("%s: got %v; want %v", name, err, want)
+		}
+	}
+
+	check("set", c.Pin(8).High(), ErrBadPin)
+	_, err := c.Pin(200).Get()
+	check("get", err, ErrBadPin)
+	_, err = c.tx(&Request{Cmd: 0xff})
+	check("unknown", err, ErrUnsupported)
+	_, err = c.tx(&Request{Cmd: i2cTx})
+	check("i2cTx", err, ErrNotInitialized)
+	_, err = c.tx(&Request{Cmd: spiSetup})
+	check("spiSetup", err, ErrBadRequest)
+	_, err = c.tx(&Request{Cmd: spiSetup, SPIConfig: &SPIConfig{MOSI: 1, MISO: 2, SCLK: 9}})
+	check("spiSetup pins", err, ErrBadPin)
+
+	var b Batch
+	b.Set(2, true)
+	b.Get(12)
+	_, err = c.Do(&b)
+	check("batch", err, ErrBadPin)
+
+	var rErr *RemoteError
+	if !errors.As(err, &rErr) || rErr.Code != CodeBadPin {
+		t.Errorf("batch: got %#v; want RemoteError with CodeBadPin", err)
+	}
+}
+
+func TestServer_I2CNack(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	dev := i2cTestPins{testPins: &testPins{state: make([]bool, 8)}, bus: &fakeBus{}}
+	go NewServer(reqR, respW, dev).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus, err := c.I2C(I2CConfig{SDA: 1, SCL: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bus.Tx(0x10, []byte{1}, nil)
+	if !errors.Is(err, i2c.ErrNack) {
+		t.Errorf("got %v; want i2c.ErrNack", err)
+	}
+}
+
+func TestServer_ServeTwice(t *testing.T) {
+	srv := NewServer(bytes.NewReader(nil), io.Discard, &testPins{state: make([]bool, 8)})
+	if err := srv.Serve(); err != io.EOF {
+		t.Fatalf("got %v; want EOF", err)
+	}
+	if err := srv.Serve(); !errors.Is(err, errServed) {
+		t.Errorf("second Serve: got %v; want errServed", err)
+	}
+}
+
+func FuzzServer(f *testing.F) {
+	seed := func(reqs ...*Request) []byte {
+		var buf bytes.Buffer
+		for _, req := range reqs {
+			WriteChunk(&buf, 'Q', req.encode())
+		}
+		return buf.Bytes()
+	}
+	f.Add(seed(&Request{Cmd: reset}, &Request{Cmd: setPin, Pin: 3, State: true}, &Request{Cmd: getPin, Pin: 3}))
+	f.Add(seed(&Request{Cmd: setPin, Pin: 200}, &Request{Cmd: 0xfe}))
+	f.Add(seed(&Request{Cmd: i2cSetup, I2CConfig: &I2CConfig{SDA: 1, SCL: 2}}, &Request{Cmd: i2cTx, I2CAddr: 0x20, Data: []byte{1}, ReadN: 2}))
+	f.Add(seed(&Request{Cmd: spiSetup, SPIConfig: &SPIConfig{MOSI: 1, MISO: 2, SCLK: 3}}, &Request{Cmd: spiRead, ReadN: 4}))
+	f.Add(seed(&Request{Cmd: pinBatch, Data: []byte{batchSet, 1, 1, batchGet, 1, 0, batchGet, 99, 0}}))
+	f.Add(seed(&Request{Cmd: i2cTransfer, Data: []byte{0, 0, 0x20, 0xff, 0xff}}))
+	f.Add([]byte{0xfe, 0xfe, 'Q', 0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var out bytes.Buffer
+		srv := NewServer(bytes.NewReader(data), &out, &testPins{state: make([]bool, 8)})
+		err := srv.Serve()
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// every response must decode
+		r := bufio.NewReader(&out)
+		for {
+			_, _, err := ReadChunk(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("bad output chunk: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package xb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mastercactapus/embedded/xb/tlv"
+)
+
+// TraceEntry is a single line of a trace written by a Tap.
+type TraceEntry struct {
+	// Time is when the request was sent, or when the chunk was
+	// received for entries without a request.
+	Time time.Time `json:"time"`
+
+	// Elapsed is the time between sending the request and
+	// receiving its response.
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+
+	Request  *Request  `json:"req,omitempty"`
+	Response *Response `json:"resp,omitempty"`
+	Event    *Event    `json:"event,omitempty"`
+
+	// Type and Data hold any other chunk, such as remote logs
+	// and UART data.
+	Type string `json:"type,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// Tap sits between a Client and a device, passing data through
+// unmodified while logging each request and response pair to a
+// trace as JSON lines.
+//
+//	tap := xb.NewTap(port, port, traceFile)
+//	c, err := xb.NewClient(tap, tap)
+type Tap struct {
+	r io.Reader
+	w io.Writer
+
+	// Framing selects how chunks are delimited; it must be set
+	// before the first Read or Write.
+	Framing Framing
+
+	once   sync.Once
+	tx, rx *io.PipeWriter
+	wg     sync.WaitGroup
+
+	mx      sync.Mutex
+	enc     *json.Encoder
+	pending map[uint16]TraceEntry
+
+	// early holds responses decoded before their request, since
+	// each direction is decoded independently.
+	early map[uint16]TraceEntry
+
+	// completed holds the IDs of the last few recorded responses, so
+	// that duplicates from retransmissions are ignored.
+	completed []uint16
+}
+
+// NewTap returns a Tap that reads from r, writes to w and logs the
+// decoded session to trace.
+func NewTap(r io.Reader, w io.Writer, trace io.Writer) *Tap {
+	return &Tap{
+		r:       r,
+		w:       w,
+		enc:     json.NewEncoder(trace),
+		pending: make(map[uint16]TraceEntry),
+		early:   make(map[uint16]TraceEntry),
+	}
+}
+
+func (t *Tap) start() {
+	t.once.Do(func() {
+		var txR, rxR *io.PipeReader
+		txR, t.tx = io.Pipe()
+		rxR, t.rx = io.Pipe()
+		t.wg.Add(2)
+		go t.decode(txR)
+		go t.decode(rxR)
+	})
+}
+
+// Read reads from the device.
+func (t *Tap) Read(p []byte) (int, error) {
+	t.start()
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.rx.Write(p[:n])
+	}
+	return n, err
+}
+
+// Write writes to the device.
+func (t *Tap) Write(p []byte) (int, error) {
+	t.start()
+	t.tx.Write(p)
+	return t.w.Write(p)
+}
+
+// Close stops logging, after writing any chunks that have been
+// fully read or written. It does not close the underlying reader
+// or writer.
+func (t *Tap) Close() error {
+	t.start()
+	t.tx.Close()
+	t.rx.Close()
+	t.wg.Wait()
+	return nil
+}
+
+func (t *Tap) decode(r io.Reader) {
+	defer t.wg.Done()
+	cr := NewChunkReader(r, t.Framing)
+	for {
+		typeCode, data, err := cr.Next()
+		if errors.Is(err, ErrHeaderCRC) || errors.Is(err, ErrDataCRC) || errors.Is(err, ErrChunkLen) {
+			continue
+		}
+		if err != nil {
+			// drain so writes to the pipe never block
+			io.Copy(io.Discard, r)
+			return
+		}
+
+		t.record(typeCode, data)
+	}
+}
+
+func (t *Tap) record(typeCode byte, data []byte) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	// decoded entries alias data, which is reused by the ChunkReader
+	data = append([]byte(nil), data...)
+
+	now := time.Now()
+	switch typeCode {
+	case 'Q':
+		req := new(Request)
+		if err := req.decode(data); err != nil {
+			return
+		}
+		if req.Cmd == reset {
+			// a new client may reuse old IDs
+			for id := range t.pending {
+				delete(t.pending, id)
+			}
+			for id := range t.early {
+				if id != req.ID {
+					delete(t.early, id)
+				}
+			}
+			t.completed = t.completed[:0]
+		}
+		if _, ok := t.pending[req.ID]; ok || t.isCompleted(req.ID) {
+			// retransmission
+			return
+		}
+		if e, ok := t.early[req.ID]; ok {
+			delete(t.early, req.ID)
+			t.complete(req.ID)
+			e.Request = req
+			t.enc.Encode(e)
+			return
+		}
+		t.pending[req.ID] = TraceEntry{Time: now, Request: req}
+	case 'R':
+		resp := new(Response)
+		if err := resp.decode(data); err != nil {
+			return
+		}
+		if t.isCompleted(resp.ID) {
+			// duplicate response to a retransmission
+			return
+		}
+		e, ok := t.pending[resp.ID]
+		if !ok {
+			// the request hasn't been decoded yet
+			t.early[resp.ID] = TraceEntry{Time: now, Response: resp}
+			return
+		}
+		delete(t.pending, resp.ID)
+		t.complete(resp.ID)
+		e.Elapsed = now.Sub(e.Time)
+		e.Response = resp
+		t.enc.Encode(e)
+	case 'V':
+		ev := new(Event)
+		if err := tlv.Unmarshal(data, ev); err != nil {
+			return
+		}
+		t.enc.Encode(TraceEntry{Time: now, Event: ev})
+	default:
+		t.enc.Encode(TraceEntry{Time: now, Type: string(typeCode), Data: data})
+	}
+}
+
+func (t *Tap) isCompleted(id uint16) bool {
+	for _, c := range t.completed {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records that the response to id has been logged. IDs are
+// forgotten after as many responses as the server remembers, after
+// which the same ID is a new request.
+func (t *Tap) complete(id uint16) {
+	if len(t.completed) == recentResponses {
+		t.completed = append(t.completed[:0], t.completed[1:]...)
+	}
+	t.completed = append(t.completed, id)
+}
+
+// ErrNoReplay is returned by a ReplayServer when a request doesn't
+// match the trace.
+var ErrNoReplay = errors.New("xb: no recorded response")
+
+// ReplayServer answers a Client using the responses recorded
+// by a Tap.
+//
+// Requests are matched in the order they were recorded, ignoring
+// request IDs, so a client repeating the same session gets the same
+// responses.
+type ReplayServer struct {
+	r io.Reader
+	w io.Writer
+
+	// Framing selects how chunks are delimited; it must be set
+	// before calling Serve.
+	Framing Framing
+
+	entries []TraceEntry
+	next    int
+	recent  map[uint16][]byte
+}
+
+// NewReplayServer reads a trace and returns a ReplayServer that reads
+// requests from r and writes responses to w.
+func NewReplayServer(r io.Reader, w io.Writer, trace io.Reader) (*ReplayServer, error) {
+	s := &ReplayServer{r: r, w: w, recent: make(map[uint16][]byte)}
+
+	dec := json.NewDecoder(trace)
+	for {
+		var e TraceEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Request == nil || e.Response == nil {
+			continue
+		}
+		s.entries = append(s.entries, e)
+	}
+
+	return s, nil
+}
+
+// Serve answers requests until reading fails.
+func (s *ReplayServer) Serve() error {
+	r := NewChunkReader(s.r, s.Framing)
+	w := NewChunkWriter(s.w, s.Framing)
+	for {
+		typeCode, data, err := r.Next()
+		if errors.Is(err, ErrHeaderCRC) || errors.Is(err, ErrDataCRC) || errors.Is(err, ErrChunkLen) {
+			w.WriteChunk('E', []byte(err.Error()))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if typeCode != 'Q' {
+			w.WriteChunk('E', []byte("unknown type code"))
+			continue
+		}
+
+		var req Request
+		if err := req.decode(data); err != nil {
+			resp := Response{ID: req.ID, Err: err.Error(), Code: CodeBadRequest}
+			if err := w.WriteChunk('R', resp.encode()); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Cmd == reset {
+			s.recent = make(map[uint16][]byte)
+		} else if resp, ok := s.recent[req.ID]; ok {
+			if err := w.WriteChunk('R', resp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		resp := s.lookup(req)
+		resp.ID = req.ID
+		data = resp.encode()
+		if req.ID != 0 {
+			s.recent[req.ID] = data
+		}
+		if err := w.WriteChunk('R', data); err != nil {
+			return err
+		}
+	}
+}
+
+// lookup returns the response to the next recorded request matching req.
+func (s *ReplayServer) lookup(req Request) Response {
+	req.ID = 0
+	want := req.encode()
+
+	for i := s.next; i < len(s.entries); i++ {
+		rec := *s.entries[i].Request
+		rec.ID = 0
+		if !bytes.Equal(rec.encode(), want) {
+			continue
+		}
+
+		s.next = i + 1
+		return *s.entries[i].Response
+	}
+
+	return Response{Err: ErrNoReplay.Error(), Code: CodeNoReplay}
+}
@@ -0,0 +1,158 @@
+package xb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTap_Replay(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	pins := &testPins{state: make([]bool, 4)}
+	go NewServer(reqR, respW, pins).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+
+	var trace bytes.Buffer
+	tap := NewTap(respR, reqW, &trace)
+	c, err := NewClient(tap, tap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := func(c *Client) []bool {
+		t.Helper()
+		if err := c.Pin(2).Set(true); err != nil {
+			t.Fatal(err)
+		}
+		var res []bool
+		for n := 0; n < 4; n++ {
+			v, err := c.Pin(n).Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			res = append(res, v)
+		}
+		return res
+	}
+	want := session(c)
+	tap.Close()
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("got %d trace entries; want 6:\n%s", len(lines), trace.String())
+	}
+	var e TraceEntry
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Request == nil || e.Request.Cmd != setPin || e.Response == nil {
+		t.Errorf("unexpected entry: %s", lines[1])
+	}
+
+	// replay the session without the device
+	reqR, reqW = io.Pipe()
+	respR, respW = io.Pipe()
+	srv, err := NewReplayServer(reqR, respW, &trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+	})
+	c, err = NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := session(c)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("pin %d: got %v; want %v", i, got[i], want[i])
+		}
+	}
+
+	_, err = c.Pin(0).Get()
+	if !errors.Is(err, ErrNoReplay) {
+		t.Errorf("got %v; want ErrNoReplay", err)
+	}
+}
+
+func TestTap_Data(t *testing.T) {
+	var resps bytes.Buffer
+	for id := uint16(1); id <= 3; id++ {
+		WriteChunk(&resps, 'R', (&Response{ID: id, Data: bytes.Repeat([]byte{'a' + byte(id)}, 4)}).encode())
+	}
+
+	var trace bytes.Buffer
+	tap := NewTap(&resps, io.Discard, &trace)
+	for id := uint16(1); id <= 3; id++ {
+		WriteChunk(tap, 'Q', (&Request{ID: id, Cmd: spiWrite, Data: bytes.Repeat([]byte{'A' + byte(id)}, 4)}).encode())
+	}
+	io.Copy(io.Discard, tap)
+	tap.Close()
+
+	dec := json.NewDecoder(&trace)
+	for id := uint16(1); id <= 3; id++ {
+		var e TraceEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Request == nil || e.Response == nil {
+			t.Fatalf("entry %d: missing request or response", id)
+		}
+		if want := strings.Repeat(string(rune('A'+id)), 4); string(e.Request.Data) != want {
+			t.Errorf("request %d: got data %s; want %s", id, e.Request.Data, want)
+		}
+		if want := strings.Repeat(string(rune('a'+id)), 4); string(e.Response.Data) != want {
+			t.Errorf("response %d: got data %s; want %s", id, e.Response.Data, want)
+		}
+	}
+}
+
+func TestTap_Duplicates(t *testing.T) {
+	var trace bytes.Buffer
+	tap := NewTap(nil, io.Discard, &trace)
+	req := func(id uint16, cmd uint8) { tap.record('Q', (&Request{ID: id, Cmd: cmd}).encode()) }
+	resp := func(id uint16, v uint8) { tap.record('R', (&Response{ID: id, DataByte: v}).encode()) }
+
+	req(1, getPin)
+	resp(1, 1)
+	// retransmitted request, answered again by the device
+	req(1, getPin)
+	resp(1, 1)
+	resp(1, 1)
+	if len(tap.early) != 0 {
+		t.Errorf("%d early responses kept; want 0", len(tap.early))
+	}
+
+	// the ID is reused once it can no longer be a retransmission
+	for id := uint16(2); id < 2+recentResponses; id++ {
+		req(id, getPin)
+		resp(id, 0)
+	}
+	req(1, setPin)
+	resp(1, 2)
+
+	dec := json.NewDecoder(&trace)
+	var n int
+	var last TraceEntry
+	for dec.More() {
+		if err := dec.Decode(&last); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2+recentResponses {
+		t.Errorf("got %d entries; want %d", n, 2+recentResponses)
+	}
+	if last.Request.Cmd != setPin || last.Response.DataByte != 2 {
+		t.Errorf("last entry: got %+v %+v; want setPin with DataByte 2", last.Request, last.Response)
+	}
+}
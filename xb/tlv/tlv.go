@@ -0,0 +1,283 @@
+// Package tlv implements the tag-length-value encoding used by xb messages.
+//
+// Each field is written as a tag byte, a wire type byte and a payload whose
+// size is determined by the wire type. Since every field describes its own
+// size, fields may be decoded in any order and unknown fields are skipped,
+// so messages can gain fields without breaking older readers.
+//
+// Zero values are omitted when encoding and decode as zero.
+package tlv
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// Wire types.
+const (
+	typeTrue  = iota // no payload
+	type8            // 1 byte
+	type16           // 2 bytes, big-endian
+	type32           // 4 bytes, big-endian
+	type64           // 8 bytes, big-endian
+	typeBytes        // uvarint length followed by data
+)
+
+var (
+	ErrTruncated   = errors.New("tlv: truncated field")
+	ErrWireType    = errors.New("tlv: unexpected wire type")
+	ErrUnknownType = errors.New("tlv: unknown wire type")
+)
+
+// DecodeError reports the field that failed to decode.
+type DecodeError struct {
+	Tag uint8
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "tlv: field " + strconv.Itoa(int(e.Tag)) + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Marshaler is implemented by messages that can encode themselves.
+type Marshaler interface {
+	MarshalTLV(e *Encoder)
+}
+
+// Unmarshaler is implemented by messages that can decode themselves.
+//
+// UnmarshalTLV must reset the message before reading fields from d.
+type Unmarshaler interface {
+	UnmarshalTLV(d *Decoder) error
+}
+
+// Marshal returns the encoding of m.
+func Marshal(m Marshaler) []byte {
+	var e Encoder
+	m.MarshalTLV(&e)
+	return e.buf
+}
+
+// Unmarshal decodes data into m.
+//
+// Byte slices in m may refer to data.
+func Unmarshal(data []byte, m Unmarshaler) error {
+	return m.UnmarshalTLV(NewDecoder(data))
+}
+
+// Encoder appends fields to a buffer.
+type Encoder struct {
+	buf []byte
+}
+
+// NewEncoder returns an Encoder that appends to buf.
+func NewEncoder(buf []byte) *Encoder { return &Encoder{buf: buf} }
+
+// Bytes returns the encoded fields.
+func (e *Encoder) Bytes() []byte { return e.buf }
+
+// Reset discards the encoded fields, keeping the buffer.
+func (e *Encoder) Reset() { e.buf = e.buf[:0] }
+
+func (e *Encoder) Bool(tag uint8, v bool) {
+	if !v {
+		return
+	}
+	e.buf = append(e.buf, tag, typeTrue)
+}
+
+func (e *Encoder) Uint8(tag uint8, v uint8) {
+	if v == 0 {
+		return
+	}
+	e.buf = append(e.buf, tag, type8, v)
+}
+
+func (e *Encoder) Uint16(tag uint8, v uint16) {
+	if v == 0 {
+		return
+	}
+	e.buf = append(e.buf, tag, type16)
+	e.buf = binary.BigEndian.AppendUint16(e.buf, v)
+}
+
+func (e *Encoder) Uint32(tag uint8, v uint32) {
+	if v == 0 {
+		return
+	}
+	e.buf = append(e.buf, tag, type32)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, v)
+}
+
+func (e *Encoder) Uint64(tag uint8, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.buf = append(e.buf, tag, type64)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+}
+
+func (e *Encoder) Data(tag uint8, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	e.buf = append(e.buf, tag, typeBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *Encoder) String(tag uint8, v string) {
+	if v == "" {
+		return
+	}
+	e.buf = append(e.buf, tag, typeBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// Message encodes m as a nested message. Unlike other values, an empty
+// message is still written so that it decodes as present.
+func (e *Encoder) Message(tag uint8, m Marshaler) {
+	var sub Encoder
+	m.MarshalTLV(&sub)
+
+	e.buf = append(e.buf, tag, typeBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(sub.buf)))
+	e.buf = append(e.buf, sub.buf...)
+}
+
+// Decoder reads fields, in the order they were encoded, from a buffer.
+//
+//	for d.Next() {
+//		switch d.Tag() {
+//		case 1:
+//			v.Foo = d.Uint16()
+//		}
+//	}
+//	return d.Err()
+//
+// Fields that aren't read are skipped.
+type Decoder struct {
+	data []byte
+
+	tag uint8
+	typ uint8
+	val []byte
+	err error
+}
+
+// NewDecoder returns a Decoder reading from data.
+func NewDecoder(data []byte) *Decoder { return &Decoder{data: data} }
+
+// Next advances to the next field. It returns false when there are no
+// more fields or an error occurred.
+func (d *Decoder) Next() bool {
+	if d.err != nil || len(d.data) == 0 {
+		return false
+	}
+	if len(d.data) < 2 {
+		d.fail(d.data[0], ErrTruncated)
+		return false
+	}
+
+	d.tag, d.typ = d.data[0], d.data[1]
+	data := d.data[2:]
+
+	var n int
+	switch d.typ {
+	case typeTrue:
+	case type8:
+		n = 1
+	case type16:
+		n = 2
+	case type32:
+		n = 4
+	case type64:
+		n = 8
+	case typeBytes:
+		l, sz := binary.Uvarint(data)
+		if sz <= 0 || l > uint64(len(data)-sz) {
+			d.fail(d.tag, ErrTruncated)
+			return false
+		}
+		data = data[sz:]
+		n = int(l)
+	default:
+		d.fail(d.tag, ErrUnknownType)
+		return false
+	}
+	if len(data) < n {
+		d.fail(d.tag, ErrTruncated)
+		return false
+	}
+
+	d.val = data[:n]
+	d.data = data[n:]
+	return true
+}
+
+func (d *Decoder) fail(tag uint8, err error) {
+	if d.err == nil {
+		d.err = &DecodeError{Tag: tag, Err: err}
+	}
+}
+
+// Err returns the first error encountered.
+func (d *Decoder) Err() error { return d.err }
+
+// Tag returns the tag of the current field.
+func (d *Decoder) Tag() uint8 { return d.tag }
+
+func (d *Decoder) Bool() bool {
+	if d.typ != typeTrue {
+		d.fail(d.tag, ErrWireType)
+		return false
+	}
+	return true
+}
+
+// uint reads an integer field of at most max bytes. Smaller wire types are
+// accepted so that fields can be widened.
+func (d *Decoder) uint(max int) uint64 {
+	if d.typ < type8 || d.typ > type64 || len(d.val) > max {
+		d.fail(d.tag, ErrWireType)
+		return 0
+	}
+
+	var v uint64
+	for _, b := range d.val {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (d *Decoder) Uint8() uint8   { return uint8(d.uint(1)) }
+func (d *Decoder) Uint16() uint16 { return uint16(d.uint(2)) }
+func (d *Decoder) Uint32() uint32 { return uint32(d.uint(4)) }
+func (d *Decoder) Uint64() uint64 { return d.uint(8) }
+
+// Data returns the current field's bytes, which refer to the
+// decoder's buffer.
+func (d *Decoder) Data() []byte {
+	if d.typ != typeBytes {
+		d.fail(d.tag, ErrWireType)
+		return nil
+	}
+	return d.val
+}
+
+func (d *Decoder) String() string { return string(d.Data()) }
+
+// Message decodes the current field as a nested message.
+func (d *Decoder) Message(m Unmarshaler) {
+	data := d.Data()
+	if d.err != nil {
+		return
+	}
+	if err := m.UnmarshalTLV(NewDecoder(data)); err != nil {
+		d.fail(d.tag, err)
+	}
+}
@@ -0,0 +1,133 @@
+package tlv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type point struct {
+	X, Y  uint16
+	Label string
+	Flag  bool
+	Inner *point
+}
+
+func (p *point) MarshalTLV(e *Encoder) {
+	e.Uint16(1, p.X)
+	e.Uint16(2, p.Y)
+	e.String(3, p.Label)
+	e.Bool(4, p.Flag)
+	if p.Inner != nil {
+		e.Message(5, p.Inner)
+	}
+}
+
+func (p *point) UnmarshalTLV(d *Decoder) error {
+	*p = point{}
+	for d.Next() {
+		switch d.Tag() {
+		case 1:
+			p.X = d.Uint16()
+		case 2:
+			p.Y = d.Uint16()
+		case 3:
+			p.Label = d.String()
+		case 4:
+			p.Flag = d.Bool()
+		case 5:
+			p.Inner = new(point)
+			d.Message(p.Inner)
+		}
+	}
+	return d.Err()
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := point{X: 1, Y: 0x1234, Label: "a", Flag: true, Inner: &point{}}
+	var got point
+	if err := Unmarshal(Marshal(&want), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.X != want.X || got.Y != want.Y || got.Label != want.Label || !got.Flag || got.Inner == nil {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+
+	if len(Marshal(&point{})) != 0 {
+		t.Error("zero values should be omitted")
+	}
+}
+
+func TestDecoder_OrderAndUnknown(t *testing.T) {
+	var e Encoder
+	e.String(3, "label")
+	e.Uint64(99, 1<<40) // unknown
+	e.Data(100, []byte{0, 1, 2})
+	e.Bool(101, true)
+	e.Uint8(2, 7) // narrower than the field
+	e.Uint16(1, 300)
+
+	var p point
+	if err := Unmarshal(e.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.X != 300 || p.Y != 7 || p.Label != "label" {
+		t.Errorf("unexpected result %+v", p)
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	var e Encoder
+	e.Uint16(1, 300)
+	e.String(3, "label")
+	full := e.Bytes()
+
+	var inner Encoder
+	inner.Uint32(1, 1) // too wide for X
+	var nested Encoder
+	nested.Data(5, inner.Bytes())
+
+	tests := []struct {
+		name string
+		data []byte
+		tag  uint8
+		err  error
+	}{
+		{"truncated value", full[:3], 1, ErrTruncated},
+		{"truncated length", full[:6], 3, ErrTruncated},
+		{"truncated key", append([]byte{}, full[0]), full[0], ErrTruncated},
+		{"unknown type", []byte{1, 0x7f, 0}, 1, ErrUnknownType},
+		{"wire type", []byte{3, 1, 'x'}, 3, ErrWireType},
+		{"nested", nested.Bytes(), 5, ErrWireType},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var p point
+			err := Unmarshal(tc.data, &p)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got %v; want %v", err, tc.err)
+			}
+			var de *DecodeError
+			if !errors.As(err, &de) || de.Tag != tc.tag {
+				t.Errorf("got %v; want error for tag %d", err, tc.tag)
+			}
+		})
+	}
+}
+
+func TestEncoder_Long(t *testing.T) {
+	data := bytes.Repeat([]byte{0xaa}, 70000)
+	var e Encoder
+	e.Data(1, data)
+
+	d := NewDecoder(e.Bytes())
+	if !d.Next() {
+		t.Fatal(d.Err())
+	}
+	if !bytes.Equal(d.Data(), data) {
+		t.Error("data mismatch")
+	}
+	if d.Next() || d.Err() != nil {
+		t.Errorf("unexpected trailing field or error: %v", d.Err())
+	}
+}
@@ -0,0 +1,250 @@
+// Package tlvgen generates tlv.Marshaler and tlv.Unmarshaler methods for
+// structs.
+//
+// Fields to encode are marked with a tlv struct tag holding either a
+// single character or a number from 0 to 255:
+//
+//	type Foo struct {
+//		ID   uint16 `tlv:"i"`
+//		Name string `tlv:"1"`
+//	}
+//
+// Supported field types are bool, sized integers, string, []byte, named
+// types with one of those as the underlying type, and pointers to structs
+// that implement the tlv interfaces.
+package tlvgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const importPath = "github.com/mastercactapus/embedded/xb/tlv"
+
+// Generate returns the source for a file in the package in dir containing
+// methods for the named types. The file named skip, if any, is not parsed
+// so that a previously generated file is ignored.
+func Generate(dir, skip string, types []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != filepath.Base(skip)
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("tlvgen: expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	decls := make(map[string]ast.Expr)
+	for _, f := range pkg.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			ts, ok := n.(*ast.TypeSpec)
+			if ok {
+				decls[ts.Name.Name] = ts.Type
+			}
+			return true
+		})
+	}
+
+	g := &generator{decls: decls}
+	fmt.Fprintf(&g.buf, "// Code generated by tlvgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&g.buf, "package %s\n\nimport %q\n", pkg.Name, importPath)
+
+	for _, name := range types {
+		st, ok := decls[name].(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("tlvgen: %s is not a struct type", name)
+		}
+		if err := g.genType(name, st); err != nil {
+			return nil, err
+		}
+	}
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("tlvgen: format: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	buf   bytes.Buffer
+	decls map[string]ast.Expr
+}
+
+type field struct {
+	name string
+	tag  string
+	kind string // Encoder/Decoder method
+	conv string // Go type to convert to when decoding, if not the method's type
+	msg  string // element type for Message fields
+}
+
+func (g *generator) genType(name string, st *ast.StructType) error {
+	var fields []field
+	seen := make(map[int]string)
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		tagStr, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			return err
+		}
+		tag, ok := reflect.StructTag(tagStr).Lookup("tlv")
+		if !ok {
+			continue
+		}
+		num, lit, err := parseTag(tag)
+		if err != nil {
+			return fmt.Errorf("tlvgen: %s: %w", name, err)
+		}
+		if len(f.Names) != 1 {
+			return fmt.Errorf("tlvgen: %s: tagged fields must be declared one per line", name)
+		}
+		fname := f.Names[0].Name
+		if prev, ok := seen[num]; ok {
+			return fmt.Errorf("tlvgen: %s: fields %s and %s both use tag %s", name, prev, fname, lit)
+		}
+		seen[num] = fname
+
+		fd := field{name: fname, tag: lit}
+		if err := g.resolve(&fd, f.Type); err != nil {
+			return fmt.Errorf("tlvgen: %s.%s: %w", name, fname, err)
+		}
+		fields = append(fields, fd)
+	}
+
+	fmt.Fprintf(&g.buf, "\nfunc (m *%s) MarshalTLV(e *tlv.Encoder) {\n", name)
+	for _, f := range fields {
+		switch {
+		case f.msg != "":
+			fmt.Fprintf(&g.buf, "if m.%s != nil {\ne.Message(%s, m.%s)\n}\n", f.name, f.tag, f.name)
+		case f.conv != "":
+			fmt.Fprintf(&g.buf, "e.%s(%s, %s(m.%s))\n", f.kind, f.tag, methodType(f.kind), f.name)
+		default:
+			fmt.Fprintf(&g.buf, "e.%s(%s, m.%s)\n", f.kind, f.tag, f.name)
+		}
+	}
+	fmt.Fprintf(&g.buf, "}\n")
+
+	fmt.Fprintf(&g.buf, "\nfunc (m *%s) UnmarshalTLV(d *tlv.Decoder) error {\n*m = %s{}\n", name, name)
+	fmt.Fprintf(&g.buf, "for d.Next() {\nswitch d.Tag() {\n")
+	for _, f := range fields {
+		fmt.Fprintf(&g.buf, "case %s:\n", f.tag)
+		switch {
+		case f.msg != "":
+			fmt.Fprintf(&g.buf, "m.%s = new(%s)\nd.Message(m.%s)\n", f.name, f.msg, f.name)
+		case f.conv != "":
+			fmt.Fprintf(&g.buf, "m.%s = %s(d.%s())\n", f.name, f.conv, f.kind)
+		default:
+			fmt.Fprintf(&g.buf, "m.%s = d.%s()\n", f.name, f.kind)
+		}
+	}
+	fmt.Fprintf(&g.buf, "}\n}\nreturn d.Err()\n}\n")
+
+	return nil
+}
+
+// parseTag returns the tag number and a Go literal for it.
+func parseTag(tag string) (int, string, error) {
+	if n, err := strconv.Atoi(tag); err == nil {
+		if n < 0 || n > 255 {
+			return 0, "", fmt.Errorf("tag %s out of range", tag)
+		}
+		return n, tag, nil
+	}
+	if len(tag) != 1 {
+		return 0, "", fmt.Errorf("invalid tag %q", tag)
+	}
+	return int(tag[0]), strconv.QuoteRune(rune(tag[0])), nil
+}
+
+var basicKinds = map[string]string{
+	"bool":   "Bool",
+	"byte":   "Uint8",
+	"uint8":  "Uint8",
+	"int8":   "Uint8",
+	"uint16": "Uint16",
+	"int16":  "Uint16",
+	"uint32": "Uint32",
+	"int32":  "Uint32",
+	"uint64": "Uint64",
+	"int64":  "Uint64",
+	"string": "String",
+}
+
+func methodType(kind string) string {
+	switch kind {
+	case "Bool":
+		return "bool"
+	case "String":
+		return "string"
+	}
+	return strings.ToLower(kind)
+}
+
+func (g *generator) resolve(f *field, expr ast.Expr) error {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if kind, ok := basicKinds[t.Name]; ok {
+			f.kind = kind
+			if methodType(kind) != t.Name && t.Name != "byte" {
+				f.conv = t.Name
+			}
+			return nil
+		}
+
+		under, ok := g.decls[t.Name]
+		if !ok {
+			return fmt.Errorf("unknown type %s", t.Name)
+		}
+		id, ok := under.(*ast.Ident)
+		if !ok {
+			return fmt.Errorf("unsupported type %s", t.Name)
+		}
+		kind, ok := basicKinds[id.Name]
+		if !ok {
+			return fmt.Errorf("unsupported type %s", t.Name)
+		}
+		f.kind = kind
+		f.conv = t.Name
+		return nil
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (id.Name == "byte" || id.Name == "uint8") {
+			f.kind = "Data"
+			return nil
+		}
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			if _, ok := g.decls[id.Name].(*ast.StructType); ok {
+				f.kind = "Message"
+				f.msg = id.Name
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unsupported type %s", exprString(expr))
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
@@ -0,0 +1,222 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mastercactapus/embedded/driver"
+	"github.com/mastercactapus/embedded/term/ascii"
+)
+
+type Parity uint8
+
+const (
+	ParityNone Parity = iota
+	ParityEven
+	ParityOdd
+)
+
+// UARTConfig configures a UART on the device.
+type UARTConfig struct {
+	TX     uint8  `tlv:"1"`
+	RX     uint8  `tlv:"2"`
+	Baud   uint32 `tlv:"3"`
+	Parity Parity `tlv:"4"`
+
+	// DataBits defaults to 8, StopBits to 1.
+	DataBits uint8 `tlv:"5"`
+	StopBits uint8 `tlv:"6"`
+}
+
+// UARTProvider is implemented by a Pinner passed to NewServer that can
+// provide a UART on the given pins.
+//
+// Reads from the returned UART may block, or return zero bytes if none
+// are available. If it implements io.Closer, it is closed when the bridge
+// is closed or replaced, which must unblock a pending Read.
+type UARTProvider interface {
+	UART(cfg UARTConfig) (io.ReadWriter, error)
+}
+
+// maxUARTWrite is the largest write sent in a single request.
+const maxUARTWrite = 96
+
+func (s *Server) setupUART(cfg UARTConfig) error {
+	s.closeUART()
+
+	if int(cfg.TX) >= len(s.pins) || int(cfg.RX) >= len(s.pins) {
+		return ascii.Errorf("uartSetup: %w", ErrBadPin)
+	}
+	if cfg.Baud == 0 || cfg.Parity > ParityOdd {
+		return ascii.Errorf("uartSetup: %w", ErrBadRequest)
+	}
+	if cfg.DataBits == 0 {
+		cfg.DataBits = 8
+	}
+	if cfg.StopBits == 0 {
+		cfg.StopBits = 1
+	}
+	p, ok := s.dev.(UARTProvider)
+	if !ok {
+		return driver.ErrNotSupported
+	}
+	u, err := p.UART(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.uart = u
+	s.uartDone = make(chan struct{})
+	go s.uartLoop(u, s.uartDone)
+	return nil
+}
+
+func (s *Server) closeUART() {
+	if s.uart == nil {
+		return
+	}
+	close(s.uartDone)
+	if c, ok := s.uart.(io.Closer); ok {
+		c.Close()
+	}
+	s.uart = nil
+}
+
+// uartLoop forwards data received by the UART to the client as 'U' chunks.
+func (s *Server) uartLoop(u io.Reader, done chan struct{}) {
+	buf := make([]byte, 64)
+	for {
+		n, err := u.Read(buf)
+		select {
+		case <-done:
+			return
+		case <-s.done:
+			return
+		default:
+		}
+		if n > 0 {
+			s.writeChunk('U', buf[:n])
+		}
+		if err != nil {
+			s.logf("uart: %s", err.Error())
+			return
+		}
+		if n == 0 {
+			time.Sleep(s.PollInterval)
+		}
+	}
+}
+
+func (s *Server) writeUART(data []byte) error {
+	if s.uart == nil {
+		return ascii.Errorf("uartWrite: uart %w", ErrNotInitialized)
+	}
+	_, err := s.uart.Write(data)
+	return err
+}
+
+// UART configures a UART on the device and returns a bridge to it.
+//
+// Data received by the device is pushed without acknowledgement,
+// so it may be lost if the link to the device is unreliable. It is also
+// dropped if it isn't read as fast as it arrives.
+// Only one UART may be open at a time.
+func (c *Client) UART(cfg UARTConfig) (io.ReadWriteCloser, error) {
+	c.uartMx.Lock()
+	defer c.uartMx.Unlock()
+	if c.uart != nil {
+		c.uart.shutdown()
+		c.uart = nil
+	}
+
+	u := &uartClient{c: c, data: make(chan []byte, 64), done: make(chan struct{})}
+	c.uart = u
+
+	_, err := c.tx(&Request{Cmd: uartSetup, UARTConfig: &cfg})
+	if err != nil {
+		c.uart = nil
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// uartData is called by the read loop for each 'U' chunk.
+func (c *Client) uartData(data []byte) {
+	c.uartMx.Lock()
+	u := c.uart
+	c.uartMx.Unlock()
+	if u == nil {
+		return
+	}
+
+	select {
+	case u.data <- data:
+	default:
+		// reader isn't keeping up
+	}
+}
+
+type uartClient struct {
+	c    *Client
+	data chan []byte
+	buf  []byte
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+var errUARTClosed = errors.New("xb: uart closed")
+
+func (u *uartClient) shutdown() { u.closeOnce.Do(func() { close(u.done) }) }
+
+func (u *uartClient) Read(p []byte) (int, error) {
+	if len(u.buf) == 0 {
+		select {
+		case u.buf = <-u.data:
+		case <-u.done:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+func (u *uartClient) Write(p []byte) (int, error) {
+	select {
+	case <-u.done:
+		return 0, errUARTClosed
+	default:
+	}
+
+	var n int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxUARTWrite {
+			chunk = chunk[:maxUARTWrite]
+		}
+		_, err := u.c.tx(&Request{Cmd: uartWrite, Data: chunk})
+		if err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+func (u *uartClient) Close() error {
+	u.c.uartMx.Lock()
+	if u.c.uart == u {
+		u.c.uart = nil
+	}
+	u.c.uartMx.Unlock()
+
+	u.shutdown()
+	_, err := u.c.tx(&Request{Cmd: uartClose})
+	return err
+}
@@ -0,0 +1,115 @@
+package xb
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mastercactapus/embedded/at"
+)
+
+type uartPins struct {
+	*testPins
+	cfg  UARTConfig
+	conn io.ReadWriter
+}
+
+func (p *uartPins) UART(cfg UARTConfig) (io.ReadWriter, error) {
+	p.cfg = cfg
+	return p.conn, nil
+}
+
+func TestClient_UART(t *testing.T) {
+	// modem side of the device UART
+	modemR, devW := io.Pipe()
+	devR, modemW := io.Pipe()
+	modem := at.NewServer(modemR, modemW)
+	modem.HandleFunc("AT+NAME", func(c at.Cmd) at.Response {
+		var resp at.Response
+		resp.OK = true
+		resp.SetValue("", "modem")
+		return resp
+	})
+	go modem.Serve()
+
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	dev := &uartPins{
+		testPins: &testPins{state: make([]bool, 4)},
+		conn:     pipeConn{devR, devW},
+	}
+	go NewServer(reqR, respW, dev).Serve()
+	t.Cleanup(func() {
+		reqW.Close()
+		respW.Close()
+		modemW.Close()
+		devW.Close()
+	})
+	c, err := NewClient(respR, reqW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.UART(UARTConfig{TX: 4, RX: 1, Baud: 9600})
+	if !errors.Is(err, ErrBadPin) {
+		t.Fatalf("got %v; want ErrBadPin", err)
+	}
+
+	u, err := c.UART(UARTConfig{TX: 0, RX: 1, Baud: 115200, Parity: ParityEven})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UARTConfig{TX: 0, RX: 1, Baud: 115200, Parity: ParityEven, DataBits: 8, StopBits: 1}
+	if dev.cfg != want {
+		t.Errorf("got config %+v; want %+v", dev.cfg, want)
+	}
+
+	resp, err := at.NewClient(u).Execute("NAME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := resp.Value(""); !resp.OK || v != "modem" {
+		t.Errorf("got %q; want modem", v)
+	}
+
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read after close: got %v; want EOF", err)
+	}
+}
+
+type closeConn struct {
+	*io.PipeReader
+	io.Writer
+}
+
+func TestServer_UARTClose(t *testing.T) {
+	r1, w1 := io.Pipe()
+	r2, w2 := io.Pipe()
+	t.Cleanup(func() {
+		w1.Close()
+		w2.Close()
+	})
+	dev := &uartPins{testPins: &testPins{state: make([]bool, 4)}, conn: closeConn{r1, io.Discard}}
+	srv := NewServer(nil, io.Discard, dev)
+
+	if err := srv.setupUART(UARTConfig{TX: 0, RX: 1, Baud: 9600}); err != nil {
+		t.Fatal(err)
+	}
+	dev.conn = closeConn{r2, io.Discard}
+	if err := srv.setupUART(UARTConfig{TX: 0, RX: 1, Baud: 9600}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first UART is closed, unblocking its reader
+	if _, err := w1.Write([]byte{1}); err != io.ErrClosedPipe {
+		t.Errorf("write to replaced UART: got %v; want ErrClosedPipe", err)
+	}
+
+	srv.closeUART()
+	if _, err := w2.Write([]byte{1}); err != io.ErrClosedPipe {
+		t.Errorf("write to closed UART: got %v; want ErrClosedPipe", err)
+	}
+}